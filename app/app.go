@@ -3,18 +3,20 @@ package app
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
+	"runtime"
 	"sync"
 	"sync/atomic"
 	"syscall"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
 	"github.com/uptrace/bun"
-	"github.com/uptrace/bun/dialect/mysqldialect"
-	"github.com/uptrace/bun/extra/bundebug"
-
-	_ "github.com/go-sql-driver/mysql"
 	"github.com/urfave/cli/v2"
 )
 
@@ -31,7 +33,11 @@ func ContextWithApp(ctx context.Context, app *App) context.Context {
 
 type App struct {
 	ctx context.Context
-	cfg *Config
+	// cfg is swapped by Reload.
+	cfg atomic.Pointer[Config]
+
+	reloadMu      sync.Mutex
+	configChanges configChangeHooks
 
 	stopping uint32
 	stopCh   chan struct{}
@@ -39,57 +45,145 @@ type App struct {
 	onStop      appHooks
 	onAfterStop appHooks
 
-	// lazy init
+	// exitSignals and exit are replaced in tests.
+	exitSignals func() (<-chan os.Signal, func())
+	exit        func(code int)
+
+	// lazy init; db is also read by DBStats, which must not wait for
+	// dbOnce.
 	dbOnce sync.Once
-	db     *bun.DB
+	db     atomic.Pointer[bun.DB]
+	dbErr  error
+
+	replicasOnce sync.Once
+	replicas     *replicaSet
+	replicasErr  error
+
+	metricsOnce      sync.Once
+	metrics          *prometheus.Registry
+	queryMetricsOnce sync.Once
+	queryMetrics     *queryMetrics
 }
 
 func New(ctx context.Context, cfg *Config) *App {
 	app := &App{
-		cfg:    cfg,
-		stopCh: make(chan struct{}),
+		stopCh:      make(chan struct{}),
+		exitSignals: notifyExitSignals,
+		exit:        os.Exit,
 	}
+	app.cfg.Store(cfg)
 	app.ctx = ContextWithApp(ctx, app)
+	app.OnConfigChange("log.level", func(_ context.Context, _, cfg *Config) error {
+		return setLogLevel(cfg)
+	})
 	return app
 }
 
+// StartCLI starts the app with the configuration selected by the global
+// --env, --config-dir and --set flags.
 func StartCLI(c *cli.Context) (context.Context, *App, error) {
-	return Start(c.Context, c.Command.Name, c.String("env"))
+	cfg, err := LoadCLIConfig(c)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return StartConfig(c.Context, cfg)
 }
 
-func Start(ctx context.Context, service, envName string) (context.Context, *App, error) {
-	cfg := LoadConfig(ctx)
+// LoadCLIConfig loads the configuration selected by the global --env,
+// --config-dir and --set flags without validating it.
+func LoadCLIConfig(c *cli.Context) (*Config, error) {
+	flags, err := ParseFlagOverrides(c.StringSlice("set"))
+	if err != nil {
+		return nil, err
+	}
+	opts := LoadOptions{
+		Dir:   c.String("config-dir"),
+		Flags: flags,
+	}
+	if c.IsSet("env") {
+		opts.Env = c.String("env")
+	}
+	return LoadConfig(c.Context, opts)
+}
 
+func Start(ctx context.Context, service, envName string) (context.Context, *App, error) {
+	cfg, err := LoadConfig(ctx, LoadOptions{Env: envName})
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
 	return StartConfig(ctx, cfg)
 }
 
 func StartConfig(ctx context.Context, cfg *Config) (context.Context, *App, error) {
+	if cfg.MaxProcesses > 0 {
+		runtime.GOMAXPROCS(cfg.MaxProcesses)
+	}
+
+	// Secrets must never reach the logs, whatever logs them.
+	setupLogging(NewLogger(cfg, os.Stderr))
+	if err := setLogLevel(cfg); err != nil {
+		return nil, nil, err
+	}
+
 	app := New(ctx, cfg)
-	if err := onStart.Run(ctx, app); err != nil {
+	if err := setupTracing(ctx, app); err != nil {
+		return nil, nil, err
+	}
+	if err := onStart.Run(ctx, app, false, true); err != nil {
 		return nil, nil, err
 	}
 	return app.ctx, app, nil
 }
 
+// Stop runs the stop hooks and then the after-stop hooks, see Shutdown,
+// logging their errors.
 func (app *App) Stop() {
-	_ = app.onStop.Run(app.ctx, app)
-	_ = app.onAfterStop.Run(app.ctx, app)
+	if err := app.Shutdown(app.ctx); err != nil {
+		log.Error().Err(err).Msg("stopping the app failed")
+	}
+}
+
+// Shutdown runs the stop hooks in reverse registration order, so that what
+// started last stops first, and then the after-stop hooks. Every hook runs
+// even if others fail, and all errors are returned together. Only the
+// first call does anything.
+func (app *App) Shutdown(ctx context.Context) error {
+	if !atomic.CompareAndSwapUint32(&app.stopping, 0, 1) {
+		return nil
+	}
+	close(app.stopCh)
+
+	return errors.Join(
+		app.onStop.Run(ctx, app, true, false),
+		app.onAfterStop.Run(ctx, app, true, false),
+	)
 }
 
-func (app *App) OnStop(name string, fn HookFunc) {
-	app.onStop.Add(newHook(name, fn))
+// OnStop registers a hook run by Shutdown. Hooks registered later stop
+// earlier unless After says otherwise.
+func (app *App) OnStop(name string, fn HookFunc, opts ...HookOption) {
+	app.onStop.Add(newHook(name, fn, opts))
 }
 
-func (app *App) OnAfterStop(name string, fn HookFunc) {
-	app.onAfterStop.Add(newHook(name, fn))
+// OnAfterStop registers a hook run by Shutdown once all stop hooks ran.
+func (app *App) OnAfterStop(name string, fn HookFunc, opts ...HookOption) {
+	app.onAfterStop.Add(newHook(name, fn, opts))
 }
 
 func (app *App) Context() context.Context {
 	return app.ctx
 }
 
+// Config returns the current configuration. Keep the returned value only
+// as long as needed: Reload replaces it rather than changing it.
 func (app *App) Config() *Config {
-	return app.cfg
+	return app.cfg.Load()
 }
 
 func (app *App) Running() bool {
@@ -101,48 +195,164 @@ func (app *App) Stopping() bool {
 }
 
 func (app *App) IsDebug() bool {
-	return app.cfg.Debug
+	return app.Config().Debug
 }
 
-func (app *App) DB() *bun.DB {
+// DB returns the database connection, opening it on first use. Opening
+// waits for the database to come up for at most DB_CONNECT_TIMEOUT; a
+// failure is remembered and returned on every later call.
+func (app *App) DB() (*bun.DB, error) {
 	app.dbOnce.Do(func() {
-		// Build DSN string
-		dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true",
-			app.cfg.DB.User,
-			app.cfg.DB.Password,
-			app.cfg.DB.Host,
-			app.cfg.DB.Port,
-			app.cfg.DB.Database,
-		)
-
-		sqldb, err := sql.Open("mysql", dsn)
+		cfg := app.Config()
+		db, err := OpenDB(app.ctx, cfg)
 		if err != nil {
-			panic(fmt.Sprintf("failed to open DB: %v", err))
+			app.dbErr = err
+			return
 		}
 
-		if err := sqldb.Ping(); err != nil {
-			panic(fmt.Sprintf("failed to connect to DB: %v", err))
-		}
-
-		db := bun.NewDB(sqldb, mysqldialect.New())
-
 		app.OnStop("db.Close", func(ctx context.Context, _ *App) error {
 			return db.Close()
 		})
 
-		if app.cfg.Debug {
-			db.AddQueryHook(bundebug.NewQueryHook(bundebug.WithVerbose(true)))
+		db.AddQueryHook(app.debugQueryHook())
+		db.AddQueryHook(app.tracingQueryHook(db))
+		app.instrumentDB(db, "primary")
+		app.OnConfigChange("db.pool", func(_ context.Context, old, cfg *Config) error {
+			if poolChanged(old.DB, cfg.DB) {
+				setPool(db, cfg.DB)
+			}
+			return nil
+		})
+
+		app.db.Store(db)
+	})
+
+	return app.db.Load(), app.dbErr
+}
+
+// ReadDB returns the database to send a read-only query to: one of the
+// healthy replicas from DB_REPLICA_DSNS in turn, or the primary when there
+// are none or all of them fail their health checks. Call it per query
+// rather than keeping the result.
+func (app *App) ReadDB() (*bun.DB, error) {
+	app.replicasOnce.Do(func() {
+		primary, err := app.DB()
+		if err != nil {
+			app.replicasErr = err
+			return
+		}
+
+		appCfg := app.Config()
+		var dbs []*bun.DB
+		for i, dsn := range appCfg.DB.ReplicaDSNs {
+			cfg := *appCfg
+			cfg.DB.DSN = dsn
+			// The replica DSN is complete; re-reading DB_DSN on rotation
+			// would point the replica at the primary.
+			cfg.secrets = nil
+			db, err := newDB(&cfg)
+			if err != nil {
+				for _, db := range dbs {
+					_ = db.Close()
+				}
+				app.replicasErr = err
+				return
+			}
+			db.AddQueryHook(app.debugQueryHook())
+			db.AddQueryHook(app.tracingQueryHook(db))
+			app.instrumentDB(db, fmt.Sprintf("replica_%d", i+1))
+			dbs = append(dbs, db)
 		}
 
-		app.db = db
+		rs := newReplicaSet(primary, dbs)
+		if len(dbs) > 0 {
+			timeout := appCfg.DB.DialTimeout
+			if timeout <= 0 {
+				timeout = 5 * time.Second
+			}
+			rs.checkHealth(app.ctx, timeout)
+
+			interval := appCfg.DB.ReplicaHealthInterval
+			if interval <= 0 {
+				interval = 10 * time.Second
+			}
+			ctx, cancel := context.WithCancel(app.ctx)
+			go rs.run(ctx, interval, timeout)
+			app.OnStop("db.replicas.Close", func(context.Context, *App) error {
+				cancel()
+				return rs.close()
+			})
+			app.OnConfigChange("db.replicas.pool", func(_ context.Context, old, cfg *Config) error {
+				if poolChanged(old.DB, cfg.DB) {
+					for _, db := range dbs {
+						setPool(db, cfg.DB)
+					}
+				}
+				return nil
+			})
+		}
+		app.replicas = rs
 	})
 
-	return app.db
+	if app.replicasErr != nil {
+		return nil, app.replicasErr
+	}
+	return app.replicas.pick(), nil
+}
+
+// debugQueryHook logs every query, with secrets redacted, while DEBUG is
+// on. The lines go to the logger of the query's context, so they carry the
+// request ID of the request that ran the query.
+func (app *App) debugQueryHook() bun.QueryHook {
+	return &debugQueryHook{app: app}
+}
+
+type debugQueryHook struct {
+	app *App
+}
+
+func (h *debugQueryHook) BeforeQuery(ctx context.Context, _ *bun.QueryEvent) context.Context {
+	return ctx
+}
+
+func (h *debugQueryHook) AfterQuery(ctx context.Context, event *bun.QueryEvent) {
+	if !h.app.IsDebug() {
+		return
+	}
+	cfg := h.app.Config()
+	e := zerolog.Ctx(ctx).Info()
+	if event.Err != nil && !errors.Is(event.Err, sql.ErrNoRows) {
+		e = zerolog.Ctx(ctx).Error().Str("error", cfg.Redact(event.Err.Error()))
+	}
+	e.Str("operation", event.Operation()).
+		Dur("took", time.Since(event.StartTime)).
+		Str("query", cfg.Redact(event.Query)).
+		Msg("query")
+}
+
+// DBStats reports the connection pool statistics, or zero values while
+// the database has not been opened.
+func (app *App) DBStats() sql.DBStats {
+	db := app.db.Load()
+	if db == nil {
+		return sql.DBStats{}
+	}
+	return db.Stats()
 }
 
 //------------------------------------------------------------------------------
 
+// WaitExitSignal blocks until the process is asked to exit with SIGINT,
+// SIGQUIT or SIGTERM and returns the signal.
 func (app *App) WaitExitSignal() os.Signal {
+	ch, stop := app.exitSignals()
+	defer stop()
+	return <-ch
+}
+
+// notifyExitSignals relays the exit signals to the returned channel until
+// the returned function is called.
+func notifyExitSignals() (<-chan os.Signal, func()) {
 	ch := make(chan os.Signal, 3)
 	signal.Notify(
 		ch,
@@ -150,5 +360,5 @@ func (app *App) WaitExitSignal() os.Signal {
 		syscall.SIGQUIT,
 		syscall.SIGTERM,
 	)
-	return <-ch
+	return ch, func() { signal.Stop(ch) }
 }
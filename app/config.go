@@ -1,77 +1,209 @@
 package app
 
 import (
-	"context"
-	"log"
-	"os"
-	"strconv"
-
-	"github.com/joho/godotenv"
+	"fmt"
+	"strings"
+	"time"
 )
 
 type PathToEnv struct{}
 
 // Config represents the application configuration.
+//
+// Every setting has a key in the config files (the yaml tag, nested per
+// section), an environment variable (env), an optional default and a
+// description (desc). validate holds the rules checked by Validate, secret
+// marks values that can come from a SecretProvider and are redacted when
+// printed, example is the value put into .env.example and reload marks
+// settings App.Reload applies at runtime. See LoadConfig for how the
+// sources are layered.
 type Config struct {
-	Env   string
-	Debug bool
-	Url   string
-	DB    struct {
-		Host      string
-		Port      string
-		User      string
-		Password  string
-		Database  string
-		BatchSize int
-	}
-	UserGeoApiToken string
+	Env     string `yaml:"env" env:"APP_ENV" default:"dev" validate:"required" desc:"Environment name, selects config.<env>.yaml"`
+	AppName string `yaml:"app_name" env:"APP_NAME" default:"user-management" validate:"required" desc:"Service name used in logs"`
+	Debug   bool   `yaml:"debug" env:"DEBUG" default:"false" example:"true" reload:"true" desc:"Log SQL queries and other debug output"`
+	// LogLevel is the minimum level of log messages.
+	LogLevel string `yaml:"log_level" env:"LOG_LEVEL" default:"info" validate:"oneof=trace debug info warn error" reload:"true" desc:"Minimum level of log messages: trace, debug, info, warn or error"`
+	// LogFormat is json for log collectors or console for people.
+	LogFormat   string            `yaml:"log_format" env:"LOG_FORMAT" default:"json" validate:"oneof=json console" desc:"Log output format: json, or console for colored human-readable lines"`
+	LogSampling LogSamplingConfig `yaml:"log_sampling"`
+	Url         string            `yaml:"url" env:"APP_URL" validate:"omitempty,url" desc:"Public base URL of the service"`
+	// MaxProcesses caps GOMAXPROCS; zero keeps the Go runtime default.
+	MaxProcesses int `yaml:"max_processes" env:"MAX_PROCESSES" default:"0" validate:"min=0" desc:"Maximum number of OS threads running Go code, 0 for one per CPU"`
+	// ConfigWatchInterval is how often WatchConfig checks the config files.
+	ConfigWatchInterval time.Duration  `yaml:"config_watch_interval" env:"CONFIG_WATCH_INTERVAL" default:"5s" validate:"min=0" desc:"How often config files are checked for changes to reload, 0 to reload on SIGHUP only"`
+	HTTP                HTTPConfig     `yaml:"http"`
+	CORS                CORSConfig     `yaml:"cors"`
+	Shutdown            ShutdownConfig `yaml:"shutdown"`
+	Tracing             TracingConfig  `yaml:"tracing"`
+	DB                  DBConfig       `yaml:"db"`
+	UserGeoApiToken     string         `yaml:"user_geo_api_token" env:"USER_GEO_API_TOKEN" secret:"true" reload:"true" example:"50787e2044f566" desc:"ipinfo.io API token; without one users are registered without the lookup"`
+	// UserRepository selects where users are stored: "db" or "memory".
+	UserRepository string `yaml:"user_repository" env:"USER_REPOSITORY" default:"db" validate:"oneof=db memory" desc:"Where users are stored: db, or memory for demos (lost on restart)"`
+	// UserBatchMaxItems caps the items of a request to /users:batch; they
+	// are written DB_BATCH_SIZE per statement.
+	UserBatchMaxItems int `yaml:"user_batch_max_items" env:"USER_BATCH_MAX_ITEMS" default:"1000" validate:"min=1" desc:"Most items a request to /users:batch may have"`
+	// UserSearchFuzzyFallback lets GET /users/search on MySQL rank every
+	// user in process when the FULLTEXT index finds nothing.
+	UserSearchFuzzyFallback bool          `yaml:"user_search_fuzzy_fallback" env:"USER_SEARCH_FUZZY_FALLBACK" default:"true" desc:"On MySQL, search all users for close matches when the FULLTEXT index finds none (reads the whole table)"`
+	Webhook                 WebhookConfig `yaml:"webhook"`
+	Events                  EventsConfig  `yaml:"events"`
+
+	secrets *secretStore
+	// opts are the sources the config was loaded from, for App.Reload.
+	opts *LoadOptions
 }
 
-func LoadConfig(ctx context.Context) *Config {
-	pathToEnv := ctx.Value(PathToEnv{})
+type HTTPConfig struct {
+	Addr string `yaml:"addr" env:"HTTP_ADDR" default:":8087" validate:"required" desc:"Address the HTTP server listens on, overridden by http --addr"`
+	// AccessLog logs a line per request, see middleware.AccessLog.
+	AccessLog bool `yaml:"access_log" env:"HTTP_ACCESS_LOG" default:"true" desc:"Log every request with method, route, status, latency, size and client IP"`
+	// MetricsPath is where Prometheus metrics are served; empty turns the
+	// endpoint off.
+	MetricsPath string `yaml:"metrics_path" env:"HTTP_METRICS_PATH" default:"/metrics" validate:"omitempty,startswith=/" desc:"Path Prometheus metrics are served on, empty to not serve them"`
+	// DebugDBStats serves the pool statistics of App.DBStats, unauthenticated,
+	// on /debug/db/stats; the metrics have them too.
+	DebugDBStats bool `yaml:"debug_db_stats" env:"HTTP_DEBUG_DB_STATS" default:"false" desc:"Serve the DB connection pool stats on GET /debug/db/stats, without authentication; the go_sql_* metrics have them too"`
 
-	var err error
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" env:"HTTP_READ_HEADER_TIMEOUT" default:"5s" validate:"min=0" desc:"How long a client may take to send the request headers, 0 for HTTP_READ_TIMEOUT"`
+	ReadTimeout       time.Duration `yaml:"read_timeout" env:"HTTP_READ_TIMEOUT" default:"30s" validate:"min=0" desc:"How long a client may take to send a whole request, 0 for no limit"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" env:"HTTP_WRITE_TIMEOUT" default:"1m" validate:"min=0" desc:"How long writing a response may take, 0 for no limit; event streams are exempt"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" env:"HTTP_IDLE_TIMEOUT" default:"2m" validate:"min=0" desc:"How long an idle keep-alive connection stays open, 0 for HTTP_READ_TIMEOUT"`
+	// RequestTimeout and RouteTimeouts set the deadline of the request
+	// context, see middleware.Timeout.
+	RequestTimeout time.Duration `yaml:"request_timeout" env:"HTTP_REQUEST_TIMEOUT" default:"30s" validate:"min=0" desc:"Deadline for handling a request, 0 for none"`
+	RouteTimeouts  []string      `yaml:"route_timeouts" env:"HTTP_ROUTE_TIMEOUTS" validate:"dive,route_timeout" desc:"Comma-separated per-route deadlines overriding HTTP_REQUEST_TIMEOUT, e.g. POST /users=5s,/users/{id:[0-9]+}=2s"`
+	MaxBodyBytes   int           `yaml:"max_body_bytes" env:"HTTP_MAX_BODY_BYTES" default:"1048576" validate:"min=0" desc:"Largest request body accepted, larger ones get 413; 0 for no limit"`
+	// Compression and CompressionMinSize configure middleware.Compress.
+	Compression        bool `yaml:"compression" env:"HTTP_COMPRESSION" default:"true" desc:"Compress responses with br, gzip or deflate as the client accepts"`
+	CompressionMinSize int  `yaml:"compression_min_size" env:"HTTP_COMPRESSION_MIN_SIZE" default:"1024" validate:"min=0" desc:"Smallest response body in bytes worth compressing"`
+	// ExportTimeout is the deadline of GET /users/export unless
+	// RouteTimeouts has one; ExportFlushInterval is how often it sends
+	// what it has so far.
+	ExportTimeout       time.Duration `yaml:"export_timeout" env:"HTTP_EXPORT_TIMEOUT" default:"10m" validate:"min=0" desc:"Deadline of GET /users/export, 0 for none"`
+	ExportFlushInterval time.Duration `yaml:"export_flush_interval" env:"HTTP_EXPORT_FLUSH_INTERVAL" default:"1s" validate:"gt=0" desc:"How often GET /users/export sends the users written so far"`
+}
 
-	if pathToEnv != nil {
-		err = godotenv.Load(pathToEnv.(string))
-	} else {
-		err = godotenv.Load()
+// RouteTimeoutMap returns RouteTimeouts keyed by route, as
+// middleware.Timeout takes them.
+func (c HTTPConfig) RouteTimeoutMap() (map[string]time.Duration, error) {
+	routes := make(map[string]time.Duration, len(c.RouteTimeouts))
+	for _, s := range c.RouteTimeouts {
+		route, d, err := parseRouteTimeout(s)
+		if err != nil {
+			return nil, fmt.Errorf("HTTP_ROUTE_TIMEOUTS: %w", err)
+		}
+		routes[route] = d
 	}
+	return routes, nil
+}
 
-	if err != nil {
-		log.Printf("Error loading .env file: %v", err)
+// parseRouteTimeout parses "[METHOD ]template=duration". The template is
+// everything up to the last "=", as patterns may contain one.
+func parseRouteTimeout(s string) (string, time.Duration, error) {
+	i := strings.LastIndex(s, "=")
+	if i < 0 {
+		return "", 0, fmt.Errorf("%q is not [METHOD ]/path=duration", s)
 	}
-
-	debug, _ := strconv.ParseBool(getEnv("DEBUG", "false"))
-	batchSize, _ := strconv.Atoi(getEnv("DB_BATCH_SIZE", "100"))
-
-	return &Config{
-		Env:   getEnv("APP_ENV", "dev"),
-		Debug: debug,
-		Url:   getEnv("APP_URL", ""),
-		DB: struct {
-			Host      string
-			Port      string
-			User      string
-			Password  string
-			Database  string
-			BatchSize int
-		}{
-			Host:      getEnv("DB_HOST", ""),
-			Port:      getEnv("DB_PORT", ""),
-			User:      getEnv("DB_USERNAME", ""),
-			Password:  getEnv("DB_PASSWORD", ""),
-			Database:  getEnv("DB_DATABASE", ""),
-			BatchSize: batchSize,
-		},
-		UserGeoApiToken: getEnv("USER_GEO_API_TOKEN", ""),
+	route, raw := strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:])
+	path := route
+	if method, rest, ok := strings.Cut(route, " "); ok && !strings.HasPrefix(route, "/") {
+		path = strings.TrimSpace(rest)
+		route = strings.ToUpper(method) + " " + path
 	}
+	if !strings.HasPrefix(path, "/") {
+		return "", 0, fmt.Errorf("%q is not [METHOD ]/path=duration", s)
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		return "", 0, fmt.Errorf("%q: %q is not a duration", s, raw)
+	}
+	return route, d, nil
+}
+
+// CORSConfig lets browser apps on other origins call the API, see
+// middleware.CORS.
+type CORSConfig struct {
+	// AllowedOrigins are exact origins, *.-wildcard subdomains of one or *
+	// for any; empty turns CORS off.
+	AllowedOrigins   []string      `yaml:"allowed_origins" env:"CORS_ALLOWED_ORIGINS" validate:"dive,cors_origin" example:"https://admin.example.com,https://*.example.com" desc:"Comma-separated origins allowed to call the API, e.g. https://admin.example.com or https://*.example.com, * for any; empty turns CORS off"`
+	AllowedMethods   []string      `yaml:"allowed_methods" env:"CORS_ALLOWED_METHODS" default:"GET,HEAD,POST,PUT,PATCH,DELETE" validate:"dive,oneof=GET HEAD POST PUT PATCH DELETE" desc:"Comma-separated methods allowed cross-origin, where a route serves them"`
+	AllowedHeaders   []string      `yaml:"allowed_headers" env:"CORS_ALLOWED_HEADERS" default:"Accept,Accept-Language,Authorization,Content-Type,Last-Event-ID,X-Client-ID,X-Request-ID" desc:"Comma-separated request headers allowed cross-origin, * for any"`
+	ExposedHeaders   []string      `yaml:"exposed_headers" env:"CORS_EXPOSED_HEADERS" default:"Location,X-Request-ID" desc:"Comma-separated response headers scripts on allowed origins may read"`
+	AllowCredentials bool          `yaml:"allow_credentials" env:"CORS_ALLOW_CREDENTIALS" default:"false" desc:"Allow cross-origin requests with cookies or HTTP authentication"`
+	MaxAge           time.Duration `yaml:"max_age" env:"CORS_MAX_AGE" default:"10m" validate:"min=0" desc:"How long browsers may cache a preflight response"`
+}
+
+// LogSamplingConfig thins out trace, debug and info messages under load.
+// Warnings and errors are always logged.
+type LogSamplingConfig struct {
+	Burst  int           `yaml:"burst" env:"LOG_SAMPLING_BURST" default:"0" validate:"min=0" desc:"Messages per LOG_SAMPLING_PERIOD logged in full before LOG_SAMPLING_EVERY applies"`
+	Period time.Duration `yaml:"period" env:"LOG_SAMPLING_PERIOD" default:"1s" validate:"min=0" desc:"Period LOG_SAMPLING_BURST applies to"`
+	Every  int           `yaml:"every" env:"LOG_SAMPLING_EVERY" default:"1" validate:"min=0" desc:"Log only every Nth trace, debug and info message, 0 or 1 to log all"`
+}
+
+// ShutdownConfig bounds how long App.Run waits for components to drain.
+type ShutdownConfig struct {
+	GracePeriod time.Duration `yaml:"grace_period" env:"SHUTDOWN_GRACE_PERIOD" default:"15s" validate:"min=0" desc:"How long each component may take to finish its work in flight after an exit signal, 0 to stop at once"`
+	Timeout     time.Duration `yaml:"timeout" env:"SHUTDOWN_TIMEOUT" default:"1m" validate:"min=0" desc:"Exit anyway when shutting down takes longer than this, 0 to wait (a second signal still forces the exit)"`
+}
+
+// TracingConfig selects where OpenTelemetry traces are exported to.
+type TracingConfig struct {
+	Exporter     string  `yaml:"exporter" env:"TRACING_EXPORTER" default:"none" validate:"oneof=none otlp stdout file" desc:"Where traces are exported to: none, otlp, stdout or file"`
+	OTLPEndpoint string  `yaml:"otlp_endpoint" env:"TRACING_OTLP_ENDPOINT" default:"localhost:4318" validate:"required_if=Exporter otlp" desc:"host:port of the OTLP/HTTP collector"`
+	OTLPInsecure bool    `yaml:"otlp_insecure" env:"TRACING_OTLP_INSECURE" default:"false" desc:"Send traces to the collector over plain HTTP"`
+	File         string  `yaml:"file" env:"TRACING_FILE" default:"traces.jsonl" validate:"required_if=Exporter file" desc:"File the file exporter appends spans to, one JSON object per line"`
+	SampleRatio  float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO" default:"1" validate:"min=0,max=1" desc:"Share of traces started here that are recorded; requests continuing a sampled trace always are"`
+}
+
+type DBConfig struct {
+	Driver    string `yaml:"driver" env:"DB_DRIVER" default:"mysql" validate:"oneof=mysql postgres sqlite" desc:"Database driver: mysql, postgres or sqlite"`
+	DSN       string `yaml:"dsn" env:"DB_DSN" secret:"true" desc:"Full DSN, overrides host, port, user, password and database"`
+	Host      string `yaml:"host" env:"DB_HOST" example:"localhost" desc:"Database host"`
+	Port      string `yaml:"port" env:"DB_PORT" example:"3306" validate:"omitempty,number" desc:"Database port"`
+	User      string `yaml:"user" env:"DB_USERNAME" example:"mysql" desc:"Database user"`
+	Password  string `yaml:"password" env:"DB_PASSWORD" secret:"true" example:"mysql" desc:"Database password"`
+	Database  string `yaml:"database" env:"DB_DATABASE" example:"user-management" desc:"Database name, or the file path (or :memory:) for sqlite"`
+	BatchSize int    `yaml:"batch_size" env:"DB_BATCH_SIZE" default:"100" validate:"min=1" desc:"Rows written per statement in batch operations"`
+
+	MaxOpenConns    int           `yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS" default:"25" validate:"min=0" reload:"true" desc:"Maximum open connections, 0 for unlimited"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS" default:"10" validate:"min=0" reload:"true" desc:"Maximum idle connections kept in the pool"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME" default:"30m" validate:"min=0" reload:"true" desc:"Close connections after this long, 0 to keep them"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME" default:"5m" validate:"min=0" reload:"true" desc:"Close connections idle for this long, 0 to keep them"`
+	DialTimeout     time.Duration `yaml:"dial_timeout" env:"DB_DIAL_TIMEOUT" default:"5s" validate:"min=0" desc:"Timeout for opening a connection"`
+	ReadTimeout     time.Duration `yaml:"read_timeout" env:"DB_READ_TIMEOUT" default:"30s" validate:"min=0" desc:"I/O read timeout (mysql)"`
+	WriteTimeout    time.Duration `yaml:"write_timeout" env:"DB_WRITE_TIMEOUT" default:"30s" validate:"min=0" desc:"I/O write timeout (mysql)"`
+	// ConnectTimeout bounds how long startup keeps retrying to reach
+	// the database. Zero means a single attempt.
+	ConnectTimeout time.Duration `yaml:"connect_timeout" env:"DB_CONNECT_TIMEOUT" default:"60s" validate:"min=0" desc:"How long startup keeps retrying while the database is not reachable yet"`
+	TLS            string        `yaml:"tls" env:"DB_TLS" default:"disable" validate:"oneof=disable require verify" desc:"TLS mode: disable, require or verify"`
+	TLSCA          string        `yaml:"tls_ca" env:"DB_TLS_CA" validate:"omitempty,file" desc:"PEM bundle to verify the server certificate with"`
+
+	ReplicaDSNs           []string      `yaml:"replica_dsns" env:"DB_REPLICA_DSNS" secret:"true" desc:"Comma-separated DSNs of read replicas for user reads, empty for primary only"`
+	ReplicaHealthInterval time.Duration `yaml:"replica_health_interval" env:"DB_REPLICA_HEALTH_INTERVAL" default:"10s" validate:"gt=0" desc:"How often read replicas are health checked"`
+	// ReplicaStickyWindow keeps a client's reads on the primary for this
+	// long after it wrote, so it reads its own writes despite lag.
+	ReplicaStickyWindow time.Duration `yaml:"replica_sticky_window" env:"DB_REPLICA_STICKY_WINDOW" default:"5s" validate:"min=0" desc:"Reads of a client (X-Client-ID or IP) stay on the primary this long after it wrote"`
+}
+
+type WebhookConfig struct {
+	MaxAttempts  int           `yaml:"max_attempts" env:"WEBHOOK_MAX_ATTEMPTS" default:"8" validate:"min=1" desc:"Delivery attempts before a delivery is marked dead"`
+	BaseBackoff  time.Duration `yaml:"base_backoff" env:"WEBHOOK_BASE_BACKOFF" default:"30s" validate:"gt=0" desc:"Delay before the first retry, doubled on every further retry"`
+	MaxBackoff   time.Duration `yaml:"max_backoff" env:"WEBHOOK_MAX_BACKOFF" default:"6h" validate:"gtefield=BaseBackoff" desc:"Upper bound of the retry delay"`
+	PollInterval time.Duration `yaml:"poll_interval" env:"WEBHOOK_POLL_INTERVAL" default:"5s" validate:"gt=0" desc:"How often due deliveries are looked up"`
+}
+
+type EventsConfig struct {
+	ReplayBuffer int           `yaml:"replay_buffer" env:"EVENTS_REPLAY_BUFFER" default:"1000" validate:"min=0" desc:"Events kept for clients resuming the change feed with Last-Event-ID"`
+	Heartbeat    time.Duration `yaml:"heartbeat" env:"EVENTS_HEARTBEAT" default:"15s" validate:"gt=0" desc:"Interval of keep-alive comments on the change feed"`
 }
 
-func getEnv(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
 	}
-	return value
+	return out
 }
@@ -0,0 +1,514 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// LoadOptions tells LoadConfig where to find configuration besides the
+// process environment.
+type LoadOptions struct {
+	// Env selects the config.<env>.yaml overrides. When empty, APP_ENV from
+	// the environment, .env or the base file is used, then "dev".
+	Env string
+	// Dir holds config.yaml (or .yml/.toml) and config.<env>.yaml.
+	// Defaults to the working directory.
+	Dir string
+	// EnvFile is the dotenv file, ".env" by default. A PathToEnv value in
+	// the context passed to LoadConfig takes precedence.
+	EnvFile string
+	// Flags are command line overrides keyed by environment variable name,
+	// e.g. {"DB_HOST": "db.internal"}.
+	Flags map[string]string
+	// SecretProviders are asked for secret settings before the built-in
+	// FileSecretProvider, which reads <KEY>_FILE from any of the layers.
+	SecretProviders []SecretProvider
+}
+
+// LoadConfig builds the configuration from these layers, each overriding
+// the ones before it:
+//
+//  1. defaults from the Config struct tags
+//  2. the base file config.yaml, config.yml or config.toml
+//  3. config.<env>.yaml (or .yml/.toml)
+//  4. the .env file
+//  5. environment variables
+//  6. command line flags (LoadOptions.Flags)
+//  7. for secrets, LoadOptions.SecretProviders and then the file named by
+//     <KEY>_FILE (or <key>_file in config files)
+//
+// Missing files are skipped. Values that cannot be parsed, unknown keys in
+// config files and unknown flags are all reported in a single error.
+func LoadConfig(ctx context.Context, opts LoadOptions) (*Config, error) {
+	if path, ok := ctx.Value(PathToEnv{}).(string); ok {
+		opts.EnvFile = path
+	}
+	if opts.EnvFile == "" {
+		opts.EnvFile = ".env"
+	}
+
+	fields := configFields(reflect.TypeOf(Config{}), nil, nil)
+	var errs []error
+
+	base, err := readConfigFile(opts.Dir, "config")
+	if err != nil {
+		errs = append(errs, err)
+	}
+	dotenv, err := readDotenv(opts.EnvFile)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	environ := &envSource{name: "environment", lookup: os.LookupEnv}
+	flags := mapSource("command line", opts.Flags)
+	if opts.Env != "" {
+		flags.values = withValue(flags.values, "APP_ENV", opts.Env)
+	}
+
+	envName := resolveEnv(fields, present(base, dotenv, environ, flags))
+	overrides, err := readConfigFile(opts.Dir, "config."+envName)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if overrides != nil {
+		if _, ok := overrides.values["env"]; ok {
+			errs = append(errs, fmt.Errorf("%s: env cannot be set in the file it selects", overrides.name))
+		}
+	}
+
+	cfg := &Config{opts: &opts}
+	v := reflect.ValueOf(cfg).Elem()
+	for _, f := range fields {
+		if f.def != "" {
+			if err := setField(v.FieldByIndex(f.index), f.def); err != nil {
+				errs = append(errs, fmt.Errorf("%s: bad default: %w", f.env, err))
+			}
+		}
+	}
+	sources := present(base, overrides, dotenv, environ, flags)
+	for _, src := range sources {
+		errs = append(errs, src.unknown(fields)...)
+		for _, f := range fields {
+			raw, ok := src.get(f)
+			if !ok {
+				continue
+			}
+			if err := setField(v.FieldByIndex(f.index), raw); err != nil {
+				errs = append(errs, fmt.Errorf("%s (%s) from %s: %w", f.env, f.key(), src.sourceName(), err))
+			}
+		}
+	}
+
+	cfg.secrets = newSecretStore(append(opts.SecretProviders, &FileSecretProvider{
+		Lookup: secretFileLookup(fields, sources),
+	}))
+	for _, f := range fields {
+		if !f.secret {
+			continue
+		}
+		field := v.FieldByIndex(f.index)
+		value, ok, err := cfg.secrets.lookup(f.env)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if ok {
+			if err := setField(field, value); err != nil {
+				errs = append(errs, fmt.Errorf("%s from secret provider: %w", f.env, err))
+			}
+		}
+		cfg.secrets.remember(secretValue(field))
+		if field.Kind() == reflect.Slice {
+			cfg.secrets.remember(field.Interface().([]string)...)
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+	return cfg, nil
+}
+
+// secretFileLookup resolves <KEY>_FILE settings for FileSecretProvider from
+// the layered sources, highest precedence first.
+func secretFileLookup(fields []configField, sources []configSource) func(string) (string, bool) {
+	fileFields := map[string]configField{}
+	for _, f := range fields {
+		if f.secret {
+			ff := fileField(f)
+			fileFields[ff.env] = ff
+		}
+	}
+	return func(key string) (string, bool) {
+		f, ok := fileFields[key]
+		if !ok {
+			return "", false
+		}
+		for i := len(sources) - 1; i >= 0; i-- {
+			if raw, ok := sources[i].get(f); ok {
+				return fmt.Sprint(raw), true
+			}
+		}
+		return "", false
+	}
+}
+
+// fileField is the <KEY>_FILE setting of the secret f.
+func fileField(f configField) configField {
+	path := append([]string{}, f.path...)
+	path[len(path)-1] += "_file"
+	return configField{path: path, env: f.env + "_FILE", typ: reflect.TypeOf("")}
+}
+
+// ParseFlagOverrides turns repeated KEY=VALUE command line arguments into
+// LoadOptions.Flags.
+func ParseFlagOverrides(args []string) (map[string]string, error) {
+	out := make(map[string]string, len(args))
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid setting %q, expected KEY=VALUE", arg)
+		}
+		out[key] = value
+	}
+	return out, nil
+}
+
+// configField is a single setting of Config.
+type configField struct {
+	path    []string // keys in config files
+	env     string
+	def     string
+	desc    string
+	rules   string
+	example string
+	secret  bool
+	reload  bool
+	typ     reflect.Type
+	index   []int
+}
+
+func (f configField) key() string {
+	return strings.Join(f.path, ".")
+}
+
+func configFields(t reflect.Type, path []string, index []int) []configField {
+	var out []configField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		key := sf.Tag.Get("yaml")
+		if key == "" || key == "-" {
+			continue
+		}
+		p := append(append([]string{}, path...), key)
+		idx := append(append([]int{}, index...), i)
+
+		env, ok := sf.Tag.Lookup("env")
+		if !ok && sf.Type.Kind() == reflect.Struct {
+			out = append(out, configFields(sf.Type, p, idx)...)
+			continue
+		}
+		out = append(out, configField{
+			path:    p,
+			env:     env,
+			def:     sf.Tag.Get("default"),
+			desc:    sf.Tag.Get("desc"),
+			rules:   sf.Tag.Get("validate"),
+			example: sf.Tag.Get("example"),
+			secret:  sf.Tag.Get("secret") == "true",
+			reload:  sf.Tag.Get("reload") == "true",
+			typ:     sf.Type,
+			index:   idx,
+		})
+	}
+	return out
+}
+
+// withFileFields adds the <KEY>_FILE setting of every secret to fields.
+func withFileFields(fields []configField) []configField {
+	out := append([]configField{}, fields...)
+	for _, f := range fields {
+		if f.secret {
+			out = append(out, fileField(f))
+		}
+	}
+	return out
+}
+
+type configSource interface {
+	sourceName() string
+	get(f configField) (any, bool)
+	unknown(fields []configField) []error
+}
+
+// resolveEnv decides APP_ENV before config.<env>.yaml can be read, from the
+// layers that do not depend on it.
+func resolveEnv(fields []configField, sources []configSource) string {
+	name := "dev"
+	for _, f := range fields {
+		if f.env != "APP_ENV" {
+			continue
+		}
+		for _, src := range sources {
+			if raw, ok := src.get(f); ok {
+				if s := fmt.Sprint(raw); s != "" {
+					name = s
+				}
+			}
+		}
+	}
+	return name
+}
+
+// present drops the sources whose file does not exist.
+func present(sources ...configSource) []configSource {
+	out := make([]configSource, 0, len(sources))
+	for _, src := range sources {
+		switch s := src.(type) {
+		case *fileSource:
+			if s == nil {
+				continue
+			}
+		case *envSource:
+			if s == nil {
+				continue
+			}
+		}
+		out = append(out, src)
+	}
+	return out
+}
+
+// fileSource is a parsed YAML or TOML config file.
+type fileSource struct {
+	name   string
+	values map[string]any
+}
+
+var configExtensions = []string{".yaml", ".yml", ".toml"}
+
+// readConfigFile reads the first of <dir>/<base>.yaml, .yml or .toml that
+// exists. It returns nil when there is none.
+func readConfigFile(dir, base string) (*fileSource, error) {
+	for _, ext := range configExtensions {
+		path := filepath.Join(dir, base+ext)
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		values := map[string]any{}
+		if ext == ".toml" {
+			err = toml.Unmarshal(data, &values)
+		} else {
+			err = yaml.Unmarshal(data, &values)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return &fileSource{name: path, values: values}, nil
+	}
+	return nil, nil
+}
+
+func (s *fileSource) sourceName() string {
+	return s.name
+}
+
+func (s *fileSource) get(f configField) (any, bool) {
+	var cur any = s.values
+	for _, key := range f.path {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return cur, cur != nil
+}
+
+func (s *fileSource) unknown(fields []configField) []error {
+	known := map[string]bool{}
+	for _, f := range withFileFields(fields) {
+		for i := range f.path {
+			known[strings.Join(f.path[:i+1], ".")] = i < len(f.path)-1
+		}
+	}
+
+	var errs []error
+	var walk func(prefix string, m map[string]any)
+	walk = func(prefix string, m map[string]any) {
+		keys := make([]string, 0, len(m))
+		for key := range m {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			value := m[key]
+			full := prefix + key
+			section, ok := known[full]
+			if !ok {
+				errs = append(errs, fmt.Errorf("%s: unknown key %q", s.name, full))
+				continue
+			}
+			if sub, isMap := value.(map[string]any); isMap && section {
+				walk(full+".", sub)
+			}
+		}
+	}
+	walk("", s.values)
+	return errs
+}
+
+// envSource reads settings by their environment variable names, from the
+// process environment (lookup) or from values. Empty values count as unset.
+type envSource struct {
+	name   string
+	lookup func(key string) (string, bool)
+	values map[string]string
+	// strict sources report keys that are not settings.
+	strict bool
+}
+
+func mapSource(name string, values map[string]string) *envSource {
+	return &envSource{name: name, values: values, strict: true}
+}
+
+func readDotenv(path string) (*envSource, error) {
+	values, err := godotenv.Read(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &envSource{name: path, values: values}, nil
+}
+
+func (s *envSource) sourceName() string {
+	return s.name
+}
+
+// get returns the value of f if it is set, even to an empty string for a
+// string or list setting, so that e.g. HTTP_METRICS_PATH= turns metrics
+// off. Other settings have no empty value, so empty leaves them unset.
+func (s *envSource) get(f configField) (any, bool) {
+	if f.env == "" {
+		return nil, false
+	}
+	var v string
+	var ok bool
+	if s.lookup != nil {
+		v, ok = s.lookup(f.env)
+	} else {
+		v, ok = s.values[f.env]
+	}
+	if v == "" && f.typ.Kind() != reflect.String && f.typ.Kind() != reflect.Slice {
+		return nil, false
+	}
+	return v, ok
+}
+
+func (s *envSource) unknown(fields []configField) []error {
+	if !s.strict {
+		return nil
+	}
+	known := map[string]bool{}
+	for _, f := range withFileFields(fields) {
+		known[f.env] = true
+	}
+	var errs []error
+	keys := make([]string, 0, len(s.values))
+	for key := range s.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !known[key] {
+			errs = append(errs, fmt.Errorf("%s: unknown setting %q", s.name, key))
+		}
+	}
+	return errs
+}
+
+func withValue(m map[string]string, key, value string) map[string]string {
+	out := make(map[string]string, len(m)+1)
+	for k, v := range m {
+		out[k] = v
+	}
+	out[key] = value
+	return out
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setField parses raw, a string from the environment or a value decoded
+// from a config file, into v.
+func setField(v reflect.Value, raw any) error {
+	if _, ok := raw.(map[string]any); ok {
+		return errors.New("expected a value, got a section")
+	}
+	if list, ok := raw.([]any); ok {
+		if v.Kind() != reflect.Slice {
+			return errors.New("expected a single value, got a list")
+		}
+		items := make([]string, 0, len(list))
+		for _, item := range list {
+			items = append(items, fmt.Sprint(item))
+		}
+		v.Set(reflect.ValueOf(items))
+		return nil
+	}
+
+	s := fmt.Sprint(raw)
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration, use e.g. 500ms, 30s or 5m", s)
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(s)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean, use true or false", s)
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", s)
+		}
+		v.SetInt(int64(n))
+	case v.Kind() == reflect.Float64:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", s)
+		}
+		v.SetFloat(n)
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		v.Set(reflect.ValueOf(splitList(s)))
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
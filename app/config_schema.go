@@ -0,0 +1,318 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// JSONSchema is the subset of JSON Schema used to describe Config. x-env
+// names the environment variable of a setting and x-reloadable marks the
+// ones App.Reload changes at runtime.
+type JSONSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Type                 string                 `json:"type"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	AdditionalProperties *bool                  `json:"additionalProperties,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+	Minimum              *int64                 `json:"minimum,omitempty"`
+	ExclusiveMinimum     *int64                 `json:"exclusiveMinimum,omitempty"`
+	Default              any                    `json:"default,omitempty"`
+	Examples             []any                  `json:"examples,omitempty"`
+	WriteOnly            bool                   `json:"writeOnly,omitempty"`
+	Env                  string                 `json:"x-env,omitempty"`
+	Reloadable           bool                   `json:"x-reloadable,omitempty"`
+
+	// order lists Properties in Config's declaration order.
+	order []string
+}
+
+// durationPattern matches what time.ParseDuration accepts.
+const durationPattern = `^-?(0|([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+)$`
+
+const redacted = "[redacted]"
+
+// ConfigSchema describes every setting of Config: its config file key,
+// environment variable, type, default and the rules Validate enforces.
+func ConfigSchema() *JSONSchema {
+	root := objectSchema()
+	root.Schema = "https://json-schema.org/draft/2020-12/schema"
+	root.Title = "user-management configuration"
+
+	for _, f := range configFields(reflect.TypeOf(Config{}), nil, nil) {
+		node := root
+		for _, key := range f.path[:len(f.path)-1] {
+			if node.Properties[key] == nil {
+				node.add(key, objectSchema())
+			}
+			node = node.Properties[key]
+		}
+		node.add(f.path[len(f.path)-1], fieldSchema(f))
+	}
+	return root
+}
+
+func objectSchema() *JSONSchema {
+	no := false
+	return &JSONSchema{Type: "object", Properties: map[string]*JSONSchema{}, AdditionalProperties: &no}
+}
+
+func (s *JSONSchema) add(key string, prop *JSONSchema) {
+	s.Properties[key] = prop
+	s.order = append(s.order, key)
+}
+
+func fieldSchema(f configField) *JSONSchema {
+	s := &JSONSchema{Description: f.desc, WriteOnly: f.secret, Env: f.env, Reloadable: f.reload}
+
+	switch {
+	case f.typ == durationType:
+		s.Type = "string"
+		s.Pattern = durationPattern
+	case f.typ.Kind() == reflect.Bool:
+		s.Type = "boolean"
+	case f.typ.Kind() == reflect.Int:
+		s.Type = "integer"
+	case f.typ.Kind() == reflect.Float64:
+		s.Type = "number"
+	case f.typ.Kind() == reflect.Slice:
+		s.Type = "array"
+		s.Items = &JSONSchema{Type: "string"}
+	default:
+		s.Type = "string"
+	}
+
+	if f.def != "" {
+		s.Default = schemaValue(s.Type, f.def)
+	}
+	if f.example != "" {
+		s.Examples = []any{schemaValue(s.Type, f.example)}
+	}
+
+	// Rules after dive are about the items of a list.
+	target := s
+	for _, rule := range strings.Split(f.rules, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "dive":
+			if s.Items != nil {
+				target = s.Items
+			}
+		case "oneof":
+			target.Enum = strings.Fields(param)
+		case "url":
+			target.Format = "uri"
+		case "min", "gt":
+			n, err := strconv.ParseInt(param, 10, 64)
+			if err != nil || s.Type != "integer" {
+				continue
+			}
+			if name == "min" {
+				s.Minimum = &n
+			} else {
+				s.ExclusiveMinimum = &n
+			}
+		}
+	}
+	return s
+}
+
+func schemaValue(typ, s string) any {
+	switch typ {
+	case "boolean":
+		b, _ := strconv.ParseBool(s)
+		return b
+	case "integer":
+		n, _ := strconv.Atoi(s)
+		return n
+	case "number":
+		n, _ := strconv.ParseFloat(s, 64)
+		return n
+	case "array":
+		return splitList(s)
+	}
+	return s
+}
+
+// WriteConfigSchema writes s as indented JSON.
+func WriteConfigSchema(w io.Writer, s *JSONSchema) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(s)
+}
+
+// schemaSetting is a leaf of a config schema with its dotted file key.
+type schemaSetting struct {
+	key string
+	*JSONSchema
+}
+
+// schemaSections flattens s into its sections, top-level settings first,
+// in declaration order.
+func schemaSections(s *JSONSchema) [][]schemaSetting {
+	var sections [][]schemaSetting
+	var walk func(prefix string, node *JSONSchema)
+	walk = func(prefix string, node *JSONSchema) {
+		keys := node.order
+		if len(keys) == 0 {
+			for key := range node.Properties {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+		}
+
+		var leaves []schemaSetting
+		var subs []string
+		for _, key := range keys {
+			if node.Properties[key].Type == "object" {
+				subs = append(subs, key)
+				continue
+			}
+			leaves = append(leaves, schemaSetting{key: prefix + key, JSONSchema: node.Properties[key]})
+		}
+		if len(leaves) > 0 {
+			sections = append(sections, leaves)
+		}
+		for _, key := range subs {
+			walk(prefix+key+".", node.Properties[key])
+		}
+	}
+	walk("", s)
+	return sections
+}
+
+func formatSchemaValue(v any) string {
+	if list, ok := v.([]string); ok {
+		return strings.Join(list, ",")
+	}
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// WriteEnvExample writes a .env file template from s, one commented
+// variable per setting, filled with its example or default value.
+func WriteEnvExample(w io.Writer, s *JSONSchema) error {
+	var b strings.Builder
+	b.WriteString("# Generated by `go run cmd/main.go config env-example`, do not edit.\n")
+	for _, section := range schemaSections(s) {
+		b.WriteString("\n")
+		for _, setting := range section {
+			if setting.Env == "" {
+				continue
+			}
+			value := setting.Default
+			if len(setting.Examples) > 0 {
+				value = setting.Examples[0]
+			}
+			fmt.Fprintf(&b, "# %s\n%s=%s\n", setting.Description, setting.Env, formatSchemaValue(value))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteConfigDocs writes a Markdown reference of every setting in s.
+func WriteConfigDocs(w io.Writer, s *JSONSchema) error {
+	var b strings.Builder
+	b.WriteString("# Configuration\n\n")
+	b.WriteString("<!-- Generated by `go run cmd/main.go config docs`, do not edit. -->\n\n")
+	b.WriteString("Settings are layered, later sources win: defaults, `config.yaml` (or `.toml`),\n")
+	b.WriteString("`config.<env>.yaml`, `.env`, environment variables, `--set KEY=VALUE`.\n")
+	b.WriteString("A variable set to an empty value clears a string or list setting, e.g.\n")
+	b.WriteString("`HTTP_METRICS_PATH=`; for other types it is ignored.\n")
+	b.WriteString("The JSON Schema is in `docs/config.schema.json` (`go run cmd/main.go config schema`).\n\n")
+	b.WriteString("Secrets can instead be read from a file named by `<VARIABLE>_FILE` (`<key>_file`\n")
+	b.WriteString("in config files), e.g. `DB_PASSWORD_FILE=/run/secrets/db`; the file is re-read when it changes.\n\n")
+	b.WriteString("Settings marked reloadable are applied on SIGHUP or when a config file changes;\n")
+	b.WriteString("changes to the others are logged and need a restart.\n\n")
+	b.WriteString("| Variable | Key | Type | Default | Description |\n")
+	b.WriteString("|---|---|---|---|---|\n")
+	for _, section := range schemaSections(s) {
+		for _, setting := range section {
+			typ := setting.Type
+			switch {
+			case setting.Pattern == durationPattern:
+				typ = "duration"
+			case len(setting.Enum) > 0:
+				typ = strings.Join(setting.Enum, " \\| ")
+			case typ == "array":
+				typ = "list"
+			}
+			desc := setting.Description
+			if setting.WriteOnly {
+				desc += " (secret)"
+			}
+			if setting.Reloadable {
+				desc += " (reloadable)"
+			}
+			def := formatSchemaValue(setting.Default)
+			if def != "" {
+				def = "`" + def + "`"
+			}
+			fmt.Fprintf(&b, "| `%s` | `%s` | %s | %s | %s |\n", setting.Env, setting.key, typ, def, desc)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteConfig prints cfg as YAML in the config file layout, or as KEY=VALUE
+// lines when format is "env". Secrets are redacted.
+func WriteConfig(w io.Writer, cfg *Config, format string) error {
+	v := reflect.ValueOf(cfg).Elem()
+	fields := configFields(v.Type(), nil, nil)
+
+	switch format {
+	case "env":
+		for _, f := range fields {
+			if _, err := fmt.Fprintf(w, "%s=%s\n", f.env, formatSchemaValue(printValue(f, v.FieldByIndex(f.index)))); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "yaml", "":
+		root := map[string]any{}
+		for _, f := range fields {
+			node := root
+			for _, key := range f.path[:len(f.path)-1] {
+				if node[key] == nil {
+					node[key] = map[string]any{}
+				}
+				node = node[key].(map[string]any)
+			}
+			node[f.path[len(f.path)-1]] = printValue(f, v.FieldByIndex(f.index))
+		}
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(root); err != nil {
+			return err
+		}
+		return enc.Close()
+	}
+	return fmt.Errorf("unknown format %q, use yaml or env", format)
+}
+
+func printValue(f configField, v reflect.Value) any {
+	if f.secret && !v.IsZero() {
+		return redacted
+	}
+	if d, ok := v.Interface().(time.Duration); ok {
+		return d.String()
+	}
+	return v.Interface()
+}
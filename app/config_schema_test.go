@@ -0,0 +1,65 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigSchema(t *testing.T) {
+	s := ConfigSchema()
+
+	batch := s.Properties["db"].Properties["batch_size"]
+	require.NotNil(t, batch)
+	assert.Equal(t, "integer", batch.Type)
+	assert.Equal(t, "DB_BATCH_SIZE", batch.Env)
+	assert.Equal(t, 100, batch.Default)
+	assert.EqualValues(t, 1, *batch.Minimum)
+
+	driver := s.Properties["db"].Properties["driver"]
+	assert.Equal(t, []string{"mysql", "postgres", "sqlite"}, driver.Enum)
+	assert.True(t, s.Properties["db"].Properties["password"].WriteOnly)
+	assert.Equal(t, durationPattern, s.Properties["webhook"].Properties["max_backoff"].Pattern)
+
+	methods := s.Properties["cors"].Properties["allowed_methods"]
+	assert.Equal(t, "array", methods.Type)
+	assert.Nil(t, methods.Enum)
+	assert.Contains(t, methods.Items.Enum, "PATCH", "dive rules are about the items")
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteConfigSchema(&buf, s))
+	assert.True(t, json.Valid(buf.Bytes()))
+}
+
+// The checked-in files are generated with make config_docs.
+func TestGeneratedConfigFilesUpToDate(t *testing.T) {
+	for file, write := range map[string]func(*bytes.Buffer) error{
+		"../.env.example":            func(b *bytes.Buffer) error { return WriteEnvExample(b, ConfigSchema()) },
+		"../docs/config.md":          func(b *bytes.Buffer) error { return WriteConfigDocs(b, ConfigSchema()) },
+		"../docs/config.schema.json": func(b *bytes.Buffer) error { return WriteConfigSchema(b, ConfigSchema()) },
+	} {
+		want, err := os.ReadFile(file)
+		require.NoError(t, err)
+		var got bytes.Buffer
+		require.NoError(t, write(&got))
+		assert.Equal(t, string(want), got.String(), "%s is stale, run make config_docs", file)
+	}
+}
+
+func TestWriteConfigRedactsSecrets(t *testing.T) {
+	cfg := &Config{UserGeoApiToken: "token"}
+	cfg.DB.Password = "hunter2"
+	cfg.DB.ReplicaDSNs = []string{"u:hunter2@tcp(r1)/db"}
+
+	for _, format := range []string{"yaml", "env"} {
+		var buf bytes.Buffer
+		require.NoError(t, WriteConfig(&buf, cfg, format))
+		assert.NotContains(t, buf.String(), "hunter2")
+		assert.NotContains(t, buf.String(), "token\n")
+		assert.Contains(t, buf.String(), redacted)
+	}
+}
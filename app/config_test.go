@@ -0,0 +1,213 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600))
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	dir := t.TempDir()
+
+	cfg, err := LoadConfig(context.Background(), LoadOptions{Dir: dir, EnvFile: filepath.Join(dir, ".env")})
+	require.NoError(t, err)
+	assert.Equal(t, "dev", cfg.Env)
+	assert.Equal(t, DriverMySQL, cfg.DB.Driver)
+	assert.Equal(t, 100, cfg.DB.BatchSize)
+	assert.Equal(t, 30*time.Minute, cfg.DB.ConnMaxLifetime)
+	assert.Equal(t, 8, cfg.Webhook.MaxAttempts)
+	assert.Equal(t, "db", cfg.UserRepository)
+}
+
+func TestLoadConfigLayers(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "config.yaml", `
+env: staging
+db:
+  host: base-host
+  port: "3306"
+  batch_size: 10
+  replica_dsns: [r1, r2]
+webhook:
+  max_attempts: 3
+`)
+	writeFile(t, dir, "config.staging.yaml", `
+db:
+  host: staging-host
+  database: staging
+`)
+	writeFile(t, dir, ".env", "DB_DATABASE=from-dotenv\nDB_USERNAME=dotenv-user\n")
+	t.Setenv("DB_USERNAME", "env-user")
+
+	cfg, err := LoadConfig(context.Background(), LoadOptions{
+		Dir:     dir,
+		EnvFile: filepath.Join(dir, ".env"),
+		Flags:   map[string]string{"DB_PORT": "3307"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "staging", cfg.Env)
+	assert.Equal(t, "staging-host", cfg.DB.Host)
+	assert.Equal(t, "from-dotenv", cfg.DB.Database)
+	assert.Equal(t, "env-user", cfg.DB.User)
+	assert.Equal(t, "3307", cfg.DB.Port)
+	assert.Equal(t, 10, cfg.DB.BatchSize)
+	assert.Equal(t, []string{"r1", "r2"}, cfg.DB.ReplicaDSNs)
+	assert.Equal(t, 3, cfg.Webhook.MaxAttempts)
+}
+
+func TestLoadConfigEmptyEnvClearsSetting(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "config.yaml", "db:\n  host: base-host\ncors:\n  allowed_origins: [https://admin.example.com]\n")
+	writeFile(t, dir, ".env", "DB_HOST=\nDB_BATCH_SIZE=\n")
+	t.Setenv("HTTP_METRICS_PATH", "")
+	t.Setenv("CORS_ALLOWED_ORIGINS", "")
+
+	cfg, err := LoadConfig(context.Background(), LoadOptions{Dir: dir, EnvFile: filepath.Join(dir, ".env")})
+	require.NoError(t, err)
+	assert.Empty(t, cfg.HTTP.MetricsPath)
+	assert.Empty(t, cfg.CORS.AllowedOrigins)
+	assert.Empty(t, cfg.DB.Host)
+	// Settings with no empty value keep theirs.
+	assert.Equal(t, 100, cfg.DB.BatchSize)
+}
+
+func TestLoadConfigEnvFlagSelectsOverrides(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "config.toml", "env = \"staging\"\n[db]\nhost = \"base-host\"\n")
+	writeFile(t, dir, "config.prod.yaml", "db:\n  host: prod-host\n")
+
+	cfg, err := LoadConfig(context.Background(), LoadOptions{Dir: dir, EnvFile: filepath.Join(dir, ".env"), Env: "prod"})
+	require.NoError(t, err)
+	assert.Equal(t, "prod", cfg.Env)
+	assert.Equal(t, "prod-host", cfg.DB.Host)
+}
+
+func TestLoadConfigReportsAllErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "config.yaml", "db:\n  hots: x\n  dial_timeout: 5\n")
+	writeFile(t, dir, ".env", "DB_BATCH_SIZE=fifty\nTRACING_SAMPLE_RATIO=half\n")
+	t.Setenv("DEBUG", "yes please")
+
+	_, err := LoadConfig(context.Background(), LoadOptions{
+		Dir:     dir,
+		EnvFile: filepath.Join(dir, ".env"),
+		Flags:   map[string]string{"DB_HOTS": "y"},
+	})
+	require.Error(t, err)
+	msg := err.Error()
+	assert.Contains(t, msg, `unknown key "db.hots"`)
+	assert.Contains(t, msg, `DB_DIAL_TIMEOUT (db.dial_timeout) from `+filepath.Join(dir, "config.yaml")+`: "5" is not a duration`)
+	assert.Contains(t, msg, `DB_BATCH_SIZE (db.batch_size) from `+filepath.Join(dir, ".env")+`: "fifty" is not a whole number`)
+	assert.Contains(t, msg, `TRACING_SAMPLE_RATIO (tracing.sample_ratio) from `+filepath.Join(dir, ".env")+`: "half" is not a number`)
+	assert.Contains(t, msg, `DEBUG (debug) from environment: "yes please" is not a boolean`)
+	assert.Contains(t, msg, `command line: unknown setting "DB_HOTS"`)
+}
+
+func TestParseFlagOverrides(t *testing.T) {
+	flags, err := ParseFlagOverrides([]string{"DB_HOST=db", "APP_URL=http://x?a=b"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"DB_HOST": "db", "APP_URL": "http://x?a=b"}, flags)
+
+	_, err = ParseFlagOverrides([]string{"DB_HOST"})
+	assert.Error(t, err)
+}
+
+func validConfig(t *testing.T) *Config {
+	t.Helper()
+	dir := t.TempDir()
+	cfg, err := LoadConfig(context.Background(), LoadOptions{
+		Dir:     dir,
+		EnvFile: filepath.Join(dir, ".env"),
+		Flags:   map[string]string{"DB_HOST": "localhost", "DB_DATABASE": "users"},
+	})
+	require.NoError(t, err)
+	return cfg
+}
+
+func TestConfigValidate(t *testing.T) {
+	cfg := validConfig(t)
+	require.NoError(t, cfg.Validate())
+
+	cfg.DB.Driver = "oracle"
+	cfg.DB.Host = ""
+	cfg.Webhook.MaxBackoff = time.Second
+	cfg.Events.Heartbeat = 0
+	cfg.UserRepository = "files"
+	cfg.HTTP.MetricsPath = "metrics"
+	cfg.Tracing.Exporter = TracingFile
+	cfg.Tracing.File = ""
+	cfg.Tracing.SampleRatio = 1.5
+	cfg.HTTP.RouteTimeouts = []string{"POST /users=5s", "users=1s"}
+	cfg.CORS.AllowedOrigins = []string{"https://*.example.com", "admin.example.com", "*"}
+	cfg.CORS.AllowedMethods = []string{"GET", "TRACE"}
+
+	err := cfg.Validate()
+	require.Error(t, err)
+	msg := err.Error()
+	assert.Contains(t, msg, `DB_DRIVER: must be one of mysql, postgres, sqlite, got "oracle"`)
+	assert.Contains(t, msg, `USER_REPOSITORY: must be one of db, memory, got "files"`)
+	assert.Contains(t, msg, `WEBHOOK_MAX_BACKOFF: must not be less than WEBHOOK_BASE_BACKOFF`)
+	assert.Contains(t, msg, `EVENTS_HEARTBEAT: must be greater than 0`)
+	assert.Contains(t, msg, `HTTP_METRICS_PATH: must start with "/", got "metrics"`)
+	assert.Contains(t, msg, `TRACING_FILE: is required when TRACING_EXPORTER is file`)
+	assert.Contains(t, msg, `TRACING_SAMPLE_RATIO: must be at most 1, got 1.5`)
+	assert.Contains(t, msg, `HTTP_ROUTE_TIMEOUTS[1]: must be [METHOD ]/path=duration, e.g. POST /users=5s, got "users=1s"`)
+	assert.NotContains(t, msg, "HTTP_ROUTE_TIMEOUTS[0]")
+	assert.Contains(t, msg, `CORS_ALLOWED_ORIGINS[1]: must be *, or scheme://host[:port] with an optional *. before the host, got "admin.example.com"`)
+	assert.NotContains(t, msg, "CORS_ALLOWED_ORIGINS[0]")
+	assert.NotContains(t, msg, "CORS_ALLOWED_ORIGINS[2]")
+	assert.Contains(t, msg, `CORS_ALLOWED_METHODS[1]: must be one of GET, HEAD, POST, PUT, PATCH, DELETE, got "TRACE"`)
+}
+
+func TestHTTPConfigRouteTimeoutMap(t *testing.T) {
+	cfg, err := LoadConfig(context.Background(), LoadOptions{
+		Dir:     t.TempDir(),
+		EnvFile: filepath.Join(t.TempDir(), ".env"),
+		Flags: map[string]string{
+			"HTTP_ROUTE_TIMEOUTS": "post /users=5s, /users/{id:[0-9]+}=2s,GET /users/export=0",
+		},
+	})
+	require.NoError(t, err)
+
+	routes, err := cfg.HTTP.RouteTimeoutMap()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]time.Duration{
+		"POST /users":        5 * time.Second,
+		"/users/{id:[0-9]+}": 2 * time.Second,
+		"GET /users/export":  0,
+	}, routes)
+
+	for _, bad := range []string{"/users", "/users=soon", "/users=-1s", "POST users=1s"} {
+		_, err := HTTPConfig{RouteTimeouts: []string{bad}}.RouteTimeoutMap()
+		assert.ErrorContains(t, err, "HTTP_ROUTE_TIMEOUTS", bad)
+	}
+}
+
+func TestConfigValidateConnectionSettings(t *testing.T) {
+	cfg := validConfig(t)
+	cfg.DB.Host = ""
+	cfg.DB.Database = ""
+
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "DB_HOST: is required unless DB_DSN is set")
+	assert.Contains(t, err.Error(), "DB_DATABASE: is required unless DB_DSN is set")
+
+	cfg.DB.DSN = "mysql:mysql@tcp(db:3306)/users"
+	assert.NoError(t, cfg.Validate())
+
+	cfg.DB.DSN = ""
+	cfg.DB.Driver = DriverSQLite
+	cfg.DB.Database = ":memory:"
+	assert.NoError(t, cfg.Validate())
+}
@@ -0,0 +1,138 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+var configValidator = newConfigValidator()
+
+func newConfigValidator() *validator.Validate {
+	v := validator.New()
+	// Report settings by the environment variable users know them by.
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		if env := f.Tag.Get("env"); env != "" {
+			return env
+		}
+		return f.Tag.Get("yaml")
+	})
+	v.RegisterStructValidation(validateDBConfig, DBConfig{})
+	_ = v.RegisterValidation("route_timeout", func(fl validator.FieldLevel) bool {
+		_, _, err := parseRouteTimeout(fl.Field().String())
+		return err == nil
+	})
+	_ = v.RegisterValidation("cors_origin", func(fl validator.FieldLevel) bool {
+		return validCORSOrigin(fl.Field().String())
+	})
+	return v
+}
+
+// validateDBConfig checks the connection settings that DSN needs when no
+// full DB_DSN is given.
+func validateDBConfig(sl validator.StructLevel) {
+	db := sl.Current().Interface().(DBConfig)
+	if db.DSN != "" {
+		return
+	}
+	switch db.Driver {
+	case DriverMySQL, DriverPostgres:
+		if db.Host == "" {
+			sl.ReportError(db.Host, "DB_HOST", "Host", "required_without_dsn", "")
+		}
+		if db.Database == "" {
+			sl.ReportError(db.Database, "DB_DATABASE", "Database", "required_without_dsn", "")
+		}
+	case DriverSQLite:
+		if db.Database == "" {
+			sl.ReportError(db.Database, "DB_DATABASE", "Database", "required_without_dsn", "")
+		}
+	}
+}
+
+// Validate checks cfg against the validate tags of Config and the rules
+// that span several settings. It reports every problem, not just the first.
+func (cfg *Config) Validate() error {
+	err := configValidator.Struct(cfg)
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		return err
+	}
+
+	errs := make([]error, 0, len(fieldErrs))
+	for _, fe := range fieldErrs {
+		errs = append(errs, fmt.Errorf("%s: %s", fe.Field(), describeFieldError(fe)))
+	}
+	return fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+}
+
+func describeFieldError(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "required_without_dsn":
+		return "is required unless DB_DSN is set"
+	case "oneof":
+		return fmt.Sprintf("must be one of %s, got %q", strings.ReplaceAll(fe.Param(), " ", ", "), fe.Value())
+	case "required_if":
+		field, value, _ := strings.Cut(fe.Param(), " ")
+		return fmt.Sprintf("is required when %s is %s", siblingEnv(fe, field), value)
+	case "min":
+		return fmt.Sprintf("must be at least %s, got %v", fe.Param(), fe.Value())
+	case "max":
+		return fmt.Sprintf("must be at most %s, got %v", fe.Param(), fe.Value())
+	case "gt":
+		return fmt.Sprintf("must be greater than %s, got %v", fe.Param(), fe.Value())
+	case "gtefield":
+		return fmt.Sprintf("must not be less than %s, got %v", siblingEnv(fe, fe.Param()), fe.Value())
+	case "url":
+		return fmt.Sprintf("must be an absolute URL, got %q", fe.Value())
+	case "startswith":
+		return fmt.Sprintf("must start with %q, got %q", fe.Param(), fe.Value())
+	case "number":
+		return fmt.Sprintf("must be a number, got %q", fe.Value())
+	case "route_timeout":
+		return fmt.Sprintf("must be [METHOD ]/path=duration, e.g. POST /users=5s, got %q", fe.Value())
+	case "cors_origin":
+		return fmt.Sprintf("must be *, or scheme://host[:port] with an optional *. before the host, got %q", fe.Value())
+	case "file":
+		return fmt.Sprintf("file %q does not exist", fe.Value())
+	}
+	return fmt.Sprintf("failed the %q rule", fe.Tag())
+}
+
+// validCORSOrigin reports whether s is *, or an origin such as
+// https://example.com:8443, optionally matching any subdomain as in
+// https://*.example.com.
+func validCORSOrigin(s string) bool {
+	if s == "*" {
+		return true
+	}
+	scheme, host, ok := strings.Cut(s, "://")
+	if !ok || scheme == "" || host == "" || strings.ContainsAny(host, "/?#@") {
+		return false
+	}
+	host = strings.TrimPrefix(host, "*.")
+	return host != "" && !strings.Contains(host, "*")
+}
+
+// siblingEnv returns the environment variable of the field named name that
+// a cross-field rule such as gtefield refers to.
+func siblingEnv(fe validator.FieldError, name string) string {
+	path := strings.Split(fe.StructNamespace(), ".")
+	t := reflect.TypeOf(Config{})
+	for _, name := range path[1 : len(path)-1] {
+		f, ok := t.FieldByName(name)
+		if !ok {
+			return fe.Param()
+		}
+		t = f.Type
+	}
+	if f, ok := t.FieldByName(name); ok {
+		return f.Tag.Get("env")
+	}
+	return name
+}
@@ -0,0 +1,357 @@
+package app
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/rs/zerolog/log"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/mysqldialect"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	"github.com/uptrace/bun/driver/pgdriver"
+	"github.com/uptrace/bun/driver/sqliteshim"
+)
+
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+const (
+	TLSDisable = "disable"
+	TLSRequire = "require"
+	TLSVerify  = "verify"
+)
+
+// OpenDB opens a bun.DB for the driver selected in cfg.DB.Driver (MySQL when
+// empty), applies the pool settings and waits until the database answers.
+// Failed pings are retried with exponential backoff until
+// cfg.DB.ConnectTimeout has passed, so the service can start alongside a
+// database that is still booting.
+func OpenDB(ctx context.Context, cfg *Config) (*bun.DB, error) {
+	db, err := newDB(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := pingWithRetry(ctx, db, cfg.DB.ConnectTimeout); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("connecting to %s database: %w", db.Dialect().Name(), err)
+	}
+	return db, nil
+}
+
+func newDB(cfg *Config) (*bun.DB, error) {
+	c := cfg.DB
+
+	var db *bun.DB
+	switch c.Driver {
+	case "", DriverMySQL:
+		connector, err := newRotatingConnector(cfg, mysqlConnector)
+		if err != nil {
+			return nil, err
+		}
+		db = bun.NewDB(sql.OpenDB(connector), mysqldialect.New())
+	case DriverPostgres:
+		connector, err := newRotatingConnector(cfg, pgConnector)
+		if err != nil {
+			return nil, err
+		}
+		db = bun.NewDB(sql.OpenDB(connector), pgdialect.New())
+	case DriverSQLite:
+		dsn, err := DSN(cfg)
+		if err != nil {
+			return nil, err
+		}
+		sqldb, err := sql.Open(sqliteshim.ShimName, dsn)
+		if err != nil {
+			return nil, err
+		}
+		db = bun.NewDB(sqldb, sqlitedialect.New())
+	}
+
+	setPool(db, c)
+	return db, nil
+}
+
+// setPool applies the pool settings of c to db. It is safe to call on an
+// open database, e.g. after a config reload.
+func setPool(db *bun.DB, c DBConfig) {
+	if c.Driver == DriverSQLite && c.Database == ":memory:" {
+		// Every connection to an in-memory database gets its own empty
+		// database, so the pool must keep exactly one connection forever.
+		db.SetMaxOpenConns(1)
+		db.SetMaxIdleConns(1)
+		db.SetConnMaxLifetime(0)
+		db.SetConnMaxIdleTime(0)
+		return
+	}
+	db.SetMaxOpenConns(c.MaxOpenConns)
+	db.SetMaxIdleConns(c.MaxIdleConns)
+	db.SetConnMaxLifetime(c.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(c.ConnMaxIdleTime)
+}
+
+func poolChanged(old, c DBConfig) bool {
+	return old.MaxOpenConns != c.MaxOpenConns || old.MaxIdleConns != c.MaxIdleConns ||
+		old.ConnMaxLifetime != c.ConnMaxLifetime || old.ConnMaxIdleTime != c.ConnMaxIdleTime
+}
+
+func mysqlConnector(cfg *Config) (driver.Connector, error) {
+	c := cfg.DB
+
+	dsn, err := DSN(cfg)
+	if err != nil {
+		return nil, err
+	}
+	mc, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	// Addr is a socket path unless Net is tcp; a socket has no host name.
+	host, _, _ := net.SplitHostPort(mc.Addr)
+	tlsCfg, err := dbTLSConfig(cfg, host)
+	if err != nil {
+		return nil, err
+	}
+	if tlsCfg != nil {
+		mc.TLS = tlsCfg
+	}
+	if c.DialTimeout > 0 {
+		mc.Timeout = c.DialTimeout
+	}
+	if c.ReadTimeout > 0 {
+		mc.ReadTimeout = c.ReadTimeout
+	}
+	if c.WriteTimeout > 0 {
+		mc.WriteTimeout = c.WriteTimeout
+	}
+	return mysql.NewConnector(mc)
+}
+
+func pgConnector(cfg *Config) (driver.Connector, error) {
+	c := cfg.DB
+
+	dsn, err := DSN(cfg)
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, err
+	}
+	tlsCfg, err := dbTLSConfig(cfg, u.Hostname())
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []pgdriver.Option{pgdriver.WithDSN(dsn)}
+	if tlsCfg != nil {
+		opts = append(opts, pgdriver.WithTLSConfig(tlsCfg))
+	}
+	if c.DialTimeout > 0 {
+		opts = append(opts, pgdriver.WithDialTimeout(c.DialTimeout))
+	}
+	if c.ReadTimeout > 0 {
+		opts = append(opts, pgdriver.WithReadTimeout(c.ReadTimeout))
+	}
+	if c.WriteTimeout > 0 {
+		opts = append(opts, pgdriver.WithWriteTimeout(c.WriteTimeout))
+	}
+	return pgdriver.NewConnector(opts...), nil
+}
+
+// rotatingConnector re-reads DB_DSN and DB_PASSWORD from the secret
+// providers before every new connection and rebuilds the driver connector
+// when they changed, so a rotated password is used without a restart.
+type rotatingConnector struct {
+	cfg   *Config
+	build func(cfg *Config) (driver.Connector, error)
+
+	mu      sync.Mutex
+	secrets string
+	current driver.Connector
+}
+
+func newRotatingConnector(cfg *Config, build func(*Config) (driver.Connector, error)) (*rotatingConnector, error) {
+	c := &rotatingConnector{cfg: cfg, build: build}
+	if _, err := c.connector(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *rotatingConnector) connector() (driver.Connector, error) {
+	dsn, err := c.cfg.Secret("DB_DSN")
+	if err != nil {
+		return nil, err
+	}
+	password, err := c.cfg.Secret("DB_PASSWORD")
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	secrets := dsn + "\x00" + password
+	if c.current != nil && secrets == c.secrets {
+		return c.current, nil
+	}
+
+	cfg := *c.cfg
+	cfg.DB.DSN = dsn
+	cfg.DB.Password = password
+	connector, err := c.build(&cfg)
+	if err != nil {
+		return nil, err
+	}
+	if c.current != nil {
+		log.Info().Msg("database credentials changed, new connections use them")
+	}
+	c.current, c.secrets = connector, secrets
+	return connector, nil
+}
+
+func (c *rotatingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	connector, err := c.connector()
+	if err != nil {
+		// Keep using the last credentials, e.g. while a secret file is
+		// being replaced.
+		log.Warn().Err(err).Msg("re-reading database credentials failed")
+		c.mu.Lock()
+		connector = c.current
+		c.mu.Unlock()
+	}
+	return connector.Connect(ctx)
+}
+
+func (c *rotatingConnector) Driver() driver.Driver {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.current.Driver()
+}
+
+func pingWithRetry(ctx context.Context, db *bun.DB, timeout time.Duration) error {
+	const (
+		minBackoff = 250 * time.Millisecond
+		maxBackoff = 5 * time.Second
+	)
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	backoff := minBackoff
+	for attempt := 1; ; attempt++ {
+		err := db.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+		if timeout <= 0 {
+			return err
+		}
+
+		log.Warn().Err(err).Int("attempt", attempt).Msgf("database not ready, retrying in %s", backoff)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("gave up after %d attempts: %w", attempt, err)
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// DSN returns cfg.DB.DSN when set, or builds the data source name for the
+// selected driver from the individual DB_* settings.
+func DSN(cfg *Config) (string, error) {
+	c := cfg.DB
+	if c.DSN != "" {
+		return c.DSN, nil
+	}
+
+	switch c.Driver {
+	case "", DriverMySQL:
+		return fmt.Sprintf("%s:%s@tcp(%s)/%s?parseTime=true",
+			c.User,
+			c.Password,
+			net.JoinHostPort(c.Host, c.Port),
+			c.Database,
+		), nil
+	case DriverPostgres:
+		u := url.URL{
+			Scheme:   "postgres",
+			User:     url.UserPassword(c.User, c.Password),
+			Host:     net.JoinHostPort(c.Host, c.Port),
+			Path:     "/" + c.Database,
+			RawQuery: "sslmode=disable",
+		}
+		return u.String(), nil
+	case DriverSQLite:
+		if c.Database == ":memory:" {
+			return "file::memory:", nil
+		}
+		return "file:" + c.Database, nil
+	default:
+		return "", fmt.Errorf("unsupported DB_DRIVER %q (want mysql, postgres or sqlite)", c.Driver)
+	}
+}
+
+// dbTLSConfig returns the TLS settings for DB_TLS, or nil to leave the
+// driver's (or the DSN's) default in place.
+//
+//	disable  plain TCP (default)
+//	require  encrypt, but accept any server certificate
+//	verify   encrypt and verify the certificate against DB_TLS_CA or the
+//	         system roots, and that it is for host
+//
+// host is the server's host name as the DSN has it, so DB_DSN is checked
+// against the server it names rather than DB_HOST.
+func dbTLSConfig(cfg *Config, host string) (*tls.Config, error) {
+	c := cfg.DB
+	switch c.TLS {
+	case "", TLSDisable:
+		return nil, nil
+	case TLSRequire:
+		return &tls.Config{InsecureSkipVerify: true}, nil //nolint:gosec // explicitly requested by DB_TLS=require
+	case TLSVerify:
+		if host == "" {
+			return nil, errors.New("DB_TLS=verify needs a host name to verify the certificate for; the DSN has none")
+		}
+		tc := &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+		if c.TLSCA != "" {
+			pem, err := os.ReadFile(c.TLSCA)
+			if err != nil {
+				return nil, fmt.Errorf("reading DB_TLS_CA: %w", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("DB_TLS_CA %s contains no certificates", c.TLSCA)
+			}
+			tc.RootCAs = pool
+		}
+		return tc, nil
+	default:
+		return nil, fmt.Errorf("unsupported DB_TLS %q (want disable, require or verify)", c.TLS)
+	}
+}
@@ -2,21 +2,51 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
+
+	"github.com/rs/zerolog/log"
 )
 
 var onStart appHooks
 
-func OnStart(name string, fn HookFunc) {
-	onStart.Add(newHook(name, fn))
+// OnStart registers a hook that StartConfig runs before it returns the app.
+// Start hooks run one at a time in registration order, except that a hook
+// runs after the hooks named in its After option.
+func OnStart(name string, fn HookFunc, opts ...HookOption) {
+	onStart.Add(newHook(name, fn, opts))
 }
 
 //------------------------------------------------------------------------------
 
 type HookFunc func(ctx context.Context, app *App) error
 
+// DefaultHookTimeout bounds a hook that has no Timeout option.
+const DefaultHookTimeout = 30 * time.Second
+
+// HookOption configures a hook, see After and Timeout.
+type HookOption struct {
+	after      []string
+	timeout    time.Duration
+	hasTimeout bool
+}
+
+// After makes a hook run after the hooks with the given names. Names that
+// are not registered are ignored, so components may depend on optional
+// ones.
+func After(names ...string) HookOption {
+	return HookOption{after: names}
+}
+
+// Timeout bounds how long a hook may run instead of DefaultHookTimeout.
+// The hook's context is cancelled when it expires. Zero or less disables
+// the timeout.
+func Timeout(d time.Duration) HookOption {
+	return HookOption{timeout: d, hasTimeout: true}
+}
+
 type appHooks struct {
 	mu    sync.Mutex
 	hooks []appHook
@@ -29,73 +59,151 @@ func (hs *appHooks) Add(hook appHook) {
 	hs.hooks = append(hs.hooks, hook)
 }
 
-func (hs *appHooks) Run(ctx context.Context, app *App) error {
+// Run runs the hooks one at a time in registration order, or in reverse
+// registration order when reverse is set, as stop hooks are, so whatever
+// started last stops first. After options take precedence over that order.
+//
+// With stopOnError, Run returns at the first failing hook; otherwise every
+// hook runs and all errors are returned together.
+func (hs *appHooks) Run(ctx context.Context, app *App, reverse, stopOnError bool) error {
 	hs.mu.Lock()
-	defer hs.mu.Unlock()
+	hooks := append([]appHook(nil), hs.hooks...)
+	hs.mu.Unlock()
+
+	ordered, err := orderHooks(hooks, reverse)
+	if err != nil {
+		return err
+	}
 
-	var wg sync.WaitGroup
-	var mu sync.Mutex
-	var errors []error
-
-	for _, h := range hs.hooks {
-		h := h
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			if err := h.run(ctx, app); err != nil {
-				mu.Lock()
-				defer mu.Unlock()
-				fmt.Printf("hook=%q failed: %s\n", h.name, err)
-				errors = append(errors, err)
+	var errs []error
+	for _, h := range ordered {
+		if err := h.run(ctx, app); err != nil {
+			log.Error().Err(err).Str("hook", h.name).Msg("hook failed")
+			errs = append(errs, fmt.Errorf("hook %q: %w", h.name, err))
+			if stopOnError {
+				break
 			}
-		}()
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// orderHooks sorts hooks so that each runs after its dependencies, keeping
+// the (possibly reversed) registration order otherwise.
+func orderHooks(hooks []appHook, reverse bool) ([]appHook, error) {
+	pending := make([]appHook, 0, len(hooks))
+	registered := make(map[string]bool, len(hooks))
+	for i := range hooks {
+		h := hooks[i]
+		if reverse {
+			h = hooks[len(hooks)-1-i]
+		}
+		pending = append(pending, h)
+		registered[h.name] = true
 	}
 
-	wg.Wait()
+	done := make(map[string]bool, len(hooks))
+	ordered := make([]appHook, 0, len(hooks))
+	for len(pending) > 0 {
+		next := -1
+		for i, h := range pending {
+			if h.ready(done, registered) {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			names := make([]string, 0, len(pending))
+			for _, h := range pending {
+				names = append(names, h.name)
+			}
+			return nil, fmt.Errorf("hooks %q depend on each other", names)
+		}
 
-	if len(errors) > 0 {
-		return fmt.Errorf("multiple errors occurred: %v", errors)
+		h := pending[next]
+		pending = append(pending[:next], pending[next+1:]...)
+		ordered = append(ordered, h)
+		// With several hooks of one name, the dependency is met once the
+		// last of them ran.
+		if !hasHook(pending, h.name) {
+			done[h.name] = true
+		}
 	}
+	return ordered, nil
+}
 
-	return nil
+func hasHook(hooks []appHook, name string) bool {
+	for _, h := range hooks {
+		if h.name == name {
+			return true
+		}
+	}
+	return false
 }
 
 type appHook struct {
-	name string
-	fn   HookFunc
+	name    string
+	fn      HookFunc
+	after   []string
+	timeout time.Duration
 }
 
-func newHook(name string, fn HookFunc) appHook {
-	return appHook{
-		name: name,
-		fn:   fn,
+func newHook(name string, fn HookFunc, opts []HookOption) appHook {
+	h := appHook{
+		name:    name,
+		fn:      fn,
+		timeout: DefaultHookTimeout,
 	}
+	for _, opt := range opts {
+		h.after = append(h.after, opt.after...)
+		if opt.hasTimeout {
+			h.timeout = opt.timeout
+		}
+	}
+	return h
 }
 
-func (h appHook) run(ctx context.Context, app *App) error {
-	const timeout = 30 * time.Second
+func (h appHook) ready(done, registered map[string]bool) bool {
+	for _, dep := range h.after {
+		if registered[dep] && !done[dep] && dep != h.name {
+			return false
+		}
+	}
+	return true
+}
 
-	done := make(chan struct{})
-	errc := make(chan error)
+// run calls the hook with a context that is cancelled when the hook times
+// out. A hook that ignores the cancellation is left running in the
+// background rather than blocking shutdown.
+func (h appHook) run(ctx context.Context, app *App) error {
+	if h.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+	}
 
+	start := time.Now()
+	errc := make(chan error, 1)
 	go func() {
-		start := time.Now()
-		if err := h.fn(ctx, app); err != nil {
-			errc <- err
-			return
-		}
-		if d := time.Since(start); d > time.Second {
-			fmt.Printf("hook=%q took %s\n", h.name, d)
-		}
-		close(done)
+		errc <- h.fn(ctx, app)
 	}()
 
 	select {
-	case <-done:
-		return nil
 	case err := <-errc:
+		if d := time.Since(start); d > time.Second {
+			log.Warn().Str("hook", h.name).Dur("took", d).Msg("slow hook")
+		}
 		return err
-	case <-time.After(timeout):
-		return fmt.Errorf("hook=%q timed out after %s", h.name, timeout)
+	case <-ctx.Done():
+		select {
+		case err := <-errc:
+			// Finished just in time.
+			return err
+		default:
+		}
+		if h.timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("did not finish within %s: %w", h.timeout, ctx.Err())
+		}
+		return fmt.Errorf("did not finish: %w", ctx.Err())
 	}
 }
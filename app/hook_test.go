@@ -0,0 +1,141 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type hookRecorder struct {
+	mu  sync.Mutex
+	ran []string
+}
+
+func (r *hookRecorder) hook(name string) HookFunc {
+	return func(context.Context, *App) error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.ran = append(r.ran, name)
+		return nil
+	}
+}
+
+func (r *hookRecorder) names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.ran...)
+}
+
+func TestStopHooksRunInReverseOrder(t *testing.T) {
+	var rec hookRecorder
+	app := New(context.Background(), &Config{})
+	app.OnStop("db.Close", rec.hook("db.Close"))
+	app.OnStop("events.Close", rec.hook("events.Close"))
+	app.OnStop("http.Shutdown", rec.hook("http.Shutdown"))
+	app.OnAfterStop("flush", rec.hook("flush"))
+
+	require.NoError(t, app.Shutdown(context.Background()))
+	assert.Equal(t, []string{"http.Shutdown", "events.Close", "db.Close", "flush"}, rec.names())
+
+	// Only the first call stops the app.
+	require.NoError(t, app.Shutdown(context.Background()))
+	assert.Len(t, rec.names(), 4)
+	assert.True(t, app.Stopping())
+}
+
+func TestHooksRunAfterTheirDependencies(t *testing.T) {
+	var rec hookRecorder
+	var hooks appHooks
+	hooks.Add(newHook("b", rec.hook("b"), []HookOption{After("a", "optional")}))
+	hooks.Add(newHook("c", rec.hook("c"), nil))
+	hooks.Add(newHook("a", rec.hook("a"), []HookOption{After("c")}))
+
+	require.NoError(t, hooks.Run(context.Background(), nil, false, true))
+	assert.Equal(t, []string{"c", "a", "b"}, rec.names())
+
+	rec = hookRecorder{}
+	require.NoError(t, hooks.Run(context.Background(), nil, true, true))
+	assert.Equal(t, []string{"c", "a", "b"}, rec.names())
+}
+
+func TestHookDependencyCycle(t *testing.T) {
+	var rec hookRecorder
+	var hooks appHooks
+	hooks.Add(newHook("a", rec.hook("a"), []HookOption{After("b")}))
+	hooks.Add(newHook("b", rec.hook("b"), []HookOption{After("a")}))
+
+	err := hooks.Run(context.Background(), nil, false, false)
+	assert.ErrorContains(t, err, "depend on each other")
+	assert.Empty(t, rec.names())
+}
+
+func TestHookTimeout(t *testing.T) {
+	var rec hookRecorder
+	cancelled := make(chan struct{})
+	block := make(chan struct{})
+	defer close(block)
+
+	var hooks appHooks
+	hooks.Add(newHook("stuck", func(ctx context.Context, _ *App) error {
+		<-ctx.Done()
+		close(cancelled)
+		<-block // ignores the cancellation
+		return nil
+	}, []HookOption{Timeout(20 * time.Millisecond)}))
+	hooks.Add(newHook("next", rec.hook("next"), nil))
+
+	start := time.Now()
+	err := hooks.Run(context.Background(), nil, false, false)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, `hook "stuck": did not finish within 20ms`)
+	assert.Equal(t, []string{"next"}, rec.names())
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("the hook's context was not cancelled")
+	}
+}
+
+func TestHookErrorsAreJoined(t *testing.T) {
+	errA := errors.New("a failed")
+	errB := errors.New("b failed")
+
+	var rec hookRecorder
+	var hooks appHooks
+	hooks.Add(newHook("a", func(context.Context, *App) error { return errA }, nil))
+	hooks.Add(newHook("ok", rec.hook("ok"), nil))
+	hooks.Add(newHook("b", func(context.Context, *App) error { return errB }, nil))
+
+	err := hooks.Run(context.Background(), nil, false, false)
+	assert.ErrorIs(t, err, errA)
+	assert.ErrorIs(t, err, errB)
+	assert.Equal(t, []string{"ok"}, rec.names())
+
+	// Start hooks give up at the first error.
+	rec = hookRecorder{}
+	err = hooks.Run(context.Background(), nil, false, true)
+	assert.ErrorIs(t, err, errA)
+	assert.NotErrorIs(t, err, errB)
+	assert.Empty(t, rec.names())
+}
+
+func TestHookContextCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var hooks appHooks
+	hooks.Add(newHook("waits", func(ctx context.Context, _ *App) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, nil))
+
+	err := hooks.Run(ctx, nil, false, false)
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,51 @@
+package app
+
+import (
+	"io"
+	stdlog "log"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+func init() {
+	// zerolog.Ctx falls back to the global logger for contexts that do not
+	// carry one, such as those of background work.
+	zerolog.DefaultContextLogger = &log.Logger
+}
+
+// NewLogger returns the logger configured by LOG_FORMAT and LOG_SAMPLING_*
+// writing to w, with secrets redacted. The level is global, see
+// LOG_LEVEL.
+func NewLogger(cfg *Config, w io.Writer) zerolog.Logger {
+	w = RedactWriter(w, cfg)
+	if cfg.LogFormat == "console" {
+		w = zerolog.ConsoleWriter{Out: w, TimeFormat: time.RFC3339}
+	}
+
+	logger := zerolog.New(w).With().Timestamp().Str("app", cfg.AppName).Logger()
+	s := cfg.LogSampling
+	if s.Every <= 1 {
+		return logger
+	}
+
+	sampler := &zerolog.BurstSampler{
+		Burst:       uint32(s.Burst),
+		Period:      s.Period,
+		NextSampler: &zerolog.BasicSampler{N: uint32(s.Every)},
+	}
+	return logger.Sample(zerolog.LevelSampler{
+		TraceSampler: sampler,
+		DebugSampler: sampler,
+		InfoSampler:  sampler,
+	})
+}
+
+// setupLogging makes logger the global logger and sends what libraries
+// log through the standard log package to it as well.
+func setupLogging(logger zerolog.Logger) {
+	log.Logger = logger
+	stdlog.SetFlags(0)
+	stdlog.SetOutput(logger)
+}
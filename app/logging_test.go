@@ -0,0 +1,134 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var lines []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var m map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &m), line)
+		lines = append(lines, m)
+	}
+	return lines
+}
+
+func TestNewLogger(t *testing.T) {
+	dir := t.TempDir()
+	cfg, err := LoadConfig(context.Background(), LoadOptions{
+		Dir:     dir,
+		EnvFile: filepath.Join(dir, ".env"),
+		Flags:   map[string]string{"USER_GEO_API_TOKEN": "tok-123456"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "json", cfg.LogFormat)
+
+	var buf bytes.Buffer
+	logger := NewLogger(cfg, &buf)
+	logger.Info().Str("token", "tok-123456").Msg("hello")
+
+	lines := logLines(t, &buf)
+	require.Len(t, lines, 1)
+	assert.Equal(t, "info", lines[0]["level"])
+	assert.Equal(t, cfg.AppName, lines[0]["app"])
+	assert.Equal(t, "[redacted]", lines[0]["token"])
+	assert.Contains(t, lines[0], "time")
+
+	buf.Reset()
+	cfg.LogFormat = "console"
+	logger = NewLogger(cfg, &buf)
+	logger.Warn().Msg("hello")
+	assert.Contains(t, buf.String(), "WRN")
+	assert.NotContains(t, buf.String(), "{")
+}
+
+func TestNewLoggerSampling(t *testing.T) {
+	cfg := &Config{LogFormat: "json"}
+	cfg.LogSampling = LogSamplingConfig{Burst: 2, Period: time.Hour, Every: 5}
+
+	var buf bytes.Buffer
+	logger := NewLogger(cfg, &buf)
+	for i := 0; i < 12; i++ {
+		logger.Info().Int("i", i).Msg("tick")
+		logger.Error().Int("i", i).Msg("failed")
+	}
+
+	var infos, errs int
+	for _, line := range logLines(t, &buf) {
+		switch line["level"] {
+		case "info":
+			infos++
+		case "error":
+			errs++
+		}
+	}
+	// The burst of 2, then every 5th of the remaining 10.
+	assert.Equal(t, 4, infos)
+	assert.Equal(t, 12, errs, "errors are never sampled")
+}
+
+func TestNewLoggerWithoutSampling(t *testing.T) {
+	for _, every := range []int{0, 1} {
+		cfg := &Config{LogFormat: "json"}
+		cfg.LogSampling.Every = every
+
+		var buf bytes.Buffer
+		logger := NewLogger(cfg, &buf)
+		for i := 0; i < 5; i++ {
+			logger.Info().Msg("tick")
+		}
+		assert.Len(t, logLines(t, &buf), 5)
+	}
+}
+
+func TestDebugQueryHook(t *testing.T) {
+	cfg := &Config{AppName: "user-management", Debug: true}
+	cfg.DB.Driver = DriverSQLite
+	cfg.DB.Database = ":memory:"
+
+	app := New(context.Background(), cfg)
+	defer app.Stop()
+	db, err := OpenDB(context.Background(), cfg)
+	require.NoError(t, err)
+	defer db.Close()
+	db.AddQueryHook(app.debugQueryHook())
+
+	var buf bytes.Buffer
+	ctx := zerolog.New(&buf).With().Str("request_id", "req-1").Logger().WithContext(context.Background())
+
+	var n int
+	require.NoError(t, db.NewSelect().ColumnExpr("?", 42).Scan(ctx, &n))
+	require.Error(t, db.NewSelect().Table("missing").Scan(ctx, &n))
+
+	lines := logLines(t, &buf)
+	require.Len(t, lines, 2)
+	assert.Equal(t, "info", lines[0]["level"])
+	assert.Equal(t, "query", lines[0]["message"])
+	assert.Equal(t, "req-1", lines[0]["request_id"])
+	assert.Equal(t, "SELECT", lines[0]["operation"])
+	assert.Equal(t, "SELECT 42", lines[0]["query"])
+	assert.Contains(t, lines[0], "took")
+
+	assert.Equal(t, "error", lines[1]["level"])
+	assert.Contains(t, lines[1]["error"], "no such table")
+
+	buf.Reset()
+	cfg.Debug = false
+	require.NoError(t, db.NewSelect().ColumnExpr("1").Scan(ctx, &n))
+	assert.Empty(t, buf.String(), "queries are only logged in debug mode")
+}
@@ -0,0 +1,93 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/uptrace/bun"
+)
+
+// Metrics returns the registry the app's Prometheus metrics are kept in.
+// It starts out with the Go runtime and process metrics; DB adds the pool
+// and query metrics.
+func (app *App) Metrics() *prometheus.Registry {
+	app.metricsOnce.Do(func() {
+		reg := prometheus.NewRegistry()
+		reg.MustRegister(
+			collectors.NewGoCollector(),
+			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		)
+		app.metrics = reg
+	})
+	return app.metrics
+}
+
+// instrumentDB exports the pool statistics of db under db_name and times
+// its queries.
+func (app *App) instrumentDB(db *bun.DB, name string) {
+	reg := app.Metrics()
+	reg.MustRegister(collectors.NewDBStatsCollector(db.DB, name))
+	db.AddQueryHook(app.queryMetricsHook(name))
+}
+
+func (app *App) queryMetricsHook(name string) bun.QueryHook {
+	app.queryMetricsOnce.Do(func() {
+		app.queryMetrics = newQueryMetrics()
+		app.Metrics().MustRegister(app.queryMetrics.duration, app.queryMetrics.errors)
+	})
+	return &queryMetricsHook{queryMetrics: app.queryMetrics, db: name}
+}
+
+// queryMetrics are labelled by database and statement type only: the
+// query text or table would make a series per query.
+type queryMetrics struct {
+	duration *prometheus.HistogramVec
+	errors   *prometheus.CounterVec
+}
+
+func newQueryMetrics() *queryMetrics {
+	return &queryMetrics{
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "db_query_duration_seconds",
+			Help:    "Duration of database queries by statement type.",
+			Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+		}, []string{"db_name", "operation"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "db_query_errors_total",
+			Help: "Database queries that failed, by statement type. No rows found is not an error.",
+		}, []string{"db_name", "operation"}),
+	}
+}
+
+type queryMetricsHook struct {
+	*queryMetrics
+	db string
+}
+
+func (h *queryMetricsHook) BeforeQuery(ctx context.Context, _ *bun.QueryEvent) context.Context {
+	return ctx
+}
+
+func (h *queryMetricsHook) AfterQuery(_ context.Context, event *bun.QueryEvent) {
+	op := queryOperation(event.Operation())
+	h.duration.WithLabelValues(h.db, op).Observe(time.Since(event.StartTime).Seconds())
+	if event.Err != nil && !errors.Is(event.Err, sql.ErrNoRows) {
+		h.errors.WithLabelValues(h.db, op).Inc()
+	}
+}
+
+// queryOperation maps the statement type bun reports to a fixed set, as
+// raw queries report their first word, whatever it is.
+func queryOperation(op string) string {
+	switch op = strings.ToUpper(op); op {
+	case "SELECT", "INSERT", "UPDATE", "DELETE", "CREATE TABLE", "DROP TABLE", "TRUNCATE TABLE", "BEGIN", "COMMIT", "ROLLBACK":
+		return strings.ToLower(strings.ReplaceAll(op, " ", "_"))
+	default:
+		return "other"
+	}
+}
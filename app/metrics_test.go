@@ -0,0 +1,46 @@
+package app
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDBMetrics(t *testing.T) {
+	cfg := &Config{}
+	cfg.DB.Driver = DriverSQLite
+	cfg.DB.Database = ":memory:"
+	app := New(context.Background(), cfg)
+	defer app.Stop()
+
+	db, err := app.DB()
+	require.NoError(t, err)
+
+	var n int
+	require.NoError(t, db.NewSelect().ColumnExpr("1").Scan(context.Background(), &n))
+	_, err = db.ExecContext(context.Background(), "SELECT * FROM missing")
+	require.Error(t, err)
+
+	reg := app.Metrics()
+	assert.Equal(t, 1, testutil.CollectAndCount(reg, "db_query_duration_seconds"))
+	assert.Equal(t, 1.0, testutil.ToFloat64(app.queryMetrics.errors.WithLabelValues("primary", "select")))
+
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	names := map[string]bool{}
+	for _, f := range families {
+		names[f.GetName()] = true
+	}
+	assert.True(t, names["go_sql_open_connections"], "pool stats")
+	assert.True(t, names["go_goroutines"], "runtime metrics")
+}
+
+func TestQueryOperation(t *testing.T) {
+	assert.Equal(t, "select", queryOperation("SELECT"))
+	assert.Equal(t, "create_table", queryOperation("CREATE TABLE"))
+	assert.Equal(t, "other", queryOperation("PRAGMA"))
+	assert.Equal(t, "other", queryOperation("'); DROP"))
+}
@@ -0,0 +1,200 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// ConfigChangeFunc is called after a reload changed settings that can
+// change at runtime. old and cfg must not be modified.
+type ConfigChangeFunc func(ctx context.Context, old, cfg *Config) error
+
+type configChangeHook struct {
+	name string
+	fn   ConfigChangeFunc
+}
+
+type configChangeHooks struct {
+	mu    sync.Mutex
+	hooks []configChangeHook
+}
+
+// OnConfigChange registers fn to be notified, in registration order, when
+// Reload changed a reloadable setting.
+func (app *App) OnConfigChange(name string, fn ConfigChangeFunc) {
+	app.configChanges.mu.Lock()
+	defer app.configChanges.mu.Unlock()
+	app.configChanges.hooks = append(app.configChanges.hooks, configChangeHook{name: name, fn: fn})
+}
+
+// Reload loads and validates the configuration again from the sources it
+// was first loaded from and swaps in the settings tagged reload:"true".
+// Changes to other settings, such as the listen address or DB_DSN, are
+// logged and ignored until the next restart. Secrets rotate through their
+// providers and are not compared. On error the current configuration is
+// kept.
+func (app *App) Reload() error {
+	app.reloadMu.Lock()
+	defer app.reloadMu.Unlock()
+
+	old := app.Config()
+	if old.opts == nil {
+		return errors.New("configuration was not loaded from files or the environment")
+	}
+
+	loaded, err := LoadConfig(context.Background(), *old.opts)
+	if err != nil {
+		return err
+	}
+	if err := loaded.Validate(); err != nil {
+		return err
+	}
+
+	cfg, changed, ignored := mergeReloadable(old, loaded)
+	for _, key := range ignored {
+		log.Warn().Str("setting", key).Msg("setting changed but needs a restart, keeping the old value")
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	app.cfg.Store(cfg)
+	log.Info().Strs("settings", changed).Msg("configuration reloaded")
+
+	app.configChanges.mu.Lock()
+	hooks := append([]configChangeHook(nil), app.configChanges.hooks...)
+	app.configChanges.mu.Unlock()
+
+	for _, h := range hooks {
+		if err := h.fn(app.ctx, old, cfg); err != nil {
+			log.Error().Err(err).Str("hook", h.name).Msg("applying the new configuration failed")
+		}
+	}
+	return nil
+}
+
+// mergeReloadable returns a copy of old with the reloadable settings taken
+// from loaded, and the settings that changed and were applied or ignored.
+func mergeReloadable(old, loaded *Config) (cfg *Config, changed, ignored []string) {
+	merged := *old
+	ov := reflect.ValueOf(old).Elem()
+	lv := reflect.ValueOf(loaded).Elem()
+	mv := reflect.ValueOf(&merged).Elem()
+
+	for _, f := range configFields(ov.Type(), nil, nil) {
+		newValue := lv.FieldByIndex(f.index)
+		if reflect.DeepEqual(ov.FieldByIndex(f.index).Interface(), newValue.Interface()) {
+			continue
+		}
+		switch {
+		case f.reload:
+			mv.FieldByIndex(f.index).Set(newValue)
+			if f.secret && old.secrets != nil {
+				old.secrets.remember(secretValue(newValue))
+			}
+			changed = append(changed, f.env)
+		case !f.secret:
+			ignored = append(ignored, f.env)
+		}
+	}
+	return &merged, changed, ignored
+}
+
+// WatchConfig reloads the configuration on SIGHUP and, every
+// CONFIG_WATCH_INTERVAL, when one of the files it was loaded from changed.
+// It stops when the app stops.
+func (app *App) WatchConfig() {
+	ctx, cancel := context.WithCancel(app.ctx)
+	app.OnStop("config.Watch", func(context.Context, *App) error {
+		cancel()
+		return nil
+	})
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	interval := app.Config().ConfigWatchInterval
+
+	go func() {
+		defer signal.Stop(hup)
+
+		var tick <-chan time.Time
+		if interval > 0 {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+
+		files := configFilesState(app.Config())
+		reload := func(reason string) {
+			log.Info().Str("reason", reason).Msg("reloading configuration")
+			if err := app.Reload(); err != nil {
+				log.Error().Err(err).Msg("reloading configuration failed, keeping the current one")
+			}
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				files = configFilesState(app.Config())
+				reload("SIGHUP")
+			case <-tick:
+				if state := configFilesState(app.Config()); state != files {
+					files = state
+					reload("config file changed")
+				}
+			}
+		}
+	}()
+}
+
+// configFilesState fingerprints the files cfg was or could have been
+// loaded from, so that creating, changing and removing one all show up.
+func configFilesState(cfg *Config) string {
+	if cfg.opts == nil {
+		return ""
+	}
+	paths := []string{cfg.opts.EnvFile}
+	for _, base := range []string{"config", "config." + cfg.Env} {
+		for _, ext := range configExtensions {
+			paths = append(paths, filepath.Join(cfg.opts.Dir, base+ext))
+		}
+	}
+
+	var b strings.Builder
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			fmt.Fprintf(&b, "%s:-;", path)
+			continue
+		}
+		fmt.Fprintf(&b, "%s:%d:%d;", path, info.ModTime().UnixNano(), info.Size())
+	}
+	return b.String()
+}
+
+func setLogLevel(cfg *Config) error {
+	if cfg.LogLevel == "" {
+		return nil
+	}
+	level, err := zerolog.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		return fmt.Errorf("LOG_LEVEL: %w", err)
+	}
+	zerolog.SetGlobalLevel(level)
+	return nil
+}
@@ -0,0 +1,135 @@
+package app
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadTestApp(t *testing.T, dir, config string) *App {
+	t.Helper()
+	writeFile(t, dir, "config.yaml", config)
+	cfg, err := LoadConfig(context.Background(), LoadOptions{Dir: dir, EnvFile: filepath.Join(dir, ".env")})
+	require.NoError(t, err)
+
+	level := zerolog.GlobalLevel()
+	t.Cleanup(func() { zerolog.SetGlobalLevel(level) })
+
+	app := New(context.Background(), cfg)
+	t.Cleanup(app.Stop)
+	return app
+}
+
+func TestAppReload(t *testing.T) {
+	dir := t.TempDir()
+	app := loadTestApp(t, dir, `
+log_level: info
+db:
+  driver: sqlite
+  database: `+filepath.Join(dir, "users.db")+`
+  max_open_conns: 5
+`)
+	db, err := app.DB()
+	require.NoError(t, err)
+	assert.Equal(t, 5, db.Stats().MaxOpenConnections)
+
+	var notified [][2]*Config
+	app.OnConfigChange("test", func(_ context.Context, old, cfg *Config) error {
+		notified = append(notified, [2]*Config{old, cfg})
+		return nil
+	})
+
+	first := app.Config()
+	writeFile(t, dir, "config.yaml", `
+log_level: warn
+db:
+  driver: sqlite
+  database: `+filepath.Join(dir, "other.db")+`
+  max_open_conns: 7
+`)
+	require.NoError(t, app.Reload())
+
+	cfg := app.Config()
+	assert.Equal(t, "warn", cfg.LogLevel)
+	assert.Equal(t, zerolog.WarnLevel, zerolog.GlobalLevel())
+	assert.Equal(t, 7, cfg.DB.MaxOpenConns)
+	assert.Equal(t, 7, db.Stats().MaxOpenConnections)
+	// Not reloadable: keeps the value the app started with.
+	assert.Equal(t, filepath.Join(dir, "users.db"), cfg.DB.Database)
+
+	require.Len(t, notified, 1)
+	assert.Same(t, first, notified[0][0])
+	assert.Same(t, cfg, notified[0][1])
+	assert.Equal(t, "info", first.LogLevel, "the old config is not changed")
+}
+
+func TestDBStatsWhileOpening(t *testing.T) {
+	dir := t.TempDir()
+	app := loadTestApp(t, dir, `
+db:
+  driver: sqlite
+  database: `+filepath.Join(dir, "users.db")+`
+  max_open_conns: 5
+`)
+	assert.Zero(t, app.DBStats().MaxOpenConnections, "not opened yet")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			app.DBStats()
+		}
+	}()
+	_, err := app.DB()
+	require.NoError(t, err)
+	<-done
+
+	assert.Equal(t, 5, app.DBStats().MaxOpenConnections)
+}
+
+func TestAppReloadKeepsConfigOnError(t *testing.T) {
+	dir := t.TempDir()
+	app := loadTestApp(t, dir, "log_level: info\ndb:\n  host: localhost\n  database: users\n")
+	before := app.Config()
+
+	writeFile(t, dir, "config.yaml", "log_level: loud\n")
+	assert.ErrorContains(t, app.Reload(), "LOG_LEVEL")
+	assert.Same(t, before, app.Config())
+
+	writeFile(t, dir, "config.yaml", "db:\n  batch_size: many\n")
+	assert.ErrorContains(t, app.Reload(), "DB_BATCH_SIZE")
+	assert.Same(t, before, app.Config())
+}
+
+func TestAppReloadWithoutSources(t *testing.T) {
+	app := New(context.Background(), &Config{})
+	assert.Error(t, app.Reload())
+}
+
+func TestWatchConfigReloadsOnFileChange(t *testing.T) {
+	dir := t.TempDir()
+	app := loadTestApp(t, dir, "config_watch_interval: 10ms\nlog_level: info\ndb:\n  host: localhost\n  database: users\n")
+
+	changed := make(chan *Config, 1)
+	app.OnConfigChange("test", func(_ context.Context, _, cfg *Config) error {
+		changed <- cfg
+		return nil
+	})
+	app.WatchConfig()
+
+	// Make sure the modification time differs on coarse file systems.
+	time.Sleep(20 * time.Millisecond)
+	writeFile(t, dir, "config.yaml", "config_watch_interval: 10ms\nlog_level: error\ndb:\n  host: localhost\n  database: users\n")
+
+	select {
+	case cfg := <-changed:
+		assert.Equal(t, "error", cfg.LogLevel)
+	case <-time.After(5 * time.Second):
+		t.Fatal("config change was not picked up")
+	}
+}
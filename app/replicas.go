@@ -0,0 +1,95 @@
+package app
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/uptrace/bun"
+)
+
+// replicaSet balances reads over the healthy read replicas and falls back
+// to the primary when none is available.
+type replicaSet struct {
+	primary  *bun.DB
+	replicas []*replica
+	next     atomic.Uint32
+}
+
+type replica struct {
+	name    string
+	db      *bun.DB
+	healthy atomic.Bool
+}
+
+func newReplicaSet(primary *bun.DB, replicas []*bun.DB) *replicaSet {
+	rs := &replicaSet{primary: primary}
+	for i, db := range replicas {
+		rs.replicas = append(rs.replicas, &replica{
+			name: "replica-" + strconv.Itoa(i+1),
+			db:   db,
+		})
+	}
+	return rs
+}
+
+// pick returns the next healthy replica in round-robin order, or the
+// primary when every replica is down.
+func (rs *replicaSet) pick() *bun.DB {
+	n := len(rs.replicas)
+	if n == 0 {
+		return rs.primary
+	}
+	start := int(rs.next.Add(1))
+	for i := 0; i < n; i++ {
+		r := rs.replicas[(start+i)%n]
+		if r.healthy.Load() {
+			return r.db
+		}
+	}
+	return rs.primary
+}
+
+func (rs *replicaSet) checkHealth(ctx context.Context, timeout time.Duration) {
+	for _, r := range rs.replicas {
+		pingCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := r.db.PingContext(pingCtx)
+		cancel()
+
+		healthy := err == nil
+		if was := r.healthy.Swap(healthy); was != healthy {
+			if healthy {
+				log.Info().Str("replica", r.name).Msg("read replica is up")
+			} else {
+				log.Warn().Err(err).Str("replica", r.name).Msg("read replica is down, failing over")
+			}
+		}
+	}
+}
+
+// run checks the replicas every interval until ctx is done.
+func (rs *replicaSet) run(ctx context.Context, interval, timeout time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			rs.checkHealth(ctx, timeout)
+		}
+	}
+}
+
+func (rs *replicaSet) close() error {
+	var firstErr error
+	for _, r := range rs.replicas {
+		if err := r.db.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,66 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+)
+
+func openSQLite(t *testing.T) *bun.DB {
+	cfg := &Config{}
+	cfg.DB.Driver = DriverSQLite
+	cfg.DB.Database = ":memory:"
+	db, err := OpenDB(context.Background(), cfg)
+	require.NoError(t, err)
+	return db
+}
+
+func TestReplicaSet_Pick(t *testing.T) {
+	primary := openSQLite(t)
+	defer primary.Close()
+
+	assert.Same(t, primary, newReplicaSet(primary, nil).pick())
+
+	r1, r2 := openSQLite(t), openSQLite(t)
+	defer r1.Close()
+	defer r2.Close()
+
+	rs := newReplicaSet(primary, []*bun.DB{r1, r2})
+	assert.Same(t, primary, rs.pick(), "replicas start unhealthy until checked")
+
+	rs.checkHealth(context.Background(), time.Second)
+	picked := map[*bun.DB]int{}
+	for i := 0; i < 10; i++ {
+		picked[rs.pick()]++
+	}
+	assert.Equal(t, map[*bun.DB]int{r1: 5, r2: 5}, picked)
+
+	// A replica going away is skipped, and the primary takes over once
+	// none are left.
+	require.NoError(t, r1.Close())
+	rs.checkHealth(context.Background(), time.Second)
+	assert.Same(t, r2, rs.pick())
+	assert.Same(t, r2, rs.pick())
+
+	require.NoError(t, r2.Close())
+	rs.checkHealth(context.Background(), time.Second)
+	assert.Same(t, primary, rs.pick())
+}
+
+func TestApp_ReadDBWithoutReplicasUsesPrimary(t *testing.T) {
+	cfg := &Config{}
+	cfg.DB.Driver = DriverSQLite
+	cfg.DB.Database = ":memory:"
+	app := New(context.Background(), cfg)
+	defer app.Stop()
+
+	primary, err := app.DB()
+	require.NoError(t, err)
+	read, err := app.ReadDB()
+	require.NoError(t, err)
+	assert.Same(t, primary, read)
+}
@@ -0,0 +1,278 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Component is a long-running part of the service run by App.Run, such as
+// an HTTP or gRPC server, an outbox relay or a scheduler.
+type Component interface {
+	// Serve runs the component until it fails or is shut down. It returns
+	// nil once Shutdown was called. ctx is cancelled when the component
+	// has to stop at once because its grace period is over.
+	Serve(ctx context.Context) error
+	// Shutdown stops taking new work and waits for the work in flight,
+	// giving up when ctx is done.
+	Shutdown(ctx context.Context) error
+}
+
+// NamedComponent is a Component with the name it is logged and reported
+// under, see Named.
+type NamedComponent struct {
+	name        string
+	component   Component
+	gracePeriod time.Duration
+	hasGrace    bool
+}
+
+// Named names a component for App.Run.
+func Named(name string, c Component) NamedComponent {
+	return NamedComponent{name: name, component: c}
+}
+
+// WithGracePeriod lets the component drain for d instead of
+// SHUTDOWN_GRACE_PERIOD. Zero stops it at once.
+func (c NamedComponent) WithGracePeriod(d time.Duration) NamedComponent {
+	c.gracePeriod = d
+	c.hasGrace = true
+	return c
+}
+
+// Run starts the components in order and blocks until an exit signal
+// arrives, a component stops on its own or the app is stopped. It then
+// shuts the components down in reverse order, so list the ones taking
+// outside traffic last: they stop first while the workers they feed are
+// still running. Each component gets its grace period to drain, after
+// which its Serve context is cancelled. Once all components stopped, Run
+// runs the stop hooks, see Shutdown, so the database closes last.
+//
+// A second exit signal, or SHUTDOWN_TIMEOUT passing, ends the process
+// without waiting any longer.
+//
+// The returned error joins the component and hook errors.
+func (app *App) Run(components ...NamedComponent) error {
+	signals, stopSignals := app.exitSignals()
+	defer stopSignals()
+
+	exited := make(chan *runningComponent, len(components))
+	running := make([]*runningComponent, 0, len(components))
+	for _, c := range components {
+		rc := startComponent(app.ctx, c, exited)
+		running = append(running, rc)
+		log.Info().Str("component", c.name).Msg("component started")
+	}
+
+	var errs []error
+	select {
+	case sig := <-signals:
+		log.Info().Str("signal", sig.String()).Msg("exit signal received, shutting down")
+	case rc := <-exited:
+		if rc.err != nil {
+			log.Error().Err(rc.err).Str("component", rc.name).Msg("component failed, shutting down")
+		} else {
+			log.Warn().Str("component", rc.name).Msg("component stopped, shutting down")
+		}
+	case <-app.stopCh:
+		log.Info().Msg("app stopped, shutting down")
+	}
+
+	finished := make(chan struct{})
+	defer close(finished)
+	go app.forceExit(signals, finished)
+
+	for i := len(running) - 1; i >= 0; i-- {
+		rc := running[i]
+		grace := app.Config().Shutdown.GracePeriod
+		if rc.hasGrace {
+			grace = rc.gracePeriod
+		}
+		if err := rc.stop(grace); err != nil {
+			log.Error().Err(err).Str("component", rc.name).Msg("component did not stop cleanly")
+			errs = append(errs, fmt.Errorf("component %q: %w", rc.name, err))
+		} else {
+			log.Info().Str("component", rc.name).Msg("component stopped")
+		}
+	}
+
+	errs = append(errs, app.Shutdown(app.ctx))
+	return errors.Join(errs...)
+}
+
+// forceExit ends the process on a second exit signal or when shutting
+// down takes longer than SHUTDOWN_TIMEOUT, unless Run finished first.
+func (app *App) forceExit(signals <-chan os.Signal, finished <-chan struct{}) {
+	var timeout <-chan time.Time
+	if d := app.Config().Shutdown.Timeout; d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-finished:
+		return
+	case sig := <-signals:
+		log.Error().Str("signal", sig.String()).Msg("second exit signal received, exiting without finishing the shutdown")
+	case <-timeout:
+		log.Error().Dur("timeout", app.Config().Shutdown.Timeout).Msg("shutdown timed out, exiting without finishing it")
+	}
+	app.exit(1)
+}
+
+type runningComponent struct {
+	NamedComponent
+
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error // set before done is closed
+}
+
+func startComponent(ctx context.Context, c NamedComponent, exited chan<- *runningComponent) *runningComponent {
+	ctx, cancel := context.WithCancel(ctx)
+	rc := &runningComponent{
+		NamedComponent: c,
+		cancel:         cancel,
+		done:           make(chan struct{}),
+	}
+	go func() {
+		defer close(rc.done)
+		rc.err = c.component.Serve(ctx)
+		exited <- rc
+	}()
+	return rc
+}
+
+// stop shuts the component down and waits for Serve to return for at
+// most grace, then cancels the Serve context.
+func (rc *runningComponent) stop(grace time.Duration) error {
+	defer rc.cancel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+
+	shutdownErr := rc.component.Shutdown(ctx)
+	select {
+	case <-rc.done:
+		return errors.Join(shutdownErr, rc.err)
+	case <-ctx.Done():
+	}
+
+	rc.cancel()
+	// Serve may still return quickly once cancelled.
+	select {
+	case <-rc.done:
+	case <-time.After(100 * time.Millisecond):
+		return fmt.Errorf("did not stop within %s: %w", grace, ctx.Err())
+	}
+	serveErr := rc.err
+	if errors.Is(serveErr, context.Canceled) {
+		// Cancelled here rather than failed.
+		serveErr = nil
+	}
+	return errors.Join(shutdownErr, serveErr)
+}
+
+//------------------------------------------------------------------------------
+
+// HTTPServer runs srv as a component. Shutting it down stops accepting
+// connections and waits for the requests in flight; connections still
+// open when the grace period is over are closed. Unless srv has a
+// BaseContext, request contexts carry the app and are cancelled when the
+// grace period is over.
+func HTTPServer(srv *http.Server) Component {
+	return &httpServer{srv: srv}
+}
+
+type httpServer struct {
+	srv *http.Server
+}
+
+func (s *httpServer) Serve(ctx context.Context) error {
+	if s.srv.BaseContext == nil {
+		s.srv.BaseContext = func(net.Listener) context.Context { return ctx }
+	}
+	log.Info().Str("addr", s.srv.Addr).Msg("HTTP server listening")
+	if err := s.srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *httpServer) Shutdown(ctx context.Context) error {
+	if err := s.srv.Shutdown(ctx); err != nil {
+		_ = s.srv.Close()
+		return err
+	}
+	return nil
+}
+
+// Worker runs fn as a component, for background loops such as an outbox
+// relay or a scheduler. fn must return when its context is cancelled,
+// which shutting the worker down does.
+func Worker(fn func(ctx context.Context)) Component {
+	return &worker{fn: fn, done: make(chan struct{})}
+}
+
+// DrainingWorker is a Worker that, once fn has returned on shutdown, calls
+// drain with what is left of the grace period, e.g. to handle the work
+// queued by the components stopped before it.
+func DrainingWorker(fn func(ctx context.Context), drain func(ctx context.Context) error) Component {
+	return &worker{fn: fn, drain: drain, done: make(chan struct{})}
+}
+
+type worker struct {
+	fn    func(ctx context.Context)
+	drain func(ctx context.Context) error
+
+	mu      sync.Mutex
+	cancel  context.CancelFunc
+	stopped bool
+	done    chan struct{}
+}
+
+func (w *worker) Serve(ctx context.Context) error {
+	defer close(w.done)
+
+	w.mu.Lock()
+	if w.stopped {
+		w.mu.Unlock()
+		return nil
+	}
+	ctx, w.cancel = context.WithCancel(ctx)
+	w.mu.Unlock()
+	defer w.cancel()
+
+	w.fn(ctx)
+	return nil
+}
+
+func (w *worker) Shutdown(ctx context.Context) error {
+	w.mu.Lock()
+	w.stopped = true
+	cancel := w.cancel
+	w.mu.Unlock()
+	if cancel == nil {
+		// Not started.
+		return nil
+	}
+	cancel()
+
+	select {
+	case <-w.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if w.drain == nil {
+		return nil
+	}
+	return w.drain(ctx)
+}
@@ -0,0 +1,202 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runTestApp returns an app whose exit signals come from the returned
+// channel and whose forced exits are reported on the other one.
+func runTestApp(t *testing.T, cfg *Config) (*App, chan os.Signal, chan int) {
+	t.Helper()
+	signals := make(chan os.Signal, 2)
+	exits := make(chan int, 1)
+
+	app := New(context.Background(), cfg)
+	app.exitSignals = func() (<-chan os.Signal, func()) { return signals, func() {} }
+	app.exit = func(code int) { exits <- code }
+	return app, signals, exits
+}
+
+// fakeComponent serves until it is shut down or fails with serveErr.
+type fakeComponent struct {
+	name string
+	rec  *hookRecorder
+	// drain is how long Shutdown takes; ignoring its context.
+	drain    time.Duration
+	serveErr chan error
+
+	once sync.Once
+	stop chan struct{}
+}
+
+func newFakeComponent(name string, rec *hookRecorder) *fakeComponent {
+	return &fakeComponent{name: name, rec: rec, serveErr: make(chan error, 1), stop: make(chan struct{})}
+}
+
+func (c *fakeComponent) Serve(ctx context.Context) error {
+	select {
+	case <-c.stop:
+		return nil
+	case err := <-c.serveErr:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *fakeComponent) Shutdown(context.Context) error {
+	time.Sleep(c.drain)
+	_ = c.rec.hook(c.name)(context.Background(), nil)
+	c.once.Do(func() { close(c.stop) })
+	return nil
+}
+
+func TestRunStopsComponentsInReverseOrder(t *testing.T) {
+	var rec hookRecorder
+	app, signals, exits := runTestApp(t, &Config{})
+	app.OnStop("db.Close", rec.hook("db.Close"))
+
+	signals <- syscall.SIGTERM
+	err := app.Run(
+		Named("worker", newFakeComponent("worker", &rec)),
+		Named("http", newFakeComponent("http", &rec)),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"http", "worker", "db.Close"}, rec.names())
+	assert.True(t, app.Stopping())
+	assert.Empty(t, exits)
+}
+
+func TestRunShutsDownWhenAComponentFails(t *testing.T) {
+	var rec hookRecorder
+	app, _, _ := runTestApp(t, &Config{})
+	failing := newFakeComponent("failing", &rec)
+	failing.serveErr <- errors.New("listen: address in use")
+
+	err := app.Run(
+		Named("worker", newFakeComponent("worker", &rec)),
+		Named("failing", failing),
+	)
+	assert.ErrorContains(t, err, `component "failing": listen: address in use`)
+	assert.Equal(t, []string{"failing", "worker"}, rec.names())
+}
+
+func TestRunGracePeriod(t *testing.T) {
+	var rec hookRecorder
+	app, _, _ := runTestApp(t, &Config{Shutdown: ShutdownConfig{GracePeriod: time.Second}})
+
+	slow := newFakeComponent("slow", &rec)
+	slow.drain = 50 * time.Millisecond
+	stuck := Worker(func(ctx context.Context) {
+		<-ctx.Done()
+		time.Sleep(time.Hour) // ignores the cancellation
+	})
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		app.Stop()
+	}()
+	start := time.Now()
+	err := app.Run(
+		Named("stuck", stuck).WithGracePeriod(20*time.Millisecond),
+		Named("slow", slow),
+	)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, `component "stuck": did not stop within 20ms`)
+	assert.NotContains(t, err.Error(), "slow")
+	assert.Equal(t, []string{"slow"}, rec.names())
+}
+
+func TestRunForcesExitOnSecondSignal(t *testing.T) {
+	var rec hookRecorder
+	app, signals, exits := runTestApp(t, &Config{Shutdown: ShutdownConfig{GracePeriod: time.Second}})
+	slow := newFakeComponent("slow", &rec)
+	slow.drain = 200 * time.Millisecond
+
+	signals <- syscall.SIGINT
+	signals <- syscall.SIGINT
+	require.NoError(t, app.Run(Named("slow", slow)))
+	select {
+	case code := <-exits:
+		assert.Equal(t, 1, code)
+	default:
+		t.Fatal("the second signal did not force the exit")
+	}
+}
+
+func TestRunForcesExitAfterShutdownTimeout(t *testing.T) {
+	var rec hookRecorder
+	app, signals, exits := runTestApp(t, &Config{Shutdown: ShutdownConfig{
+		GracePeriod: time.Second,
+		Timeout:     20 * time.Millisecond,
+	}})
+	slow := newFakeComponent("slow", &rec)
+	slow.drain = 200 * time.Millisecond
+
+	signals <- syscall.SIGTERM
+	require.NoError(t, app.Run(Named("slow", slow)))
+	assert.Len(t, exits, 1)
+}
+
+func TestWorkerShutdown(t *testing.T) {
+	stopped := make(chan struct{})
+	w := Worker(func(ctx context.Context) {
+		<-ctx.Done()
+		close(stopped)
+	})
+
+	served := make(chan error, 1)
+	go func() { served <- w.Serve(context.Background()) }()
+
+	require.Eventually(t, func() bool {
+		w.(*worker).mu.Lock()
+		defer w.(*worker).mu.Unlock()
+		return w.(*worker).cancel != nil
+	}, time.Second, time.Millisecond)
+
+	require.NoError(t, w.Shutdown(context.Background()))
+	assert.NoError(t, <-served)
+	<-stopped
+
+	// Shutdown before Serve keeps the worker from starting.
+	idle := Worker(func(context.Context) { t.Error("ran after Shutdown") })
+	require.NoError(t, idle.Shutdown(context.Background()))
+	assert.NoError(t, idle.Serve(context.Background()))
+}
+
+func TestDrainingWorkerShutdown(t *testing.T) {
+	var steps []string
+	w := DrainingWorker(func(ctx context.Context) {
+		<-ctx.Done()
+		steps = append(steps, "stopped")
+	}, func(ctx context.Context) error {
+		steps = append(steps, "drained")
+		_, ok := ctx.Deadline()
+		assert.True(t, ok, "drain gets the grace period")
+		return errors.New("receiver down")
+	})
+
+	served := make(chan error, 1)
+	go func() { served <- w.Serve(context.Background()) }()
+	require.Eventually(t, func() bool {
+		w.(*worker).mu.Lock()
+		defer w.(*worker).mu.Unlock()
+		return w.(*worker).cancel != nil
+	}, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.EqualError(t, w.Shutdown(ctx), "receiver down")
+	assert.NoError(t, <-served)
+	assert.Equal(t, []string{"stopped", "drained"}, steps)
+}
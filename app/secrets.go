@@ -0,0 +1,233 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// SecretProvider supplies secret settings, the Config fields tagged
+// secret:"true", by their environment variable name. ok is false when the
+// provider does not have the secret.
+//
+// LoadConfig asks the providers in order and the first one that has a
+// secret overrides every other source. The providers are asked again
+// whenever a secret is used anew, e.g. for every new database connection,
+// so rotated secrets are picked up without a restart.
+type SecretProvider interface {
+	Secret(key string) (value string, ok bool, err error)
+}
+
+// EnvSecretProvider reads a secret from the environment variable of the
+// same name.
+type EnvSecretProvider struct {
+	// Lookup defaults to os.LookupEnv.
+	Lookup func(key string) (string, bool)
+}
+
+func (p *EnvSecretProvider) Secret(key string) (string, bool, error) {
+	lookup := p.Lookup
+	if lookup == nil {
+		lookup = os.LookupEnv
+	}
+	v, ok := lookup(key)
+	return v, ok && v != "", nil
+}
+
+// FileSecretProvider reads a secret from the file named by the <KEY>_FILE
+// setting, e.g. DB_PASSWORD_FILE=/run/secrets/db, the way Docker and
+// Kubernetes mount secrets. A trailing newline is dropped. The file is read
+// again only when its modification time or size changed.
+type FileSecretProvider struct {
+	// Lookup resolves the <KEY>_FILE setting and defaults to os.LookupEnv.
+	// LoadConfig passes one that also sees .env, config files and flags.
+	Lookup func(key string) (string, bool)
+
+	mu    sync.Mutex
+	cache map[string]secretFile
+}
+
+type secretFile struct {
+	modTime time.Time
+	size    int64
+	value   string
+}
+
+func (p *FileSecretProvider) Secret(key string) (string, bool, error) {
+	lookup := p.Lookup
+	if lookup == nil {
+		lookup = os.LookupEnv
+	}
+	path, ok := lookup(key + "_FILE")
+	if !ok || path == "" {
+		return "", false, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", false, fmt.Errorf("%s_FILE: %w", key, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if f, ok := p.cache[path]; ok && f.modTime.Equal(info.ModTime()) && f.size == info.Size() {
+		return f.value, true, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("%s_FILE: %w", key, err)
+	}
+	value := strings.TrimRight(string(data), "\r\n")
+	if p.cache == nil {
+		p.cache = make(map[string]secretFile)
+	}
+	p.cache[path] = secretFile{modTime: info.ModTime(), size: info.Size(), value: value}
+	return value, true, nil
+}
+
+// secretStore re-reads secrets from the providers they were loaded from and
+// remembers every value it has seen, so that logs can be redacted even
+// after a rotation.
+type secretStore struct {
+	providers []SecretProvider
+
+	mu   sync.Mutex
+	seen map[string]bool
+}
+
+func newSecretStore(providers []SecretProvider) *secretStore {
+	return &secretStore{providers: providers, seen: make(map[string]bool)}
+}
+
+// lookup asks the providers for key in order.
+func (s *secretStore) lookup(key string) (string, bool, error) {
+	for _, p := range s.providers {
+		v, ok, err := p.Secret(key)
+		if err != nil {
+			return "", false, err
+		}
+		if ok {
+			s.remember(v)
+			return v, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+func (s *secretStore) remember(values ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, v := range values {
+		for _, part := range secretParts(v) {
+			s.seen[part] = true
+		}
+	}
+}
+
+// values returns the secrets seen so far, longest first so that a secret
+// containing another one is redacted as a whole.
+func (s *secretStore) values() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]string, 0, len(s.seen))
+	for v := range s.seen {
+		out = append(out, v)
+	}
+	sort.Slice(out, func(i, j int) bool { return len(out[i]) > len(out[j]) })
+	return out
+}
+
+// minSecretLen keeps very short values, which would match all over the
+// logs, from being redacted.
+const minSecretLen = 4
+
+// secretParts returns v and, for DSNs, the password inside it.
+func secretParts(v string) []string {
+	var out []string
+	add := func(s string) {
+		if len(s) >= minSecretLen {
+			out = append(out, s)
+		}
+	}
+	add(v)
+	if u, err := url.Parse(v); err == nil && u.User != nil {
+		if pw, ok := u.User.Password(); ok {
+			add(pw)
+			add(url.QueryEscape(pw))
+		}
+	} else if mc, err := mysql.ParseDSN(v); err == nil {
+		add(mc.Passwd)
+	}
+	return out
+}
+
+// Secret returns the current value of the secret setting key, its
+// environment variable name, re-read from the secret providers so a rotated
+// secret is picked up. Without a provider for key the loaded value is
+// returned.
+func (cfg *Config) Secret(key string) (string, error) {
+	if cfg.secrets != nil {
+		v, ok, err := cfg.secrets.lookup(key)
+		if err != nil || ok {
+			return v, err
+		}
+	}
+	for _, f := range configFields(reflect.TypeOf(Config{}), nil, nil) {
+		if f.env == key && f.secret {
+			return secretValue(reflect.ValueOf(cfg).Elem().FieldByIndex(f.index)), nil
+		}
+	}
+	return "", fmt.Errorf("%s is not a secret setting", key)
+}
+
+// secretValue formats a string or list setting the way the environment
+// holds it.
+func secretValue(v reflect.Value) string {
+	if list, ok := v.Interface().([]string); ok {
+		return strings.Join(list, ",")
+	}
+	return v.String()
+}
+
+// Redact replaces every secret value cfg has seen, including rotated ones
+// and passwords inside DSNs, in s.
+func (cfg *Config) Redact(s string) string {
+	if cfg.secrets == nil {
+		return s
+	}
+	for _, v := range cfg.secrets.values() {
+		s = strings.ReplaceAll(s, v, redacted)
+	}
+	return s
+}
+
+// RedactWriter wraps w so that secrets of cfg never reach it, for loggers.
+// Every Write must hold complete lines, which is what zerolog writes.
+func RedactWriter(w io.Writer, cfg *Config) io.Writer {
+	return &redactWriter{w: w, cfg: cfg}
+}
+
+type redactWriter struct {
+	w   io.Writer
+	cfg *Config
+}
+
+func (rw *redactWriter) Write(p []byte) (int, error) {
+	out := rw.cfg.Redact(string(p))
+	if out == string(p) {
+		return rw.w.Write(p)
+	}
+	if _, err := io.WriteString(rw.w, out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,175 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	TracingNone   = "none"
+	TracingOTLP   = "otlp"
+	TracingStdout = "stdout"
+	TracingFile   = "file"
+)
+
+// setupTracing installs the W3C trace context propagator and, unless
+// TRACING_EXPORTER is none, a tracer provider exporting to the configured
+// destination. The provider is flushed and shut down after the stop hooks
+// ran, so the spans of shutting down are exported too.
+func setupTracing(ctx context.Context, app *App) error {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	cfg := app.Config()
+	tp, err := newTracerProvider(ctx, cfg)
+	if err != nil || tp == nil {
+		return err
+	}
+	otel.SetTracerProvider(tp)
+	app.OnAfterStop("tracing.Shutdown", func(ctx context.Context, _ *App) error {
+		return tp.Shutdown(ctx)
+	})
+	return nil
+}
+
+// newTracerProvider returns a tracer provider exporting spans as
+// configured in cfg.Tracing, or nil when tracing is off.
+func newTracerProvider(ctx context.Context, cfg *Config) (*sdktrace.TracerProvider, error) {
+	exporter, err := newSpanExporter(ctx, cfg.Tracing)
+	if err != nil || exporter == nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(cfg.AppName),
+		semconv.DeploymentEnvironment(cfg.Env),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.Tracing.SampleRatio))),
+	), nil
+}
+
+func newSpanExporter(ctx context.Context, c TracingConfig) (sdktrace.SpanExporter, error) {
+	switch c.Exporter {
+	case "", TracingNone:
+		return nil, nil
+	case TracingOTLP:
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(c.OTLPEndpoint)}
+		if c.OTLPInsecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		return otlptracehttp.New(ctx, opts...)
+	case TracingStdout:
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case TracingFile:
+		f, err := os.OpenFile(c.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("TRACING_FILE: %w", err)
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		return &fileExporter{SpanExporter: exporter, f: f}, nil
+	default:
+		return nil, fmt.Errorf("unknown TRACING_EXPORTER %q", c.Exporter)
+	}
+}
+
+// fileExporter closes the file the spans are written to on shutdown.
+type fileExporter struct {
+	sdktrace.SpanExporter
+	f *os.File
+}
+
+func (e *fileExporter) Shutdown(ctx context.Context) error {
+	return errors.Join(e.SpanExporter.Shutdown(ctx), e.f.Close())
+}
+
+//------------------------------------------------------------------------------
+
+// tracingQueryHook traces every query as a client span of the span in its
+// context. The statement is redacted like the debug query log.
+type tracingQueryHook struct {
+	app    *App
+	tracer trace.Tracer
+	system attribute.KeyValue
+}
+
+func (app *App) tracingQueryHook(db *bun.DB) bun.QueryHook {
+	var system attribute.KeyValue
+	switch db.Dialect().Name() {
+	case dialect.PG:
+		system = semconv.DBSystemPostgreSQL
+	case dialect.SQLite:
+		system = semconv.DBSystemSqlite
+	default:
+		system = semconv.DBSystemMySQL
+	}
+	return &tracingQueryHook{
+		app:    app,
+		tracer: otel.Tracer("user-management/app"),
+		system: system,
+	}
+}
+
+// maxStatementLen keeps huge batch inserts out of the spans.
+const maxStatementLen = 2048
+
+func (h *tracingQueryHook) BeforeQuery(ctx context.Context, event *bun.QueryEvent) context.Context {
+	if !trace.SpanFromContext(ctx).IsRecording() {
+		// Not part of a sampled request; queries alone are not traced.
+		return ctx
+	}
+	op := event.Operation()
+	ctx, _ = h.tracer.Start(ctx, op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithTimestamp(event.StartTime),
+		trace.WithAttributes(h.system, semconv.DBOperationName(op)),
+	)
+	return ctx
+}
+
+func (h *tracingQueryHook) AfterQuery(ctx context.Context, event *bun.QueryEvent) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+	defer span.End()
+
+	query := h.app.Config().Redact(event.Query)
+	if len(query) > maxStatementLen {
+		query = strings.ToValidUTF8(query[:maxStatementLen], "") + "..."
+	}
+	span.SetAttributes(semconv.DBQueryText(query))
+	if event.Err != nil && !errors.Is(event.Err, sql.ErrNoRows) {
+		span.RecordError(event.Err)
+		span.SetStatus(codes.Error, event.Err.Error())
+	}
+}
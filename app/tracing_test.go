@@ -0,0 +1,100 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// exportedSpan is the part of a span the stdout exporter writes that the
+// tests look at.
+type exportedSpan struct {
+	Name        string
+	SpanContext struct{ TraceID, SpanID string }
+	Parent      struct{ TraceID, SpanID string }
+	Attributes  []struct {
+		Key   string
+		Value struct{ Value any }
+	}
+}
+
+func (s exportedSpan) attr(key string) any {
+	for _, a := range s.Attributes {
+		if a.Key == key {
+			return a.Value.Value
+		}
+	}
+	return nil
+}
+
+func readSpans(t *testing.T, path string) []exportedSpan {
+	t.Helper()
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var spans []exportedSpan
+	dec := json.NewDecoder(bufio.NewReader(f))
+	for dec.More() {
+		var s exportedSpan
+		require.NoError(t, dec.Decode(&s))
+		spans = append(spans, s)
+	}
+	return spans
+}
+
+func TestTracingToFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	cfg := &Config{AppName: "user-management", Env: "test"}
+	cfg.Tracing = TracingConfig{Exporter: TracingFile, File: path, SampleRatio: 1}
+	cfg.DB.Driver = DriverSQLite
+	cfg.DB.Database = ":memory:"
+
+	tp, err := newTracerProvider(context.Background(), cfg)
+	require.NoError(t, err)
+
+	app := New(context.Background(), cfg)
+	defer app.Stop()
+	db, err := OpenDB(context.Background(), cfg)
+	require.NoError(t, err)
+	defer db.Close()
+	db.AddQueryHook(&tracingQueryHook{app: app, tracer: tp.Tracer("test"), system: semconv.DBSystemSqlite})
+
+	var n int
+	// Queries outside a trace are not traced.
+	require.NoError(t, db.NewSelect().ColumnExpr("1").Scan(context.Background(), &n))
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "POST /users")
+	require.NoError(t, db.NewSelect().ColumnExpr("?", 42).Scan(ctx, &n))
+	parent.End()
+	require.NoError(t, tp.Shutdown(context.Background()))
+
+	spans := readSpans(t, path)
+	require.Len(t, spans, 2)
+	query, request := spans[0], spans[1]
+	assert.Equal(t, "POST /users", request.Name)
+	assert.Equal(t, "SELECT", query.Name)
+	assert.Equal(t, request.SpanContext.TraceID, query.Parent.TraceID)
+	assert.Equal(t, request.SpanContext.SpanID, query.Parent.SpanID)
+	assert.Equal(t, "sqlite", query.attr("db.system"))
+	assert.Equal(t, "SELECT 42", query.attr("db.query.text"))
+}
+
+func TestNewSpanExporter(t *testing.T) {
+	exporter, err := newSpanExporter(context.Background(), TracingConfig{Exporter: TracingNone})
+	assert.NoError(t, err)
+	assert.Nil(t, exporter)
+
+	_, err = newSpanExporter(context.Background(), TracingConfig{Exporter: TracingFile, File: filepath.Join(t.TempDir(), "missing", "traces.jsonl")})
+	assert.ErrorContains(t, err, "TRACING_FILE")
+
+	_, err = newSpanExporter(context.Background(), TracingConfig{Exporter: "zipkin"})
+	assert.ErrorContains(t, err, `unknown TRACING_EXPORTER "zipkin"`)
+}
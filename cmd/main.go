@@ -6,26 +6,33 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
-	"os/signal"
+	"path/filepath"
 	"strings"
-	"syscall"
 	"time"
 
 	"user-management/app"
 	"user-management/cmd/migrations"
+	"user-management/internal/user-management/domain"
 	"user-management/internal/user-management/domain/controller"
 	"user-management/internal/user-management/domain/service"
+	"user-management/internal/user-management/domain/userfile"
 	"user-management/internal/user-management/infrastructure/repository"
+	"user-management/internal/user-management/middleware"
 
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/zerolog/log"
 
 	_ "user-management/docs" // auto-generated Swagger docs
 
 	httpSwagger "github.com/swaggo/http-swagger"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
 	"github.com/uptrace/bun/migrate"
 	"github.com/urfave/cli/v2"
 )
@@ -37,12 +44,23 @@ func main() {
 			&cli.StringFlag{
 				Name:  "env",
 				Value: "dev",
-				Usage: "environment",
+				Usage: "environment, selects config.<env>.yaml (overrides APP_ENV)",
+			},
+			&cli.StringFlag{
+				Name:    "config-dir",
+				EnvVars: []string{"CONFIG_DIR"},
+				Usage:   "directory with config.yaml and config.<env>.yaml",
+			},
+			&cli.StringSliceFlag{
+				Name:  "set",
+				Usage: "override a setting, e.g. --set DB_HOST=db.internal (repeatable)",
 			},
 		},
 		Commands: []*cli.Command{
 			httpCommand,
 			newDBCommand(migrations.Migrations),
+			usersCommand,
+			configCommand,
 		},
 	}
 
@@ -57,65 +75,227 @@ var httpCommand = &cli.Command{
 	Flags: []cli.Flag{
 		&cli.StringFlag{
 			Name:  "addr",
-			Value: ":8087",
-			Usage: "serve address",
+			Usage: "serve address (default HTTP_ADDR)",
 		},
 	},
 	Action: func(c *cli.Context) error {
-		_, app, err := app.Start(c.Context, "service", c.String("env"))
+		_, app, err := app.StartCLI(c)
 		if err != nil {
 			return err
 		}
+		defer app.Stop()
+		app.WatchConfig()
 		if app.Config().UserGeoApiToken == "" {
-			return fmt.Errorf("User geo api token is missing")
+			log.Warn().Msg("USER_GEO_API_TOKEN is not set; users are registered without the ipinfo lookup")
 		}
-		defer app.Stop()
 
-		apiClient := service.NewIPInfoClient(app.Config().UserGeoApiToken)
-		repo := repository.NewUserRepository(app.DB())
-		userService := service.NewUserService(repo, apiClient)
-		controller := controller.NewController(userService)
+		broker := service.NewUserEventBroker(app.Config().Events.ReplayBuffer)
+		app.OnStop("events.Close", stopHook(broker.Close))
+
+		apiClient := service.InstrumentIPInfoClient(service.NewIPInfoClient(func() string {
+			token, err := app.Config().Secret("USER_GEO_API_TOKEN")
+			if err != nil {
+				log.Warn().Err(err).Msg("Re-reading the ipinfo token failed")
+				return app.Config().UserGeoApiToken
+			}
+			return token
+		}), app.Metrics())
+		// Without a database (USER_REPOSITORY=memory) there are no webhooks,
+		// whose subscriptions and deliveries are kept there.
+		var db *bun.DB
+		var repo domain.IUserRepository
+		switch app.Config().UserRepository {
+		case "memory":
+			log.Warn().Msg("Users are kept in memory and will be lost on restart; webhooks are off, they need USER_REPOSITORY=db")
+			repo = repository.NewInMemoryUserRepository()
+		case "db":
+			if db, err = app.DB(); err != nil {
+				return err
+			}
+			repo = repository.NewRoutedUserRepository(db, app.ReadDB)
+		default:
+			return fmt.Errorf("unknown USER_REPOSITORY %q (want db or memory)", app.Config().UserRepository)
+		}
+		var searcher domain.IUserSearcher = repository.NewFuzzyUserSearcher(repo)
+		if db != nil && db.Dialect().Name() == dialect.MySQL {
+			var fallback domain.IUserSearcher
+			if app.Config().UserSearchFuzzyFallback {
+				fallback = searcher
+			}
+			searcher = repository.NewFulltextUserSearcher(db, app.ReadDB, fallback)
+		}
+		var publishers []domain.IUserEventPublisher
+		var webhookService service.IWebhookService
+		if db != nil {
+			webhookCfg := app.Config().Webhook
+			webhookService = service.NewWebhookService(repository.NewWebhookRepository(db), service.WebhookOptions{
+				MaxAttempts:  webhookCfg.MaxAttempts,
+				BaseBackoff:  webhookCfg.BaseBackoff,
+				MaxBackoff:   webhookCfg.MaxBackoff,
+				PollInterval: webhookCfg.PollInterval,
+			})
+			publishers = append(publishers, webhookService)
+		}
+		publishers = append(publishers, broker)
+		userService := service.NewUserService(repo, searcher, apiClient, service.UserServiceOptions{
+			BatchSize: app.Config().DB.BatchSize,
+		}, publishers...)
+		webhookController := controller.NewWebhookController(webhookService)
+		eventsController := controller.NewEventsController(broker, app.Config().Events.Heartbeat)
+		controller := controller.NewController(userService, controller.ControllerOptions{
+			MaxBatchItems:       app.Config().UserBatchMaxItems,
+			ExportFlushInterval: app.Config().HTTP.ExportFlushInterval,
+		})
 
 		router := mux.NewRouter()
+		if db != nil && len(app.Config().DB.ReplicaDSNs) > 0 {
+			if _, err := app.ReadDB(); err != nil {
+				return err
+			}
+			router.Use(middleware.ReadYourWrites(app.Config().DB.ReplicaStickyWindow))
+		}
 		router.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler)
+		if path := app.Config().HTTP.MetricsPath; path != "" {
+			router.Handle(path, promhttp.HandlerFor(app.Metrics(), promhttp.HandlerOpts{})).Methods("GET")
+		}
+		if app.Config().HTTP.DebugDBStats {
+			router.HandleFunc("/debug/db/stats", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(app.DBStats())
+			}).Methods("GET")
+		}
 		router.HandleFunc("/users", controller.CreateUser).Methods("POST")
 		router.HandleFunc("/users", controller.GetUsers).Methods("GET")
+		router.HandleFunc("/users:batch", controller.CreateUsers).Methods("POST")
+		router.HandleFunc("/users:batch", controller.UpdateUsers).Methods("PUT")
+		router.HandleFunc("/users:batch", controller.DeleteUsers).Methods("DELETE")
+		router.HandleFunc("/users/events", eventsController.StreamUserEvents).Methods("GET")
+		router.HandleFunc("/users/export", controller.StreamUsers).Methods("GET")
+		router.HandleFunc("/users/search", controller.SearchUsers).Methods("GET")
 		router.HandleFunc("/users/{id:[0-9]+}", controller.GetUserByID).Methods("GET")
 		router.HandleFunc("/users/{id:[0-9]+}", controller.UpdateUser).Methods("PUT")
 		router.HandleFunc("/users/{id:[0-9]+}", controller.DeleteUser).Methods("DELETE")
+		if webhookService != nil {
+			router.HandleFunc("/webhooks", webhookController.CreateSubscription).Methods("POST")
+			router.HandleFunc("/webhooks", webhookController.GetSubscriptions).Methods("GET")
+			router.HandleFunc("/webhooks/{id:[0-9]+}", webhookController.GetSubscriptionByID).Methods("GET")
+			router.HandleFunc("/webhooks/{id:[0-9]+}", webhookController.UpdateSubscription).Methods("PUT")
+			router.HandleFunc("/webhooks/{id:[0-9]+}", webhookController.DeleteSubscription).Methods("DELETE")
+			router.HandleFunc("/admin/webhooks/deliveries", webhookController.GetDeliveries).Methods("GET")
+			router.HandleFunc("/admin/webhooks/deliveries/{id:[0-9]+}", webhookController.GetDeliveryByID).Methods("GET")
+			router.HandleFunc("/admin/webhooks/deliveries/{id:[0-9]+}/replay", webhookController.ReplayDelivery).Methods("POST")
+		}
 
-		httpSrv := &http.Server{
-			Addr:    c.String("addr"),
-			Handler: router,
+		addr := app.Config().HTTP.Addr
+		if c.IsSet("addr") {
+			addr = c.String("addr")
+		}
+		middlewares := []func(http.Handler) http.Handler{
+			middleware.RequestID,
+			middleware.Tracing(router),
 		}
+		if app.Config().HTTP.AccessLog {
+			middlewares = append(middlewares, middleware.AccessLog(router))
+		}
+		middlewares = append(middlewares, middleware.Metrics(app.Metrics(), router))
 
-		go func() {
-			log.Info().Msgf("Starting HTTP server at %s", "8087")
-			if err := httpSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-				log.Fatal().Msgf("HTTP server error: %v", err)
-			}
-		}()
+		httpCfg := app.Config().HTTP
+		routeTimeouts, err := httpRouteTimeouts(httpCfg)
+		if err != nil {
+			return err
+		}
+		corsCfg := app.Config().CORS
+		middlewares = append(middlewares,
+			middleware.Recover,
+			middleware.CORS(router, middleware.CORSOptions{
+				AllowedOrigins:   corsCfg.AllowedOrigins,
+				AllowedMethods:   corsCfg.AllowedMethods,
+				AllowedHeaders:   corsCfg.AllowedHeaders,
+				ExposedHeaders:   corsCfg.ExposedHeaders,
+				AllowCredentials: corsCfg.AllowCredentials,
+				MaxAge:           corsCfg.MaxAge,
+			}),
+		)
+		if httpCfg.Compression {
+			middlewares = append(middlewares, middleware.Compress(httpCfg.CompressionMinSize))
+		}
+		middlewares = append(middlewares,
+			middleware.MaxBytes(int64(httpCfg.MaxBodyBytes)),
+			middleware.Timeout(router, httpCfg.RequestTimeout, routeTimeouts),
+		)
 
-		quit := make(chan os.Signal, 1)
-		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-		<-quit
+		httpSrv := &http.Server{
+			Addr:              addr,
+			Handler:           middleware.Chain(router, middlewares...),
+			ReadHeaderTimeout: httpCfg.ReadHeaderTimeout,
+			ReadTimeout:       httpCfg.ReadTimeout,
+			WriteTimeout:      httpCfg.WriteTimeout,
+			IdleTimeout:       httpCfg.IdleTimeout,
+		}
+		// Event streams never finish on their own; end them as soon as the
+		// server starts draining.
+		httpSrv.RegisterOnShutdown(broker.Close)
 
-		log.Info().Msg("Shutdown signal received, stopping services...")
+		return app.Run(httpComponents(httpSrv, webhookService)...)
+	},
+}
 
-		app.Stop()
+// httpComponents lists what the http command runs. App.Run stops them in
+// reverse order: the HTTP server drains first, then the webhook worker
+// stops and makes one last pass over the due deliveries, sending what the
+// last requests queued within its grace period, and the database closes
+// last. webhooks is nil when there are none.
+func httpComponents(srv *http.Server, webhooks service.IWebhookService) []app.NamedComponent {
+	var components []app.NamedComponent
+	if webhooks != nil {
+		components = append(components, app.Named("webhooks", app.DrainingWorker(webhooks.Run, webhooks.ProcessDue)))
+	}
+	return append(components, app.Named("http", app.HTTPServer(srv)))
+}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-		if err := httpSrv.Shutdown(ctx); err != nil {
-			log.Error().Msgf("HTTP shutdown error: %v", err)
-		} else {
-			log.Info().Msg("HTTP server stopped gracefully")
+// httpRouteTimeouts returns the per-route request deadlines: none for the
+// event stream, which stays open, HTTP_EXPORT_TIMEOUT for the export, then
+// HTTP_ROUTE_TIMEOUTS on top.
+func httpRouteTimeouts(cfg app.HTTPConfig) (map[string]time.Duration, error) {
+	routes, err := cfg.RouteTimeoutMap()
+	if err != nil {
+		return nil, err
+	}
+	defaults := map[string]time.Duration{
+		"/users/events": 0,
+		"/users/export": cfg.ExportTimeout,
+	}
+	for route, d := range defaults {
+		if _, ok := routes["GET "+route]; !ok {
+			if _, ok := routes[route]; !ok {
+				routes["GET "+route] = d
+			}
 		}
+	}
+	return routes, nil
+}
 
-		log.Info().Msg("All services stopped")
-
+// stopHook adapts a plain close function to an app.HookFunc.
+func stopHook(fn func()) app.HookFunc {
+	return func(context.Context, *app.App) error {
+		fn()
 		return nil
-	},
+	}
+}
+
+// startMigrator starts the app for a db subcommand and connects the
+// migrator to its database.
+func startMigrator(c *cli.Context, migrations *migrate.Migrations) (context.Context, *app.App, *migrate.Migrator, error) {
+	ctx, a, err := app.StartCLI(c)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	db, err := a.DB()
+	if err != nil {
+		a.Stop()
+		return nil, nil, nil, err
+	}
+	return ctx, a, migrate.NewMigrator(db, migrations), nil
 }
 
 func newDBCommand(migrations *migrate.Migrations) *cli.Command {
@@ -127,13 +307,11 @@ func newDBCommand(migrations *migrate.Migrations) *cli.Command {
 				Name:  "init",
 				Usage: "create migration tables",
 				Action: func(c *cli.Context) error {
-					ctx, app, err := app.StartCLI(c)
+					ctx, app, migrator, err := startMigrator(c, migrations)
 					if err != nil {
 						return err
 					}
 					defer app.Stop()
-
-					migrator := migrate.NewMigrator(app.DB(), migrations)
 					return migrator.Init(ctx)
 				},
 			},
@@ -141,14 +319,12 @@ func newDBCommand(migrations *migrate.Migrations) *cli.Command {
 				Name:  "migrate",
 				Usage: "migrate database",
 				Action: func(c *cli.Context) error {
-					ctx, app, err := app.StartCLI(c)
+					ctx, app, migrator, err := startMigrator(c, migrations)
 					if err != nil {
 						return err
 					}
 					defer app.Stop()
 
-					migrator := migrate.NewMigrator(app.DB(), migrations)
-
 					group, err := migrator.Migrate(ctx)
 					if err != nil {
 						return err
@@ -167,14 +343,12 @@ func newDBCommand(migrations *migrate.Migrations) *cli.Command {
 				Name:  "rollback",
 				Usage: "rollback the last migration group",
 				Action: func(c *cli.Context) error {
-					ctx, app, err := app.StartCLI(c)
+					ctx, app, migrator, err := startMigrator(c, migrations)
 					if err != nil {
 						return err
 					}
 					defer app.Stop()
 
-					migrator := migrate.NewMigrator(app.DB(), migrations)
-
 					group, err := migrator.Rollback(ctx)
 					if err != nil {
 						return err
@@ -193,13 +367,11 @@ func newDBCommand(migrations *migrate.Migrations) *cli.Command {
 				Name:  "lock",
 				Usage: "lock migrations",
 				Action: func(c *cli.Context) error {
-					ctx, app, err := app.StartCLI(c)
+					ctx, app, migrator, err := startMigrator(c, migrations)
 					if err != nil {
 						return err
 					}
 					defer app.Stop()
-
-					migrator := migrate.NewMigrator(app.DB(), migrations)
 					return migrator.Lock(ctx)
 				},
 			},
@@ -207,13 +379,11 @@ func newDBCommand(migrations *migrate.Migrations) *cli.Command {
 				Name:  "unlock",
 				Usage: "unlock migrations",
 				Action: func(c *cli.Context) error {
-					ctx, app, err := app.StartCLI(c)
+					ctx, app, migrator, err := startMigrator(c, migrations)
 					if err != nil {
 						return err
 					}
 					defer app.Stop()
-
-					migrator := migrate.NewMigrator(app.DB(), migrations)
 					return migrator.Unlock(ctx)
 				},
 			},
@@ -221,14 +391,12 @@ func newDBCommand(migrations *migrate.Migrations) *cli.Command {
 				Name:  "create_go",
 				Usage: "create Go migration",
 				Action: func(c *cli.Context) error {
-					ctx, app, err := app.StartCLI(c)
+					ctx, app, migrator, err := startMigrator(c, migrations)
 					if err != nil {
 						return err
 					}
 					defer app.Stop()
 
-					migrator := migrate.NewMigrator(app.DB(), migrations)
-
 					name := strings.Join(c.Args().Slice(), "_")
 					mf, err := migrator.CreateGoMigration(ctx, name)
 					if err != nil {
@@ -243,14 +411,12 @@ func newDBCommand(migrations *migrate.Migrations) *cli.Command {
 				Name:  "create_sql",
 				Usage: "create up and down SQL migrations",
 				Action: func(c *cli.Context) error {
-					ctx, app, err := app.StartCLI(c)
+					ctx, app, migrator, err := startMigrator(c, migrations)
 					if err != nil {
 						return err
 					}
 					defer app.Stop()
 
-					migrator := migrate.NewMigrator(app.DB(), migrations)
-
 					name := strings.Join(c.Args().Slice(), "_")
 					files, err := migrator.CreateSQLMigrations(ctx, name)
 					if err != nil {
@@ -268,14 +434,12 @@ func newDBCommand(migrations *migrate.Migrations) *cli.Command {
 				Name:  "status",
 				Usage: "print migrations status",
 				Action: func(c *cli.Context) error {
-					ctx, app, err := app.StartCLI(c)
+					ctx, app, migrator, err := startMigrator(c, migrations)
 					if err != nil {
 						return err
 					}
 					defer app.Stop()
 
-					migrator := migrate.NewMigrator(app.DB(), migrations)
-
 					ms, err := migrator.MigrationsWithStatus(ctx)
 					if err != nil {
 						return err
@@ -291,14 +455,12 @@ func newDBCommand(migrations *migrate.Migrations) *cli.Command {
 				Name:  "mark_applied",
 				Usage: "mark migrations as applied without actually running them",
 				Action: func(c *cli.Context) error {
-					ctx, app, err := app.StartCLI(c)
+					ctx, app, migrator, err := startMigrator(c, migrations)
 					if err != nil {
 						return err
 					}
 					defer app.Stop()
 
-					migrator := migrate.NewMigrator(app.DB(), migrations)
-
 					group, err := migrator.Migrate(ctx, migrate.WithNopMigration())
 					if err != nil {
 						return err
@@ -316,3 +478,280 @@ func newDBCommand(migrations *migrate.Migrations) *cli.Command {
 		},
 	}
 }
+
+// startUserService starts the app for a users subcommand and returns the
+// user service on its database.
+func startUserService(c *cli.Context) (context.Context, *app.App, service.IUserService, error) {
+	ctx, a, err := app.StartCLI(c)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if a.Config().UserRepository != "db" {
+		a.Stop()
+		return nil, nil, nil, fmt.Errorf("users are not in the database with USER_REPOSITORY=%s", a.Config().UserRepository)
+	}
+	db, err := a.DB()
+	if err != nil {
+		a.Stop()
+		return nil, nil, nil, err
+	}
+	svc := service.NewUserService(repository.NewUserRepository(db), nil, nil, service.UserServiceOptions{
+		BatchSize: a.Config().DB.BatchSize,
+	})
+	return ctx, a, svc, nil
+}
+
+// userFileFormat returns the --format of a users subcommand, unless it is
+// not set and the extension of path tells the format.
+func userFileFormat(c *cli.Context, path string) string {
+	if !c.IsSet("format") {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".csv":
+			return "csv"
+		case ".ndjson", ".jsonl":
+			return "ndjson"
+		case ".json":
+			return "json"
+		}
+	}
+	return c.String("format")
+}
+
+// parseColumnMap parses --map field=column flags.
+func parseColumnMap(flags []string) (map[string]string, error) {
+	columns := make(map[string]string, len(flags))
+	for _, f := range flags {
+		field, column, ok := strings.Cut(f, "=")
+		if !ok || field == "" || column == "" {
+			return nil, fmt.Errorf("--map %q is not field=column", f)
+		}
+		columns[field] = column
+	}
+	return columns, nil
+}
+
+var usersCommand = &cli.Command{
+	Name:  "users",
+	Usage: "export and import users",
+	Subcommands: []*cli.Command{
+		{
+			Name:  "export",
+			Usage: "write all users, DB_BATCH_SIZE at a time, to stdout or a file",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "format",
+					Value: "csv",
+					Usage: "csv, ndjson or json (default from the --output extension)",
+				},
+				&cli.StringFlag{
+					Name:    "output",
+					Aliases: []string{"o"},
+					Usage:   "file to write instead of stdout",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				path := c.String("output")
+				format := userFileFormat(c, path)
+				ctx, app, svc, err := startUserService(c)
+				if err != nil {
+					return err
+				}
+				defer app.Stop()
+
+				out := os.Stdout
+				if path != "" && path != "-" {
+					if out, err = os.Create(path); err != nil {
+						return err
+					}
+					defer out.Close()
+				}
+				n, err := userfile.Export(ctx, svc, out, format, app.Config().DB.BatchSize)
+				if err != nil {
+					return err
+				}
+				if out != os.Stdout {
+					if err := out.Close(); err != nil {
+						return err
+					}
+				}
+				fmt.Fprintf(os.Stderr, "exported %d users\n", n)
+				return nil
+			},
+		},
+		{
+			Name:      "import",
+			Usage:     "create users from a CSV, NDJSON or JSON file (- for stdin), DB_BATCH_SIZE rows at a time",
+			ArgsUsage: "FILE",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "format",
+					Usage: "csv, ndjson or json (default from the FILE extension)",
+				},
+				&cli.StringSliceFlag{
+					Name:  "map",
+					Usage: "take a field from another column or key, e.g. --map email=mail (repeatable)",
+				},
+				&cli.BoolFlag{
+					Name:  "upsert",
+					Usage: "update the name and profile of users whose email is taken instead of failing the row",
+				},
+				&cli.BoolFlag{
+					Name:  "dry-run",
+					Usage: "check every row and count what importing would do without writing",
+				},
+				&cli.StringFlag{
+					Name:  "report",
+					Usage: "CSV file for the problems of rows (row, column, message) instead of stderr",
+				},
+				&cli.StringFlag{
+					Name:  "checkpoint",
+					Usage: "file recording the rows done, to resume from after a failed run",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				path := c.Args().First()
+				if c.NArg() != 1 {
+					return fmt.Errorf("users import takes one FILE, got %d", c.NArg())
+				}
+				columns, err := parseColumnMap(c.StringSlice("map"))
+				if err != nil {
+					return err
+				}
+				ctx, app, svc, err := startUserService(c)
+				if err != nil {
+					return err
+				}
+				defer app.Stop()
+
+				opts := userfile.ImportOptions{
+					Format:    userFileFormat(c, path),
+					Columns:   columns,
+					Check:     controller.CheckNewUser,
+					Upsert:    c.Bool("upsert"),
+					DryRun:    c.Bool("dry-run"),
+					BatchSize: app.Config().DB.BatchSize,
+					Report:    os.Stderr,
+				}
+				in := os.Stdin
+				if path != "-" {
+					if in, err = os.Open(path); err != nil {
+						return err
+					}
+					defer in.Close()
+				}
+				if report := c.String("report"); report != "" {
+					f, err := os.Create(report)
+					if err != nil {
+						return err
+					}
+					defer f.Close()
+					opts.Report = f
+				}
+
+				checkpoint := c.String("checkpoint")
+				if checkpoint != "" {
+					if path == "-" {
+						return fmt.Errorf("--checkpoint needs a FILE, not stdin")
+					}
+					file, err := filepath.Abs(path)
+					if err != nil {
+						return err
+					}
+					cp, err := userfile.LoadCheckpoint(checkpoint)
+					if err != nil {
+						return err
+					}
+					if cp.File != "" && cp.File != file {
+						return fmt.Errorf("checkpoint %s is for %s, not %s", checkpoint, cp.File, file)
+					}
+					opts.Skip = cp.Rows
+					if !opts.DryRun {
+						opts.Checkpoint = func(rows int) error {
+							return userfile.Checkpoint{File: file, Rows: rows}.Save(checkpoint)
+						}
+					}
+				}
+
+				stats, err := userfile.Import(ctx, svc, in, opts)
+				summary := "%d rows: %d created, %d updated, %d unchanged, %d failed, %d skipped\n"
+				if opts.DryRun {
+					summary = "dry run, nothing written; " + summary
+				}
+				fmt.Printf(summary, stats.Rows, stats.Created, stats.Updated, stats.Unchanged, stats.Failed, stats.Skipped)
+				if err != nil {
+					return err
+				}
+				if opts.Checkpoint != nil {
+					if err := os.Remove(checkpoint); err != nil && !errors.Is(err, os.ErrNotExist) {
+						return err
+					}
+				}
+				if stats.Failed > 0 {
+					return fmt.Errorf("%d rows failed, see the report", stats.Failed)
+				}
+				return nil
+			},
+		},
+	},
+}
+
+var configCommand = &cli.Command{
+	Name:  "config",
+	Usage: "inspect the configuration",
+	Subcommands: []*cli.Command{
+		{
+			Name:  "print",
+			Usage: "print the effective configuration with secrets redacted",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "format",
+					Value: "yaml",
+					Usage: "yaml or env",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				cfg, err := app.LoadCLIConfig(c)
+				if err != nil {
+					return err
+				}
+				return app.WriteConfig(os.Stdout, cfg, c.String("format"))
+			},
+		},
+		{
+			Name:  "validate",
+			Usage: "check the configuration and report every problem",
+			Action: func(c *cli.Context) error {
+				cfg, err := app.LoadCLIConfig(c)
+				if err != nil {
+					return err
+				}
+				if err := cfg.Validate(); err != nil {
+					return err
+				}
+				fmt.Println("configuration is valid")
+				return nil
+			},
+		},
+		{
+			Name:  "schema",
+			Usage: "print the JSON Schema of all settings",
+			Action: func(c *cli.Context) error {
+				return app.WriteConfigSchema(os.Stdout, app.ConfigSchema())
+			},
+		},
+		{
+			Name:  "env-example",
+			Usage: "print .env.example generated from the schema",
+			Action: func(c *cli.Context) error {
+				return app.WriteEnvExample(os.Stdout, app.ConfigSchema())
+			},
+		},
+		{
+			Name:  "docs",
+			Usage: "print the Markdown settings reference generated from the schema",
+			Action: func(c *cli.Context) error {
+				return app.WriteConfigDocs(os.Stdout, app.ConfigSchema())
+			},
+		},
+	},
+}
@@ -2,15 +2,23 @@ package migrations
 
 import (
 	"context"
-	"user-management/internal/user-management/infrastructure/model"
 
 	"github.com/uptrace/bun"
 )
 
+// usersV1 is the users table as this migration creates it; later
+// migrations add to model.User.
+type usersV1 struct {
+	bun.BaseModel `bun:"table:users"`
+	ID            int64 `bun:",pk,autoincrement"`
+	Name          string
+	Email         string
+}
+
 func init() {
 	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
 		_, err := db.NewCreateTable().
-			Model((*model.User)(nil)).
+			Model((*usersV1)(nil)).
 			Exec(ctx)
 		if err != nil {
 			panic(err)
@@ -18,7 +26,7 @@ func init() {
 
 		return nil
 	}, func(ctx context.Context, db *bun.DB) error {
-		_, err := db.NewDropTable().Model((*model.User)(nil)).IfExists().Exec(ctx)
+		_, err := db.NewDropTable().Model((*usersV1)(nil)).IfExists().Exec(ctx)
 		if err != nil {
 			panic(err)
 		}
@@ -0,0 +1,68 @@
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// webhookSubscriptionsV1 and webhookDeliveriesV1 are the webhook tables as
+// this migration creates them; later migrations add to
+// model.WebhookSubscription and model.WebhookDelivery.
+type webhookSubscriptionsV1 struct {
+	bun.BaseModel `bun:"table:webhook_subscriptions"`
+	ID            int64  `bun:",pk,autoincrement"`
+	URL           string `bun:",notnull"`
+	Events        string `bun:",notnull"`
+	Secret        string `bun:",notnull"`
+	Active        bool   `bun:",notnull"`
+	CreatedAt     time.Time
+}
+
+type webhookDeliveriesV1 struct {
+	bun.BaseModel  `bun:"table:webhook_deliveries"`
+	ID             int64  `bun:",pk,autoincrement"`
+	SubscriptionID int64  `bun:",notnull"`
+	EventType      string `bun:",notnull"`
+	Payload        string `bun:",type:text,notnull"`
+	Status         string `bun:",notnull"`
+	Attempts       int    `bun:",notnull"`
+	LastError      string `bun:",type:text"`
+	LastStatusCode int
+	NextAttemptAt  time.Time
+	DeliveredAt    bun.NullTime
+	CreatedAt      time.Time
+}
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		_, err := db.NewCreateTable().
+			Model((*webhookSubscriptionsV1)(nil)).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		_, err = db.NewCreateTable().
+			Model((*webhookDeliveriesV1)(nil)).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		_, err = db.NewCreateIndex().
+			Model((*webhookDeliveriesV1)(nil)).
+			Index("webhook_deliveries_due_idx").
+			Column("status", "next_attempt_at").
+			Exec(ctx)
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.NewDropTable().Model((*webhookDeliveriesV1)(nil)).IfExists().Exec(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = db.NewDropTable().Model((*webhookSubscriptionsV1)(nil)).IfExists().Exec(ctx)
+		return err
+	})
+}
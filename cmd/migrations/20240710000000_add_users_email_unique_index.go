@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"context"
+	"user-management/internal/user-management/infrastructure/model"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		_, err := db.NewCreateIndex().
+			Model((*model.User)(nil)).
+			Unique().
+			Index("users_email_uniq").
+			Column("email").
+			Exec(ctx)
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		if db.Dialect().Name() == dialect.MySQL {
+			_, err := db.ExecContext(ctx, "ALTER TABLE users DROP INDEX users_email_uniq")
+			return err
+		}
+		_, err := db.NewDropIndex().
+			Model((*model.User)(nil)).
+			Index("users_email_uniq").
+			IfExists().
+			Exec(ctx)
+		return err
+	})
+}
@@ -0,0 +1,26 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// The FULLTEXT index behind user search on MySQL. Other databases search
+// in process and get no index.
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if db.Dialect().Name() != dialect.MySQL {
+			return nil
+		}
+		_, err := db.ExecContext(ctx, "ALTER TABLE users ADD FULLTEXT INDEX users_search_ft (name, email)")
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		if db.Dialect().Name() != dialect.MySQL {
+			return nil
+		}
+		_, err := db.ExecContext(ctx, "ALTER TABLE users DROP INDEX users_search_ft")
+		return err
+	})
+}
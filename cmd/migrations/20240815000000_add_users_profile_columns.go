@@ -0,0 +1,38 @@
+package migrations
+
+import (
+	"context"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+// usersProfileColumns are the optional profile columns of model.User.
+var usersProfileColumns = []string{
+	"display_name VARCHAR(100)",
+	"phone VARCHAR(16)",
+	"locale VARCHAR(35)",
+	"timezone VARCHAR(64)",
+	"avatar_url VARCHAR(2048)",
+	"birthdate DATE",
+}
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		// One column per statement, as SQLite adds no more at a time.
+		for _, column := range usersProfileColumns {
+			if _, err := db.NewAddColumn().Table("users").ColumnExpr(column).Exec(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		for i := len(usersProfileColumns) - 1; i >= 0; i-- {
+			name, _, _ := strings.Cut(usersProfileColumns[i], " ")
+			if _, err := db.NewDropColumn().Table("users").Column(name).Exec(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
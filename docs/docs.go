@@ -15,23 +15,865 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/admin/webhooks/deliveries": {
+            "get": {
+                "description": "Inspect webhook deliveries, newest first, optionally filtered by status",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks-admin"
+                ],
+                "summary": "List webhook deliveries",
+                "parameters": [
+                    {
+                        "enum": [
+                            "pending",
+                            "succeeded",
+                            "dead"
+                        ],
+                        "type": "string",
+                        "description": "Delivery status",
+                        "name": "status",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/user-management_internal_user-management_domain_entities.WebhookDelivery"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid status",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/webhooks/deliveries/{id}": {
+            "get": {
+                "description": "Inspect a single webhook delivery, including its payload and last error",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks-admin"
+                ],
+                "summary": "Get webhook delivery",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Delivery ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/user-management_internal_user-management_domain_entities.WebhookDelivery"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid delivery ID",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Delivery not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/webhooks/deliveries/{id}/replay": {
+            "post": {
+                "description": "Queue a delivery again with a fresh attempt budget, e.g. after it was dead-lettered",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks-admin"
+                ],
+                "summary": "Replay webhook delivery",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Delivery ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/user-management_internal_user-management_domain_entities.WebhookDelivery"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid delivery ID",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Delivery not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
         "/users": {
             "get": {
-                "description": "Get a list of all users",
+                "description": "Get a list of users in ID order, optionally filtered and paginated",
+                "produces": [
+                    "application/json",
+                    "application/msgpack",
+                    "text/csv"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "List users",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Case-insensitive substring of the name",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Exact email",
+                        "name": "email",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of users",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of users to skip",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/internal_user-management_domain_controller.UserResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid filter",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "406": {
+                        "description": "Requested media type not available",
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Register a new user in the system. The response has what ipinfo knows\nabout the client's address, when the lookup succeeds.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/msgpack"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Create a new user",
+                "parameters": [
+                    {
+                        "description": "User info",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.CreateUserRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.CreateUserResponse"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "/users/{id} of the new user"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.problem"
+                        }
+                    },
+                    "406": {
+                        "description": "Requested media type not available",
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Email already registered",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "413": {
+                        "description": "Request body too large",
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.problem"
+                        }
+                    },
+                    "415": {
+                        "description": "Content-Type is not application/json",
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/users/events": {
+            "get": {
+                "description": "Server-Sent Events feed of user.created, user.updated and user.deleted events.\nReconnecting clients resume from the Last-Event-ID header (or lastEventId query\nparameter) as long as the event is still in the replay buffer.",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Stream user changes",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Comma-separated event types to receive",
+                        "name": "types",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Resume after this event ID",
+                        "name": "lastEventId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Resume after this event ID",
+                        "name": "Last-Event-ID",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Event stream",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid event type or event ID",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "503": {
+                        "description": "Service is shutting down",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/users/export": {
+            "get": {
+                "description": "All users in ID order, filtered as GET /users, streamed from the database as they\nare read, so any number of them takes the same memory. What is written is sent\nevery HTTP_EXPORT_FLUSH_INTERVAL. The export is cut off after HTTP_EXPORT_TIMEOUT\n(or when the client goes away); once rows have been sent, a failure aborts the\nconnection, so a truncated export never looks complete.",
+                "produces": [
+                    "application/x-ndjson",
+                    "text/csv"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Export users",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Case-insensitive substring of the name",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Exact email",
+                        "name": "email",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of users",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of users to skip",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "A JSON object per line, or CSV with a header row and the profile fields as columns",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/internal_user-management_domain_controller.UserResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid filter",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "406": {
+                        "description": "Requested media type not available",
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "503": {
+                        "description": "Timed out before the first user",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/users/search": {
+            "get": {
+                "description": "Find users by words of their name or email, such as part of a name or a misspelled\nemail. Every word has to match; hits are ranked best first, with the parts that\nmatched highlighted. On MySQL the FULLTEXT index matches word starts, falling back\nto close matches when it finds nothing (USER_SEARCH_FUZZY_FALLBACK).",
+                "produces": [
+                    "application/json",
+                    "application/msgpack"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Search users",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Words to look for",
+                        "name": "q",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Hits per page, up to 100",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of hits to skip",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.UserSearchResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query",
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.problem"
+                        }
+                    },
+                    "406": {
+                        "description": "Requested media type not available",
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/users/{id}": {
+            "get": {
+                "description": "Retrieve a single user by their ID",
+                "produces": [
+                    "application/json",
+                    "application/msgpack"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Get user by ID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.UserResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid user ID",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "406": {
+                        "description": "Requested media type not available",
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Replace an existing user's information. Without a profile the stored one is\nkept; a profile replaces the stored one whole. The response is the user as stored.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/msgpack"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Update user",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "User data",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.UpdateUserRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.UserResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.problem"
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "406": {
+                        "description": "Requested media type not available",
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Email already registered",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "413": {
+                        "description": "Request body too large",
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.problem"
+                        }
+                    },
+                    "415": {
+                        "description": "Content-Type is not application/json",
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Remove a user by ID",
+                "tags": [
+                    "users"
+                ],
+                "summary": "Delete user",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid user ID",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/users:batch": {
+            "put": {
+                "description": "Replace up to USER_BATCH_MAX_ITEMS users, each item naming the user by its ID.\nEvery item gets the status it would have had on its own (200 when updated) with\nthe user or a problem. With transactional, either all users are updated or none\nis; when an item fails, the response has its status and the other items get 424.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/msgpack"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Update users in bulk",
+                "parameters": [
+                    {
+                        "description": "Users",
+                        "name": "batch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.BatchUpdateUsersRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.BatchResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Transactional batch failed, a user does not exist",
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.BatchResponse"
+                        }
+                    },
+                    "406": {
+                        "description": "Requested media type not available",
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Transactional batch failed, an email is already registered",
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.BatchResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request body too large",
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.problem"
+                        }
+                    },
+                    "415": {
+                        "description": "Content-Type is not application/json",
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Create up to USER_BATCH_MAX_ITEMS users, DB_BATCH_SIZE per statement and without\nthe ipinfo lookup of POST /users. Every item gets the status it would have had on\nits own (201 when created) with the user or a problem. With transactional, either\nall users are created or none is; when an item fails, the response has its status\nand the other items get 424.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/msgpack"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Create users in bulk",
+                "parameters": [
+                    {
+                        "description": "Users",
+                        "name": "batch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.BatchCreateUsersRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.BatchResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.problem"
+                        }
+                    },
+                    "406": {
+                        "description": "Requested media type not available",
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Transactional batch failed, an email is already registered",
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.BatchResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request body too large",
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.problem"
+                        }
+                    },
+                    "415": {
+                        "description": "Content-Type is not application/json",
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete up to USER_BATCH_MAX_ITEMS users by ID, DB_BATCH_SIZE per statement. Every\nitem gets the status it would have had on its own (204 when deleted, 404 when\nthere is no such user). With transactional, either all users are deleted or none\nis; when an item fails, the response has its status and the other items get 424.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/msgpack"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Delete users in bulk",
+                "parameters": [
+                    {
+                        "description": "User IDs",
+                        "name": "batch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.BatchDeleteUsersRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.BatchResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Transactional batch failed, a user does not exist",
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.BatchResponse"
+                        }
+                    },
+                    "406": {
+                        "description": "Requested media type not available",
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.problem"
+                        }
+                    },
+                    "413": {
+                        "description": "Request body too large",
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.problem"
+                        }
+                    },
+                    "415": {
+                        "description": "Content-Type is not application/json",
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks": {
+            "get": {
+                "description": "Get all webhook subscriptions. Secrets are not returned.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "users"
+                    "webhooks"
                 ],
-                "summary": "List users",
+                "summary": "List webhook subscriptions",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/user-management_internal_user-management_domain_entities.User"
+                                "$ref": "#/definitions/user-management_internal_user-management_domain_entities.WebhookSubscription"
                             }
                         }
                     },
@@ -44,7 +886,7 @@ const docTemplate = `{
                 }
             },
             "post": {
-                "description": "Register a new user in the system",
+                "description": "Subscribe a URL to user events. The secret is used to sign every delivery.",
                 "consumes": [
                     "application/json"
                 ],
@@ -52,17 +894,17 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "users"
+                    "webhooks"
                 ],
-                "summary": "Create a new user",
+                "summary": "Create a webhook subscription",
                 "parameters": [
                     {
-                        "description": "User info",
-                        "name": "user",
+                        "description": "Subscription",
+                        "name": "subscription",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/user-management_internal_user-management_domain_entities.User"
+                            "$ref": "#/definitions/user-management_internal_user-management_domain_entities.WebhookSubscription"
                         }
                     }
                 ],
@@ -70,16 +912,25 @@ const docTemplate = `{
                     "201": {
                         "description": "Created",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "integer"
-                            }
+                            "$ref": "#/definitions/user-management_internal_user-management_domain_entities.WebhookSubscription"
                         }
                     },
                     "400": {
                         "description": "Invalid request",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/internal_user-management_domain_controller.problem"
+                        }
+                    },
+                    "413": {
+                        "description": "Request body too large",
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.problem"
+                        }
+                    },
+                    "415": {
+                        "description": "Content-Type is not application/json",
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.problem"
                         }
                     },
                     "500": {
@@ -91,20 +942,20 @@ const docTemplate = `{
                 }
             }
         },
-        "/users/{id}": {
+        "/webhooks/{id}": {
             "get": {
-                "description": "Retrieve a single user by their ID",
+                "description": "Retrieve a single webhook subscription. The secret is not returned.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "users"
+                    "webhooks"
                 ],
-                "summary": "Get user by ID",
+                "summary": "Get webhook subscription",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "User ID",
+                        "description": "Subscription ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -114,17 +965,17 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/user-management_internal_user-management_domain_entities.User"
+                            "$ref": "#/definitions/user-management_internal_user-management_domain_entities.WebhookSubscription"
                         }
                     },
                     "400": {
-                        "description": "Invalid user ID",
+                        "description": "Invalid subscription ID",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "404": {
-                        "description": "User not found",
+                        "description": "Subscription not found",
                         "schema": {
                             "type": "string"
                         }
@@ -132,29 +983,29 @@ const docTemplate = `{
                 }
             },
             "put": {
-                "description": "Update an existing user's information",
+                "description": "Replace the URL, events, secret and active flag of a subscription",
                 "consumes": [
                     "application/json"
                 ],
                 "tags": [
-                    "users"
+                    "webhooks"
                 ],
-                "summary": "Update user",
+                "summary": "Update webhook subscription",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "User ID",
+                        "description": "Subscription ID",
                         "name": "id",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "description": "User data",
-                        "name": "user",
+                        "description": "Subscription",
+                        "name": "subscription",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/user-management_internal_user-management_domain_entities.User"
+                            "$ref": "#/definitions/user-management_internal_user-management_domain_entities.WebhookSubscription"
                         }
                     }
                 ],
@@ -167,10 +1018,28 @@ const docTemplate = `{
                     },
                     "400": {
                         "description": "Invalid input",
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Subscription not found",
                         "schema": {
                             "type": "string"
                         }
                     },
+                    "413": {
+                        "description": "Request body too large",
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.problem"
+                        }
+                    },
+                    "415": {
+                        "description": "Content-Type is not application/json",
+                        "schema": {
+                            "$ref": "#/definitions/internal_user-management_domain_controller.problem"
+                        }
+                    },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
@@ -180,15 +1049,15 @@ const docTemplate = `{
                 }
             },
             "delete": {
-                "description": "Remove a user by ID",
+                "description": "Remove a webhook subscription by ID",
                 "tags": [
-                    "users"
+                    "webhooks"
                 ],
-                "summary": "Delete user",
+                "summary": "Delete webhook subscription",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "User ID",
+                        "description": "Subscription ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -202,7 +1071,13 @@ const docTemplate = `{
                         }
                     },
                     "400": {
-                        "description": "Invalid user ID",
+                        "description": "Invalid subscription ID",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Subscription not found",
                         "schema": {
                             "type": "string"
                         }
@@ -218,16 +1093,486 @@ const docTemplate = `{
         }
     },
     "definitions": {
-        "user-management_internal_user-management_domain_entities.User": {
+        "internal_user-management_domain_controller.BatchCreateUsersRequest": {
+            "type": "object",
+            "required": [
+                "items"
+            ],
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "$ref": "#/definitions/internal_user-management_domain_controller.CreateUserRequest"
+                    }
+                },
+                "transactional": {
+                    "type": "boolean",
+                    "example": false
+                }
+            }
+        },
+        "internal_user-management_domain_controller.BatchDeleteUsersRequest": {
+            "type": "object",
+            "required": [
+                "ids"
+            ],
+            "properties": {
+                "ids": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "type": "integer"
+                    },
+                    "example": [
+                        1,
+                        2
+                    ]
+                },
+                "transactional": {
+                    "type": "boolean",
+                    "example": false
+                }
+            }
+        },
+        "internal_user-management_domain_controller.BatchItemResult": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "$ref": "#/definitions/user-management_internal_user-management_helper.Problem"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "index": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "status": {
+                    "type": "integer",
+                    "example": 201
+                },
+                "user": {
+                    "$ref": "#/definitions/internal_user-management_domain_controller.UserResponse"
+                }
+            }
+        },
+        "internal_user-management_domain_controller.BatchResponse": {
+            "type": "object",
+            "properties": {
+                "failed": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/internal_user-management_domain_controller.BatchItemResult"
+                    }
+                },
+                "succeeded": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "internal_user-management_domain_controller.BatchUpdateUserItem": {
+            "type": "object",
+            "required": [
+                "email",
+                "id",
+                "name"
+            ],
+            "properties": {
+                "email": {
+                    "type": "string",
+                    "example": "aren@example.com"
+                },
+                "id": {
+                    "type": "integer",
+                    "minimum": 1,
+                    "example": 1
+                },
+                "name": {
+                    "type": "string",
+                    "minLength": 2,
+                    "example": "Aren"
+                },
+                "profile": {
+                    "$ref": "#/definitions/internal_user-management_domain_controller.UserProfile"
+                }
+            }
+        },
+        "internal_user-management_domain_controller.BatchUpdateUsersRequest": {
+            "type": "object",
+            "required": [
+                "items"
+            ],
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "$ref": "#/definitions/internal_user-management_domain_controller.BatchUpdateUserItem"
+                    }
+                },
+                "transactional": {
+                    "type": "boolean",
+                    "example": false
+                }
+            }
+        },
+        "internal_user-management_domain_controller.CreateUserRequest": {
+            "type": "object",
+            "required": [
+                "email",
+                "name"
+            ],
+            "properties": {
+                "email": {
+                    "type": "string",
+                    "example": "aren@example.com"
+                },
+                "name": {
+                    "type": "string",
+                    "minLength": 2,
+                    "example": "Aren"
+                },
+                "profile": {
+                    "$ref": "#/definitions/internal_user-management_domain_controller.UserProfile"
+                }
+            }
+        },
+        "internal_user-management_domain_controller.CreateUserResponse": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string",
+                    "example": "aren@example.com"
+                },
+                "geo": {
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Aren"
+                },
+                "profile": {
+                    "$ref": "#/definitions/internal_user-management_domain_controller.UserProfile"
+                }
+            }
+        },
+        "internal_user-management_domain_controller.UpdateUserRequest": {
+            "type": "object",
+            "required": [
+                "email",
+                "name"
+            ],
+            "properties": {
+                "email": {
+                    "type": "string",
+                    "example": "aren@example.com"
+                },
+                "name": {
+                    "type": "string",
+                    "minLength": 2,
+                    "example": "Aren"
+                },
+                "profile": {
+                    "$ref": "#/definitions/internal_user-management_domain_controller.UserProfile"
+                }
+            }
+        },
+        "internal_user-management_domain_controller.UserProfile": {
+            "type": "object",
+            "properties": {
+                "avatar_url": {
+                    "type": "string",
+                    "maxLength": 2048,
+                    "example": "https://example.com/aren.png"
+                },
+                "birthdate": {
+                    "description": "Birthdate is a date at least 13 years ago.",
+                    "type": "string",
+                    "format": "date",
+                    "example": "1990-04-01"
+                },
+                "display_name": {
+                    "type": "string",
+                    "maxLength": 100,
+                    "example": "Aren D."
+                },
+                "locale": {
+                    "description": "Locale is a BCP 47 language tag, returned in canonical form.",
+                    "type": "string",
+                    "maxLength": 35,
+                    "example": "en-US"
+                },
+                "phone": {
+                    "description": "Phone is in E.164 form.",
+                    "type": "string",
+                    "example": "+14155550123"
+                },
+                "timezone": {
+                    "description": "Timezone is an IANA time zone name.",
+                    "type": "string",
+                    "maxLength": 64,
+                    "example": "Europe/Berlin"
+                }
+            }
+        },
+        "internal_user-management_domain_controller.UserResponse": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string",
+                    "example": "aren@example.com"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Aren"
+                },
+                "profile": {
+                    "$ref": "#/definitions/internal_user-management_domain_controller.UserProfile"
+                }
+            }
+        },
+        "internal_user-management_domain_controller.UserSearchHit": {
             "type": "object",
             "properties": {
                 "email": {
+                    "type": "string",
+                    "example": "aren@example.com"
+                },
+                "highlights": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    },
+                    "example": {
+                        "name": "\u003cem\u003eAre\u003c/em\u003en"
+                    }
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Aren"
+                },
+                "profile": {
+                    "$ref": "#/definitions/internal_user-management_domain_controller.UserProfile"
+                },
+                "score": {
+                    "type": "number",
+                    "example": 0.9
+                }
+            }
+        },
+        "internal_user-management_domain_controller.UserSearchResponse": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/internal_user-management_domain_controller.UserSearchHit"
+                    }
+                },
+                "limit": {
+                    "type": "integer",
+                    "example": 20
+                },
+                "offset": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "total": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "internal_user-management_domain_controller.problem": {
+            "type": "object",
+            "properties": {
+                "detail": {
+                    "type": "string"
+                },
+                "errors": {
+                    "description": "Errors lists what is wrong with each field of an invalid request.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/user-management_internal_user-management_helper.FieldError"
+                    }
+                },
+                "instance": {
+                    "type": "string"
+                },
+                "request_id": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "integer"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "user-management_internal_user-management_domain_entities.UserEventType": {
+            "type": "string",
+            "enum": [
+                "user.created",
+                "user.updated",
+                "user.deleted"
+            ],
+            "x-enum-varnames": [
+                "UserCreated",
+                "UserUpdated",
+                "UserDeleted"
+            ]
+        },
+        "user-management_internal_user-management_domain_entities.WebhookDelivery": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "delivered_at": {
                     "type": "string"
                 },
+                "event_type": {
+                    "$ref": "#/definitions/user-management_internal_user-management_domain_entities.UserEventType"
+                },
                 "id": {
                     "type": "integer"
                 },
-                "name": {
+                "last_error": {
+                    "type": "string"
+                },
+                "last_status_code": {
+                    "type": "integer"
+                },
+                "next_attempt_at": {
+                    "type": "string"
+                },
+                "payload": {
+                    "type": "object"
+                },
+                "status": {
+                    "$ref": "#/definitions/user-management_internal_user-management_domain_entities.WebhookDeliveryStatus"
+                },
+                "subscription_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "user-management_internal_user-management_domain_entities.WebhookDeliveryStatus": {
+            "type": "string",
+            "enum": [
+                "pending",
+                "succeeded",
+                "dead"
+            ],
+            "x-enum-varnames": [
+                "DeliveryPending",
+                "DeliverySucceeded",
+                "DeliveryDead"
+            ]
+        },
+        "user-management_internal_user-management_domain_entities.WebhookSubscription": {
+            "type": "object",
+            "required": [
+                "events",
+                "secret",
+                "url"
+            ],
+            "properties": {
+                "active": {
+                    "type": "boolean"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "events": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "secret": {
+                    "type": "string",
+                    "minLength": 16
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "user-management_internal_user-management_helper.FieldError": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "param": {
+                    "type": "string"
+                },
+                "rule": {
+                    "type": "string"
+                }
+            }
+        },
+        "user-management_internal_user-management_helper.Problem": {
+            "type": "object",
+            "properties": {
+                "detail": {
+                    "type": "string"
+                },
+                "errors": {
+                    "description": "Errors lists what is wrong with each field of an invalid request.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/user-management_internal_user-management_helper.FieldError"
+                    }
+                },
+                "instance": {
+                    "type": "string"
+                },
+                "request_id": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "integer"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "type": {
                     "type": "string"
                 }
             }
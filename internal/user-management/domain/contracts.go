@@ -1,12 +1,58 @@
 package domain
 
-import entity "user-management/internal/user-management/domain/entities"
+import (
+	"context"
+	"time"
 
+	entity "user-management/internal/user-management/domain/entities"
+)
 
+// IUserRepository stores users. Implementations return a helper.NotFound
+// BusinessError for unknown IDs and a helper.AlreadyExists one when an email
+// is already taken, and list users in ID order.
 type IUserRepository interface {
-	Create(user entity.User) (int64, error)
-	GetAll() ([]entity.User, error)
-	GetByID(id int64) (entity.User, error)
-	Update(user entity.User) error
-	Delete(id int64) error
+	Create(ctx context.Context, user entity.User) (int64, error)
+	// CreateMany creates users in one statement, so either all of them or
+	// none, and returns their IDs in order.
+	CreateMany(ctx context.Context, users []entity.User) ([]int64, error)
+	GetAll(ctx context.Context, filter entity.UserFilter) ([]entity.User, error)
+	// Stream calls fn with the users GetAll would return, reading them
+	// one at a time, and stops at the first error fn returns. A database
+	// connection is held until it returns.
+	Stream(ctx context.Context, filter entity.UserFilter, fn func(user entity.User) error) error
+	GetByID(ctx context.Context, id int64) (entity.User, error)
+	Update(ctx context.Context, user entity.User) error
+	Delete(ctx context.Context, id int64) error
+	// DeleteMany deletes the users with the given IDs and returns the IDs
+	// of those that existed.
+	DeleteMany(ctx context.Context, ids []int64) ([]int64, error)
+	// RunInTx runs fn so that the changes made with the ctx passed to fn
+	// are all kept or, when fn fails, all undone.
+	RunInTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// IUserSearcher finds users by free text, ranked by how well they match.
+type IUserSearcher interface {
+	Search(ctx context.Context, query entity.UserSearchQuery) (entity.UserSearchResult, error)
+}
+
+type IUserEventPublisher interface {
+	// Publish is called once the change is stored; ctx is that of the
+	// operation, which may be canceled already.
+	Publish(ctx context.Context, event entity.UserEvent)
+}
+
+type IWebhookRepository interface {
+	CreateSubscription(ctx context.Context, sub entity.WebhookSubscription) (int64, error)
+	GetSubscriptions(ctx context.Context) ([]entity.WebhookSubscription, error)
+	GetSubscriptionByID(ctx context.Context, id int64) (entity.WebhookSubscription, error)
+	GetActiveSubscriptions(ctx context.Context) ([]entity.WebhookSubscription, error)
+	UpdateSubscription(ctx context.Context, sub entity.WebhookSubscription) error
+	DeleteSubscription(ctx context.Context, id int64) error
+
+	CreateDelivery(ctx context.Context, delivery entity.WebhookDelivery) (int64, error)
+	GetDeliveries(ctx context.Context, status entity.WebhookDeliveryStatus) ([]entity.WebhookDelivery, error)
+	GetDeliveryByID(ctx context.Context, id int64) (entity.WebhookDelivery, error)
+	GetDueDeliveries(ctx context.Context, now time.Time, limit int) ([]entity.WebhookDelivery, error)
+	UpdateDelivery(ctx context.Context, delivery entity.WebhookDelivery) error
 }
@@ -0,0 +1,258 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	entity "user-management/internal/user-management/domain/entities"
+	"user-management/internal/user-management/domain/service"
+	"user-management/internal/user-management/helper"
+
+	"github.com/rs/zerolog"
+)
+
+// CreateUsers godoc
+// @Summary      Create users in bulk
+// @Description  Create up to USER_BATCH_MAX_ITEMS users, DB_BATCH_SIZE per statement and without
+// @Description  the ipinfo lookup of POST /users. Every item gets the status it would have had on
+// @Description  its own (201 when created) with the user or a problem. With transactional, either
+// @Description  all users are created or none is; when an item fails, the response has its status
+// @Description  and the other items get 424.
+// @Tags         users
+// @Accept       json
+// @Produce      json,application/msgpack
+// @Param        batch  body      BatchCreateUsersRequest  true  "Users"
+// @Success      200    {object}  BatchResponse
+// @Failure      400    {object}  problem        "Invalid request"
+// @Failure      406    {object}  problem        "Requested media type not available"
+// @Failure      409    {object}  BatchResponse  "Transactional batch failed, an email is already registered"
+// @Failure      413    {object}  problem        "Request body too large"
+// @Failure      415    {object}  problem        "Content-Type is not application/json"
+// @Router       /users:batch [post]
+func (c *controller) CreateUsers(w http.ResponseWriter, r *http.Request) {
+	mediaType, ok := negotiate(w, r, objectTypes)
+	if !ok {
+		return
+	}
+
+	var req BatchCreateUsersRequest
+	err := readJSON(r, &req)
+	if err == nil {
+		err = c.checkBatchSize("items", len(req.Items))
+	}
+	if err != nil {
+		writeRequestError(w, r, err)
+		return
+	}
+
+	b := newBatch(len(req.Items), http.StatusCreated)
+	users := make([]entity.User, 0, len(req.Items))
+	for i, item := range req.Items {
+		if b.validate(r, i, item) {
+			users = append(users, item.toEntity())
+		}
+	}
+	if req.Transactional && len(users) < len(req.Items) {
+		b.apply(r, abortedResults(users))
+	} else {
+		b.apply(r, c.userService.CreateUsers(r.Context(), users, req.Transactional))
+	}
+	b.write(w, mediaType, req.Transactional)
+}
+
+// UpdateUsers godoc
+// @Summary      Update users in bulk
+// @Description  Replace up to USER_BATCH_MAX_ITEMS users, each item naming the user by its ID.
+// @Description  Every item gets the status it would have had on its own (200 when updated) with
+// @Description  the user or a problem. With transactional, either all users are updated or none
+// @Description  is; when an item fails, the response has its status and the other items get 424.
+// @Tags         users
+// @Accept       json
+// @Produce      json,application/msgpack
+// @Param        batch  body      BatchUpdateUsersRequest  true  "Users"
+// @Success      200    {object}  BatchResponse
+// @Failure      400    {object}  problem        "Invalid request"
+// @Failure      404    {object}  BatchResponse  "Transactional batch failed, a user does not exist"
+// @Failure      406    {object}  problem        "Requested media type not available"
+// @Failure      409    {object}  BatchResponse  "Transactional batch failed, an email is already registered"
+// @Failure      413    {object}  problem        "Request body too large"
+// @Failure      415    {object}  problem        "Content-Type is not application/json"
+// @Router       /users:batch [put]
+func (c *controller) UpdateUsers(w http.ResponseWriter, r *http.Request) {
+	mediaType, ok := negotiate(w, r, objectTypes)
+	if !ok {
+		return
+	}
+
+	var req BatchUpdateUsersRequest
+	err := readJSON(r, &req)
+	if err == nil {
+		err = c.checkBatchSize("items", len(req.Items))
+	}
+	if err != nil {
+		writeRequestError(w, r, err)
+		return
+	}
+
+	b := newBatch(len(req.Items), http.StatusOK)
+	users := make([]entity.User, 0, len(req.Items))
+	for i, item := range req.Items {
+		if b.validate(r, i, item) {
+			users = append(users, item.toEntity())
+		}
+	}
+	if req.Transactional && len(users) < len(req.Items) {
+		b.apply(r, abortedResults(users))
+	} else {
+		b.apply(r, c.userService.UpdateUsers(r.Context(), users, req.Transactional))
+	}
+	b.write(w, mediaType, req.Transactional)
+}
+
+// DeleteUsers godoc
+// @Summary      Delete users in bulk
+// @Description  Delete up to USER_BATCH_MAX_ITEMS users by ID, DB_BATCH_SIZE per statement. Every
+// @Description  item gets the status it would have had on its own (204 when deleted, 404 when
+// @Description  there is no such user). With transactional, either all users are deleted or none
+// @Description  is; when an item fails, the response has its status and the other items get 424.
+// @Tags         users
+// @Accept       json
+// @Produce      json,application/msgpack
+// @Param        batch  body      BatchDeleteUsersRequest  true  "User IDs"
+// @Success      200    {object}  BatchResponse
+// @Failure      400    {object}  problem        "Invalid request"
+// @Failure      404    {object}  BatchResponse  "Transactional batch failed, a user does not exist"
+// @Failure      406    {object}  problem        "Requested media type not available"
+// @Failure      413    {object}  problem        "Request body too large"
+// @Failure      415    {object}  problem        "Content-Type is not application/json"
+// @Router       /users:batch [delete]
+func (c *controller) DeleteUsers(w http.ResponseWriter, r *http.Request) {
+	mediaType, ok := negotiate(w, r, objectTypes)
+	if !ok {
+		return
+	}
+
+	var req BatchDeleteUsersRequest
+	err := readJSON(r, &req)
+	if err == nil {
+		err = c.checkBatchSize("ids", len(req.IDs))
+	}
+	if err != nil {
+		writeRequestError(w, r, err)
+		return
+	}
+
+	b := newBatch(len(req.IDs), http.StatusNoContent)
+	for i := range req.IDs {
+		b.accept(i)
+	}
+	b.apply(r, c.userService.DeleteUsers(r.Context(), req.IDs, req.Transactional))
+	b.write(w, mediaType, req.Transactional)
+}
+
+// checkBatchSize fails when the list field of a batch request has more
+// than MaxBatchItems items.
+func (c *controller) checkBatchSize(field string, n int) error {
+	if n <= c.opts.MaxBatchItems {
+		return nil
+	}
+	e := badRequest("a batch must not have more than %d items", c.opts.MaxBatchItems)
+	e.fields = []helper.FieldError{{
+		Field:   field,
+		Rule:    "max",
+		Param:   strconv.Itoa(c.opts.MaxBatchItems),
+		Message: fmt.Sprintf("%s must contain at most %d items", field, c.opts.MaxBatchItems),
+	}}
+	return e
+}
+
+// batch collects the results of the items of a batch request. Invalid
+// items get their problem from validate; the results of the service are
+// for the valid ones, in order.
+type batch struct {
+	okStatus int
+	items    []BatchItemResult
+	valid    []int
+}
+
+func newBatch(n, okStatus int) *batch {
+	b := &batch{okStatus: okStatus, items: make([]BatchItemResult, n), valid: make([]int, 0, n)}
+	for i := range b.items {
+		b.items[i].Index = i
+	}
+	return b
+}
+
+// validate checks item, the one at index i, and reports whether it is
+// valid.
+func (b *batch) validate(r *http.Request, i int, item any) bool {
+	if err := validateRequest(r, item); err != nil {
+		p := requestProblem(err)
+		b.items[i].Status = p.Status
+		b.items[i].Error = &p
+		return false
+	}
+	b.accept(i)
+	return true
+}
+
+// accept marks the item at index i as valid.
+func (b *batch) accept(i int) {
+	b.valid = append(b.valid, i)
+}
+
+// apply sets the results of the valid items from those of the service.
+func (b *batch) apply(r *http.Request, results []entity.UserBatchResult) {
+	for j, res := range results {
+		i := b.valid[j]
+		item := &b.items[i]
+		item.ID = res.User.ID
+		if res.Err == nil {
+			item.Status = b.okStatus
+			if b.okStatus != http.StatusNoContent {
+				u := toUserResponse(res.User)
+				item.User = &u
+			}
+			continue
+		}
+
+		status := errorStatus(res.Err)
+		detail := helper.Cause(res.Err)
+		if status == http.StatusInternalServerError {
+			zerolog.Ctx(r.Context()).Error().Err(res.Err).Int("index", i).Msg("Batch item failed")
+			detail = ""
+		}
+		p := helper.NewProblem(status, detail)
+		item.Status = status
+		item.Error = &p
+	}
+}
+
+// write sends the results. A failed transactional batch has the status of
+// the item that failed it.
+func (b *batch) write(w http.ResponseWriter, mediaType string, transactional bool) {
+	resp := BatchResponse{Items: b.items}
+	status := http.StatusOK
+	for _, item := range b.items {
+		if item.Error == nil {
+			resp.Succeeded++
+			continue
+		}
+		resp.Failed++
+		if transactional && status == http.StatusOK && item.Status != http.StatusFailedDependency {
+			status = item.Status
+		}
+	}
+	writeResponse(w, mediaType, status, resp)
+}
+
+// abortedResults are the results of the valid users of a transactional
+// batch that has invalid ones too, none of which was applied.
+func abortedResults(users []entity.User) []entity.UserBatchResult {
+	results := make([]entity.UserBatchResult, 0, len(users))
+	for _, u := range users {
+		results = append(results, entity.UserBatchResult{User: u, Err: service.ErrBatchAborted})
+	}
+	return results
+}
@@ -1,97 +1,133 @@
 package controller
 
 import (
-	"encoding/json"
-	"net"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
+
 	entity "user-management/internal/user-management/domain/entities"
 	"user-management/internal/user-management/domain/service"
+	"user-management/internal/user-management/helper"
 
-	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
 )
 
 type controller struct {
 	userService service.IUserService
-	validator   *validator.Validate
+	opts        ControllerOptions
+}
+
+// ControllerOptions tunes the /users handlers.
+type ControllerOptions struct {
+	// MaxBatchItems caps the items of a batch request.
+	MaxBatchItems int
+	// ExportFlushInterval is how often GET /users/export sends what it
+	// has written so far, 1s if not positive.
+	ExportFlushInterval time.Duration
 }
 
-func NewController(userService service.IUserService) *controller {
+// NewController returns the handlers of the /users routes.
+func NewController(userService service.IUserService, opts ControllerOptions) *controller {
+	if opts.ExportFlushInterval <= 0 {
+		opts.ExportFlushInterval = time.Second
+	}
 	return &controller{
 		userService: userService,
-		validator:   validator.New(),
+		opts:        opts,
 	}
 }
 
 // CreateUser godoc
 // @Summary      Create a new user
-// @Description  Register a new user in the system
+// @Description  Register a new user in the system. The response has what ipinfo knows
+// @Description  about the client's address, when the lookup succeeds.
 // @Tags         users
 // @Accept       json
-// @Produce      json
-// @Param        user  body      entity.User  true  "User info"
-// @Success      201   {object}  map[string]interface{}
-// @Failure      400   {string}  string  "Invalid request"
-// @Failure      500   {string}  string  "Internal server error"
+// @Produce      json,application/msgpack
+// @Param        user  body      CreateUserRequest   true  "User info"
+// @Success      201   {object}  CreateUserResponse
+// @Header       201   {string}  Location  "/users/{id} of the new user"
+// @Failure      400   {object}  problem             "Invalid request"
+// @Failure      406   {object}  problem             "Requested media type not available"
+// @Failure      409   {string}  string              "Email already registered"
+// @Failure      413   {object}  problem             "Request body too large"
+// @Failure      415   {object}  problem             "Content-Type is not application/json"
+// @Failure      500   {string}  string              "Internal server error"
 // @Router       /users [post]
 func (c *controller) CreateUser(w http.ResponseWriter, r *http.Request) {
-	var u entity.User
-	ip := r.Header.Get("X-Forwarded-For")
-	if ip == "" {
-		ip = r.Header.Get("X-Real-IP")
-	}
-	if ip == "" {
-		ip, _, _ = net.SplitHostPort(r.RemoteAddr)
-	}
-
-	if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	mediaType, ok := negotiate(w, r, objectTypes)
+	if !ok {
 		return
 	}
 
-	if err := c.validator.Struct(u); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	var req CreateUserRequest
+	if err := readJSON(r, &req); err != nil {
+		writeRequestError(w, r, err)
 		return
 	}
 
-	createdUserInfo, err := c.userService.RegisterUser(u, ip)
+	user, geo, err := c.userService.RegisterUser(r.Context(), req.toEntity(), helper.ClientIP(r))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), errorStatus(err))
 		return
 	}
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(createdUserInfo)
+	w.Header().Set("Location", fmt.Sprintf("/users/%d", user.ID))
+	writeResponse(w, mediaType, http.StatusCreated, CreateUserResponse{UserResponse: toUserResponse(user), Geo: geo})
 }
 
 // GetUsers godoc
 // @Summary      List users
-// @Description  Get a list of all users
+// @Description  Get a list of users in ID order, optionally filtered and paginated
 // @Tags         users
-// @Produce      json
-// @Success      200  {array}   entity.User
-// @Failure      500  {string}  string  "Internal server error"
+// @Produce      json,application/msgpack,text/csv
+// @Param        name    query     string  false  "Case-insensitive substring of the name"
+// @Param        email   query     string  false  "Exact email"
+// @Param        limit   query     int     false  "Maximum number of users"
+// @Param        offset  query     int     false  "Number of users to skip"
+// @Success      200     {array}   UserResponse
+// @Failure      400     {string}  string   "Invalid filter"
+// @Failure      406     {object}  problem  "Requested media type not available"
+// @Failure      500     {string}  string   "Internal server error"
 // @Router       /users [get]
 func (c *controller) GetUsers(w http.ResponseWriter, r *http.Request) {
-	users, err := c.userService.ListUsers()
+	mediaType, ok := negotiate(w, r, listTypes)
+	if !ok {
+		return
+	}
+
+	filter, err := parseUserFilter(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	json.NewEncoder(w).Encode(users)
+
+	users, err := c.userService.ListUsers(r.Context(), filter)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	writeResponse(w, mediaType, http.StatusOK, toUserResponses(users))
 }
 
 // GetUserByID godoc
 // @Summary      Get user by ID
 // @Description  Retrieve a single user by their ID
 // @Tags         users
-// @Produce      json
+// @Produce      json,application/msgpack
 // @Param        id   path      int  true  "User ID"
-// @Success      200  {object}  entity.User
-// @Failure      400  {string}  string  "Invalid user ID"
-// @Failure      404  {string}  string  "User not found"
+// @Success      200  {object}  UserResponse
+// @Failure      400  {string}  string   "Invalid user ID"
+// @Failure      404  {string}  string   "User not found"
+// @Failure      406  {object}  problem  "Requested media type not available"
+// @Failure      500  {string}  string   "Internal server error"
 // @Router       /users/{id} [get]
 func (c *controller) GetUserByID(w http.ResponseWriter, r *http.Request) {
+	mediaType, ok := negotiate(w, r, objectTypes)
+	if !ok {
+		return
+	}
+
 	vars := mux.Vars(r)
 	idStr := vars["id"]
 	id, err := strconv.ParseInt(idStr, 10, 64)
@@ -100,27 +136,39 @@ func (c *controller) GetUserByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := c.userService.GetUserByID(id)
+	user, err := c.userService.GetUserByID(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		http.Error(w, err.Error(), errorStatus(err))
 		return
 	}
 
-	json.NewEncoder(w).Encode(user)
+	writeResponse(w, mediaType, http.StatusOK, toUserResponse(user))
 }
 
 // UpdateUser godoc
 // @Summary      Update user
-// @Description  Update an existing user's information
+// @Description  Replace an existing user's information. Without a profile the stored one is
+// @Description  kept; a profile replaces the stored one whole. The response is the user as stored.
 // @Tags         users
 // @Accept       json
-// @Param        id    path      int          true  "User ID"
-// @Param        user  body      entity.User  true  "User data"
-// @Success      200   {string}  string       "OK"
-// @Failure      400   {string}  string       "Invalid input"
-// @Failure      500   {string}  string       "Internal server error"
+// @Produce      json,application/msgpack
+// @Param        id    path      int                true  "User ID"
+// @Param        user  body      UpdateUserRequest  true  "User data"
+// @Success      200   {object}  UserResponse
+// @Failure      400   {object}  problem            "Invalid input"
+// @Failure      404   {string}  string             "User not found"
+// @Failure      406   {object}  problem            "Requested media type not available"
+// @Failure      409   {string}  string             "Email already registered"
+// @Failure      413   {object}  problem            "Request body too large"
+// @Failure      415   {object}  problem            "Content-Type is not application/json"
+// @Failure      500   {string}  string             "Internal server error"
 // @Router       /users/{id} [put]
 func (c *controller) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	mediaType, ok := negotiate(w, r, objectTypes)
+	if !ok {
+		return
+	}
+
 	vars := mux.Vars(r)
 	idStr := vars["id"]
 	id, err := strconv.ParseInt(idStr, 10, 64)
@@ -129,25 +177,19 @@ func (c *controller) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var user entity.User
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	var req UpdateUserRequest
+	if err := readJSON(r, &req); err != nil {
+		writeRequestError(w, r, err)
 		return
 	}
 
-	if err := c.validator.Struct(user); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	user.ID = id
-
-	if err := c.userService.UpdateUser(user); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	user, err := c.userService.UpdateUser(r.Context(), req.toEntity(id))
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	writeResponse(w, mediaType, http.StatusOK, toUserResponse(user))
 }
 
 // DeleteUser godoc
@@ -157,6 +199,7 @@ func (c *controller) UpdateUser(w http.ResponseWriter, r *http.Request) {
 // @Param        id   path      int  true  "User ID"
 // @Success      204  {string}  string  "No Content"
 // @Failure      400  {string}  string  "Invalid user ID"
+// @Failure      404  {string}  string  "User not found"
 // @Failure      500  {string}  string  "Internal server error"
 // @Router       /users/{id} [delete]
 func (c *controller) DeleteUser(w http.ResponseWriter, r *http.Request) {
@@ -168,10 +211,30 @@ func (c *controller) DeleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := c.userService.DeleteUser(id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := c.userService.DeleteUser(r.Context(), id); err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+func parseUserFilter(r *http.Request) (entity.UserFilter, error) {
+	q := r.URL.Query()
+	filter := entity.UserFilter{
+		Name:  q.Get("name"),
+		Email: q.Get("email"),
+	}
+	for key, dst := range map[string]*int{"limit": &filter.Limit, "offset": &filter.Offset} {
+		v := q.Get(key)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return entity.UserFilter{}, fmt.Errorf("invalid %s %q", key, v)
+		}
+		*dst = n
+	}
+	return filter, nil
+}
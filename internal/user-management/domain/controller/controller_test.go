@@ -0,0 +1,236 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	entity "user-management/internal/user-management/domain/entities"
+	"user-management/internal/user-management/domain/service"
+	"user-management/internal/user-management/helper"
+	"user-management/mocks"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRouter(svc *mocks.IUserService) *mux.Router {
+	c := NewController(svc, ControllerOptions{MaxBatchItems: 3})
+	router := mux.NewRouter()
+	router.HandleFunc("/users", c.CreateUser).Methods("POST")
+	router.HandleFunc("/users", c.GetUsers).Methods("GET")
+	router.HandleFunc("/users/{id:[0-9]+}", c.GetUserByID).Methods("GET")
+	router.HandleFunc("/users/{id:[0-9]+}", c.UpdateUser).Methods("PUT")
+	router.HandleFunc("/users:batch", c.CreateUsers).Methods("POST")
+	router.HandleFunc("/users:batch", c.DeleteUsers).Methods("DELETE")
+	return router
+}
+
+func TestCreateUser(t *testing.T) {
+	svc := mocks.NewIUserService(t)
+	svc.On("RegisterUser", mock.Anything, entity.User{Name: "Aren", Email: "aren@example.com"}, "192.0.2.7").
+		Return(entity.User{ID: 42, Name: "Aren", Email: "aren@example.com"}, map[string]interface{}{"city": "Yerevan"}, nil)
+
+	r := httptest.NewRequest("POST", "/users", strings.NewReader(`{"name":"Aren","email":"aren@example.com"}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.RemoteAddr = "192.0.2.7:40000"
+	w := httptest.NewRecorder()
+	newTestRouter(svc).ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "/users/42", w.Header().Get("Location"))
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"id":42,"name":"Aren","email":"aren@example.com","geo":{"city":"Yerevan"}}`, w.Body.String())
+}
+
+func TestCreateUserWithoutGeo(t *testing.T) {
+	svc := mocks.NewIUserService(t)
+	svc.On("RegisterUser", mock.Anything, mock.Anything, mock.Anything).
+		Return(entity.User{ID: 1, Name: "Aren", Email: "aren@example.com"}, nil, nil)
+
+	r := httptest.NewRequest("POST", "/users", strings.NewReader(`{"name":"Aren","email":"aren@example.com"}`))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	newTestRouter(svc).ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.JSONEq(t, `{"id":1,"name":"Aren","email":"aren@example.com"}`, w.Body.String())
+}
+
+func TestUpdateUserTakesIDFromPath(t *testing.T) {
+	svc := mocks.NewIUserService(t)
+	svc.On("UpdateUser", mock.Anything, entity.User{ID: 7, Name: "Aren", Email: "aren@example.com"}).
+		Return(entity.User{ID: 7, Name: "Aren", Email: "aren@example.com"}, nil)
+
+	r := httptest.NewRequest("PUT", "/users/7", strings.NewReader(`{"name":"Aren","email":"aren@example.com"}`))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	newTestRouter(svc).ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"id":7,"name":"Aren","email":"aren@example.com"}`, w.Body.String())
+}
+
+func TestUpdateUserWithProfile(t *testing.T) {
+	svc := mocks.NewIUserService(t)
+	user := entity.User{ID: 7, Name: "Aren", Email: "aren@example.com",
+		Profile: &entity.UserProfile{Locale: "pt-BR", Timezone: "America/Sao_Paulo"}}
+	svc.On("UpdateUser", mock.Anything, user).Return(user, nil)
+
+	r := httptest.NewRequest("PUT", "/users/7", strings.NewReader(
+		`{"name":"Aren","email":"aren@example.com","profile":{"locale":"pt-br","timezone":"America/Sao_Paulo"}}`))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	newTestRouter(svc).ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"id":7,"name":"Aren","email":"aren@example.com",
+		"profile":{"locale":"pt-BR","timezone":"America/Sao_Paulo"}}`, w.Body.String())
+}
+
+func TestUpdateUserAnswersWithStoredProfile(t *testing.T) {
+	svc := mocks.NewIUserService(t)
+	svc.On("UpdateUser", mock.Anything, entity.User{ID: 7, Name: "Aren", Email: "aren@example.com"}).
+		Return(entity.User{ID: 7, Name: "Aren", Email: "aren@example.com", Profile: &entity.UserProfile{Timezone: "UTC"}}, nil)
+
+	r := httptest.NewRequest("PUT", "/users/7", strings.NewReader(`{"name":"Aren","email":"aren@example.com"}`))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	newTestRouter(svc).ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"id":7,"name":"Aren","email":"aren@example.com","profile":{"timezone":"UTC"}}`, w.Body.String())
+}
+
+func TestGetUserByIDStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"not found", helper.NewError(helper.NotFound, errors.New("user 7 does not exist")), http.StatusNotFound},
+		{"database down", errors.New("dial tcp: connection refused"), http.StatusInternalServerError},
+		{"timeout", context.DeadlineExceeded, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := mocks.NewIUserService(t)
+			svc.On("GetUserByID", mock.Anything, int64(7)).Return(entity.User{}, tt.err)
+
+			w := httptest.NewRecorder()
+			newTestRouter(svc).ServeHTTP(w, httptest.NewRequest("GET", "/users/7", nil))
+
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+}
+
+func TestGetUsersReturnsEmptyList(t *testing.T) {
+	svc := mocks.NewIUserService(t)
+	svc.On("ListUsers", mock.Anything, entity.UserFilter{}).Return(nil, nil)
+
+	w := httptest.NewRecorder()
+	newTestRouter(svc).ServeHTTP(w, httptest.NewRequest("GET", "/users", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[]`, w.Body.String())
+}
+
+func serveBatch(svc *mocks.IUserService, method, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/users:batch", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	newTestRouter(svc).ServeHTTP(w, r)
+	return w
+}
+
+func TestCreateUsersReportsEveryItem(t *testing.T) {
+	svc := mocks.NewIUserService(t)
+	svc.On("CreateUsers", mock.Anything, []entity.User{
+		{Name: "Aren", Email: "aren@example.com"},
+		{Name: "Bob", Email: "bob@example.com"},
+	}, false).Return([]entity.UserBatchResult{
+		{User: entity.User{ID: 1, Name: "Aren", Email: "aren@example.com"}},
+		{User: entity.User{Name: "Bob", Email: "bob@example.com"}, Err: helper.NewError(helper.AlreadyExists, errors.New("email taken"))},
+	})
+
+	w := serveBatch(svc, "POST", `{"items":[
+		{"name":"Aren","email":"aren@example.com"},
+		{"name":"Cy","email":"not-an-email"},
+		{"name":"Bob","email":"bob@example.com"}
+	]}`)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"succeeded":1,"failed":2,"items":[
+		{"index":0,"status":201,"id":1,"user":{"id":1,"name":"Aren","email":"aren@example.com"}},
+		{"index":1,"status":400,"error":{"type":"about:blank","title":"Bad Request","status":400,
+			"detail":"request body failed validation",
+			"errors":[{"field":"email","rule":"email","message":"email must be a valid email address"}]}},
+		{"index":2,"status":409,"error":{"type":"about:blank","title":"Conflict","status":409,"detail":"email taken"}}
+	]}`, w.Body.String())
+}
+
+func TestCreateUsersTransactionalWithInvalidItem(t *testing.T) {
+	svc := mocks.NewIUserService(t)
+
+	w := serveBatch(svc, "POST", `{"transactional":true,"items":[
+		{"name":"Aren","email":"aren@example.com"},
+		{"name":"C","email":"cy@example.com"}
+	]}`)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var resp BatchResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 0, resp.Succeeded)
+	assert.Equal(t, 2, resp.Failed)
+	assert.Equal(t, http.StatusFailedDependency, resp.Items[0].Status)
+	assert.Equal(t, http.StatusBadRequest, resp.Items[1].Status)
+}
+
+func TestCreateUsersRejectsTooManyItems(t *testing.T) {
+	svc := mocks.NewIUserService(t)
+
+	w := serveBatch(svc, "POST", `{"items":[
+		{"name":"Aa","email":"a@example.com"},{"name":"Bb","email":"b@example.com"},
+		{"name":"Cc","email":"c@example.com"},{"name":"Dd","email":"d@example.com"}
+	]}`)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"field":"items","rule":"max","param":"3"`)
+}
+
+func TestDeleteUsersTransactionalFailure(t *testing.T) {
+	svc := mocks.NewIUserService(t)
+	svc.On("DeleteUsers", mock.Anything, []int64{1, 404}, true).Return([]entity.UserBatchResult{
+		{User: entity.User{ID: 1}, Err: service.ErrBatchAborted},
+		{User: entity.User{ID: 404}, Err: helper.NewError(helper.NotFound, errors.New("user 404 does not exist"))},
+	})
+
+	w := serveBatch(svc, "DELETE", `{"ids":[1,404],"transactional":true}`)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"succeeded":0,"failed":2,"items":[
+		{"index":0,"status":424,"id":1,"error":{"type":"about:blank","title":"Failed Dependency","status":424,
+			"detail":"not applied because another item of the transactional batch failed"}},
+		{"index":1,"status":404,"id":404,"error":{"type":"about:blank","title":"Not Found","status":404,
+			"detail":"user 404 does not exist"}}
+	]}`, w.Body.String())
+}
+
+func TestCheckNewUser(t *testing.T) {
+	u, problems := CheckNewUser(entity.User{Name: "Ann", Email: "ann@example.com", Profile: &entity.UserProfile{Locale: "en-us"}})
+	assert.Empty(t, problems)
+	assert.Equal(t, entity.User{Name: "Ann", Email: "ann@example.com", Profile: &entity.UserProfile{Locale: "en-US"}}, u)
+
+	_, problems = CheckNewUser(entity.User{Name: "A", Email: "not-an-email"})
+	assert.Equal(t, []helper.FieldError{
+		{Field: "name", Rule: "min", Param: "2", Message: "name must be at least 2 characters in length"},
+		{Field: "email", Rule: "email", Message: "email must be a valid email address"},
+	}, problems)
+}
@@ -0,0 +1,181 @@
+package controller
+
+import (
+	entity "user-management/internal/user-management/domain/entities"
+	"user-management/internal/user-management/domain/userfile"
+	"user-management/internal/user-management/helper"
+
+	"golang.org/x/text/language"
+)
+
+// CreateUserRequest is the body of POST /users.
+type CreateUserRequest struct {
+	Name    string       `json:"name" validate:"required,min=2" example:"Aren"`
+	Email   string       `json:"email" validate:"required,email" example:"aren@example.com"`
+	Profile *UserProfile `json:"profile,omitempty"`
+}
+
+// UpdateUserRequest is the body of PUT /users/{id}. It replaces every
+// field but profile, which is kept when left out; the ID is the one in
+// the path.
+type UpdateUserRequest struct {
+	Name    string       `json:"name" validate:"required,min=2" example:"Aren"`
+	Email   string       `json:"email" validate:"required,email" example:"aren@example.com"`
+	Profile *UserProfile `json:"profile,omitempty"`
+}
+
+// UserProfile holds the optional details of a user, in requests and
+// responses. A request with a profile replaces the whole of the stored
+// one, so fields it leaves out or empty are cleared.
+type UserProfile struct {
+	DisplayName string `json:"display_name,omitempty" validate:"omitempty,max=100" example:"Aren D."`
+	// Phone is in E.164 form.
+	Phone string `json:"phone,omitempty" validate:"omitempty,phone" example:"+14155550123"`
+	// Locale is a BCP 47 language tag, returned in canonical form.
+	Locale string `json:"locale,omitempty" validate:"omitempty,max=35,locale" example:"en-US"`
+	// Timezone is an IANA time zone name.
+	Timezone  string `json:"timezone,omitempty" validate:"omitempty,max=64,iana_timezone" example:"Europe/Berlin"`
+	AvatarURL string `json:"avatar_url,omitempty" validate:"omitempty,max=2048,http_url" example:"https://example.com/aren.png"`
+	// Birthdate is a date at least 13 years ago.
+	Birthdate string `json:"birthdate,omitempty" validate:"omitempty,birthdate,min_age=13" example:"1990-04-01" format:"date"`
+}
+
+// BatchCreateUsersRequest is the body of POST /users:batch. With
+// transactional, either all users are created or none is.
+type BatchCreateUsersRequest struct {
+	Items         []CreateUserRequest `json:"items" validate:"required,min=1"`
+	Transactional bool                `json:"transactional" example:"false"`
+}
+
+// BatchUpdateUsersRequest is the body of PUT /users:batch.
+type BatchUpdateUsersRequest struct {
+	Items         []BatchUpdateUserItem `json:"items" validate:"required,min=1"`
+	Transactional bool                  `json:"transactional" example:"false"`
+}
+
+// BatchUpdateUserItem replaces the user with its ID as PUT /users/{id}
+// does.
+type BatchUpdateUserItem struct {
+	ID      int64        `json:"id" validate:"required,min=1" example:"1"`
+	Name    string       `json:"name" validate:"required,min=2" example:"Aren"`
+	Email   string       `json:"email" validate:"required,email" example:"aren@example.com"`
+	Profile *UserProfile `json:"profile,omitempty"`
+}
+
+// BatchDeleteUsersRequest is the body of DELETE /users:batch.
+type BatchDeleteUsersRequest struct {
+	IDs           []int64 `json:"ids" validate:"required,min=1" example:"1,2"`
+	Transactional bool    `json:"transactional" example:"false"`
+}
+
+// UserResponse is a user as the API returns it. Users without a profile
+// have none.
+type UserResponse struct {
+	ID      int64        `json:"id" example:"1"`
+	Name    string       `json:"name" example:"Aren"`
+	Email   string       `json:"email" example:"aren@example.com"`
+	Profile *UserProfile `json:"profile,omitempty"`
+}
+
+// CreateUserResponse is a newly registered user, with what ipinfo knows
+// about the address they registered from when the lookup succeeded.
+type CreateUserResponse struct {
+	UserResponse
+	Geo map[string]interface{} `json:"geo,omitempty"`
+}
+
+// UserSearchResponse is a page of the hits of GET /users/search, best
+// first, and how many there are in all.
+type UserSearchResponse struct {
+	Total  int             `json:"total" example:"1"`
+	Limit  int             `json:"limit" example:"20"`
+	Offset int             `json:"offset" example:"0"`
+	Items  []UserSearchHit `json:"items"`
+}
+
+// UserSearchHit is a user that matched a search, with its score, higher
+// for better matches. Scores compare hits of one response only, not of
+// different searches or pages. Highlights has the fields that matched, HTML-escaped
+// and with each match in <em> tags.
+type UserSearchHit struct {
+	UserResponse
+	Score      float64           `json:"score" example:"0.9"`
+	Highlights map[string]string `json:"highlights,omitempty" example:"name:<em>Are</em>n"`
+}
+
+// BatchResponse is the outcome of a batch request, item by item in the
+// order of the request.
+type BatchResponse struct {
+	Succeeded int               `json:"succeeded" example:"1"`
+	Failed    int               `json:"failed" example:"1"`
+	Items     []BatchItemResult `json:"items"`
+}
+
+// BatchItemResult is the outcome of one item of a batch request: the
+// status it would have had as a request of its own, with the user or the
+// problem.
+type BatchItemResult struct {
+	Index  int             `json:"index" example:"0"`
+	Status int             `json:"status" example:"201"`
+	ID     int64           `json:"id,omitempty" example:"1"`
+	User   *UserResponse   `json:"user,omitempty"`
+	Error  *helper.Problem `json:"error,omitempty"`
+}
+
+func (r CreateUserRequest) toEntity() entity.User {
+	return entity.User{Name: r.Name, Email: r.Email, Profile: r.Profile.toEntity()}
+}
+
+func (r UpdateUserRequest) toEntity(id int64) entity.User {
+	return entity.User{ID: id, Name: r.Name, Email: r.Email, Profile: r.Profile.toEntity()}
+}
+
+func (r BatchUpdateUserItem) toEntity() entity.User {
+	return entity.User{ID: r.ID, Name: r.Name, Email: r.Email, Profile: r.Profile.toEntity()}
+}
+
+// toEntity returns nil for a nil p, and the locale of a valid p in
+// canonical form.
+func (p *UserProfile) toEntity() *entity.UserProfile {
+	if p == nil {
+		return nil
+	}
+	e := entity.UserProfile(*p)
+	if tag, err := language.Parse(e.Locale); err == nil {
+		e.Locale = tag.String()
+	}
+	return &e
+}
+
+func toUserResponse(u entity.User) UserResponse {
+	resp := UserResponse{ID: u.ID, Name: u.Name, Email: u.Email}
+	if u.Profile != nil && *u.Profile != (entity.UserProfile{}) {
+		p := UserProfile(*u.Profile)
+		resp.Profile = &p
+	}
+	return resp
+}
+
+// userList is a list of users, which can be sent as CSV too.
+type userList []UserResponse
+
+func (l userList) csvHeader() []string {
+	return userfile.CSVHeader()
+}
+
+func (l userList) csvRows() [][]string {
+	rows := make([][]string, 0, len(l))
+	for _, u := range l {
+		rows = append(rows, userfile.CSVRecord(entity.User{ID: u.ID, Name: u.Name, Email: u.Email,
+			Profile: (*entity.UserProfile)(u.Profile)}))
+	}
+	return rows
+}
+
+func toUserResponses(users []entity.User) userList {
+	resp := make(userList, 0, len(users))
+	for _, u := range users {
+		resp = append(resp, toUserResponse(u))
+	}
+	return resp
+}
@@ -0,0 +1,144 @@
+package controller
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"user-management/internal/user-management/helper"
+	"user-management/internal/user-management/middleware"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// encodeFunc writes v to w in one media type.
+type encodeFunc func(w io.Writer, v any) error
+
+// encoders are the formats responses can be sent in, by media type.
+var encoders = map[string]encodeFunc{
+	"application/json":      encodeJSON,
+	"application/msgpack":   encodeMsgpack,
+	"application/x-msgpack": encodeMsgpack,
+	"text/csv":              encodeCSV,
+}
+
+var (
+	// objectTypes are the formats of single resources, the default first.
+	objectTypes = []string{"application/json", "application/msgpack", "application/x-msgpack"}
+	// listTypes are the formats of lists; only they have rows for CSV.
+	listTypes = append(objectTypes[:len(objectTypes):len(objectTypes)], "text/csv")
+)
+
+// negotiate returns the media type of offers that the Accept header of r
+// prefers, the first offered on a tie or without an Accept header. When
+// it accepts none of them it answers 406 and returns false; handlers call
+// it before doing anything.
+func negotiate(w http.ResponseWriter, r *http.Request, offers []string) (string, bool) {
+	w.Header().Add("Vary", "Accept")
+	header := r.Header.Get("Accept")
+	if header == "" {
+		return offers[0], true
+	}
+
+	accepted := helper.ParseAccept(header)
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		if q := acceptQuality(accepted, offer); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	if best != "" {
+		return best, true
+	}
+
+	p := helper.NewProblem(http.StatusNotAcceptable, "acceptable media types: "+strings.Join(offers, ", "))
+	p.RequestID = middleware.RequestIDFromContext(r.Context())
+	helper.WriteProblem(w, p)
+	return "", false
+}
+
+// acceptQuality returns the quality of the most specific media range of
+// accepted that matches mediaType.
+func acceptQuality(accepted []helper.Accepted, mediaType string) float64 {
+	major, _, _ := strings.Cut(mediaType, "/")
+	q, specificity := 0.0, 0
+	for _, a := range accepted {
+		var s int
+		switch a.Value {
+		case mediaType:
+			s = 3
+		case major + "/*":
+			s = 2
+		case "*/*":
+			s = 1
+		default:
+			continue
+		}
+		if s > specificity {
+			q, specificity = a.Q, s
+		}
+	}
+	return q
+}
+
+// writeResponse writes v as the response body in mediaType, which
+// negotiate picked.
+func writeResponse(w http.ResponseWriter, mediaType string, status int, v any) {
+	contentType := mediaType
+	if strings.HasPrefix(mediaType, "text/") {
+		contentType = mime.FormatMediaType(mediaType, map[string]string{"charset": "utf-8"})
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	_ = encoders[mediaType](w, v)
+}
+
+func encodeJSON(w io.Writer, v any) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+// encodeMsgpack names fields as in JSON, so the formats are alike.
+func encodeMsgpack(w io.Writer, v any) error {
+	enc := msgpack.NewEncoder(w)
+	enc.SetCustomStructTag("json")
+	return enc.Encode(v)
+}
+
+// csvTable is a list that can be sent as CSV.
+type csvTable interface {
+	csvHeader() []string
+	csvRows() [][]string
+}
+
+func encodeCSV(w io.Writer, v any) error {
+	t, ok := v.(csvTable)
+	if !ok {
+		return fmt.Errorf("%T cannot be encoded as CSV", v)
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(t.csvHeader()); err != nil {
+		return err
+	}
+	for _, row := range t.csvRows() {
+		for i, cell := range row {
+			row[i] = csvCell(cell)
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvCell keeps spreadsheets from running a value as a formula.
+func csvCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	entity "user-management/internal/user-management/domain/entities"
+	"user-management/internal/user-management/helper"
+	"user-management/mocks"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestNegotiate(t *testing.T) {
+	for _, tt := range []struct {
+		accept string
+		offers []string
+		want   string
+	}{
+		{"", listTypes, "application/json"},
+		{"*/*", listTypes, "application/json"},
+		{"application/json, text/csv", listTypes, "application/json"},
+		{"text/csv", listTypes, "text/csv"},
+		{"text/*", listTypes, "text/csv"},
+		{"application/msgpack", objectTypes, "application/msgpack"},
+		{"application/x-msgpack", objectTypes, "application/x-msgpack"},
+		{"application/json;q=0.5, application/msgpack", objectTypes, "application/msgpack"},
+		{"*/*;q=0.1, text/csv", listTypes, "text/csv"},
+		{"text/csv;q=0, */*", listTypes, "application/json"},
+		{"text/html, */*;q=0.8", objectTypes, "application/json"},
+		{"text/csv", objectTypes, ""},
+		{"application/xml", listTypes, ""},
+		{"application/json;q=0", objectTypes, ""},
+	} {
+		r := httptest.NewRequest("GET", "/users", nil)
+		if tt.accept != "" {
+			r.Header.Set("Accept", tt.accept)
+		}
+		w := httptest.NewRecorder()
+		got, ok := negotiate(w, r, tt.offers)
+		assert.Equal(t, tt.want, got, tt.accept)
+		assert.Equal(t, tt.want != "", ok, tt.accept)
+		assert.Equal(t, "Accept", w.Header().Get("Vary"))
+		if !ok {
+			assert.Equal(t, http.StatusNotAcceptable, w.Code, tt.accept)
+			assert.Equal(t, helper.ProblemContentType, w.Header().Get("Content-Type"))
+		}
+	}
+}
+
+func getUsers(t *testing.T, accept string, users []entity.User) *httptest.ResponseRecorder {
+	t.Helper()
+	svc := mocks.NewIUserService(t)
+	svc.On("ListUsers", mock.Anything, mock.Anything).Return(users, nil).Maybe()
+
+	r := httptest.NewRequest("GET", "/users", nil)
+	r.Header.Set("Accept", accept)
+	w := httptest.NewRecorder()
+	newTestRouter(svc).ServeHTTP(w, r)
+	return w
+}
+
+func TestGetUsersAsCSV(t *testing.T) {
+	w := getUsers(t, "text/csv", []entity.User{
+		{ID: 1, Name: "Aren", Email: "aren@example.com"},
+		{ID: 2, Name: "=HYPERLINK(\"http://evil\")", Email: "o'neil, jr@example.com",
+			Profile: &entity.UserProfile{DisplayName: "+Ann", Timezone: "UTC"}},
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "id,name,email,display_name,phone,locale,timezone,avatar_url,birthdate\n"+
+		"1,Aren,aren@example.com,,,,,,\n"+
+		"2,\"'=HYPERLINK(\"\"http://evil\"\")\",\"o'neil, jr@example.com\",'+Ann,,,UTC,,\n", w.Body.String())
+}
+
+func TestGetUsersAsMsgpack(t *testing.T) {
+	w := getUsers(t, "application/msgpack", []entity.User{{ID: 1, Name: "Aren", Email: "aren@example.com"}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/msgpack", w.Header().Get("Content-Type"))
+
+	var got []map[string]any
+	assert.NoError(t, msgpack.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, []map[string]any{{"id": int64(1), "name": "Aren", "email": "aren@example.com"}}, got)
+}
+
+func TestGetUsersNotAcceptable(t *testing.T) {
+	// The service is not called.
+	w := getUsers(t, "application/xml", nil)
+	assert.Equal(t, http.StatusNotAcceptable, w.Code)
+	assert.Contains(t, w.Body.String(), "application/json, application/msgpack, application/x-msgpack, text/csv")
+}
@@ -0,0 +1,29 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"user-management/internal/user-management/domain/service"
+	"user-management/internal/user-management/helper"
+)
+
+// errorStatus maps business errors returned by the services to HTTP status
+// codes. Running out of the request's time (see middleware.Timeout) is
+// 503, an item of a failed transactional batch is 424; anything else
+// unrecognised is an internal error.
+func errorStatus(err error) int {
+	switch {
+	case helper.HasStatus(err, helper.NotFound):
+		return http.StatusNotFound
+	case helper.HasStatus(err, helper.AlreadyExists):
+		return http.StatusConflict
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, service.ErrBatchAborted):
+		return http.StatusFailedDependency
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,148 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	entity "user-management/internal/user-management/domain/entities"
+	"user-management/internal/user-management/domain/service"
+)
+
+type eventsController struct {
+	broker    *service.UserEventBroker
+	heartbeat time.Duration
+}
+
+func NewEventsController(broker *service.UserEventBroker, heartbeat time.Duration) *eventsController {
+	if heartbeat <= 0 {
+		heartbeat = 15 * time.Second
+	}
+	return &eventsController{
+		broker:    broker,
+		heartbeat: heartbeat,
+	}
+}
+
+// StreamUserEvents godoc
+// @Summary      Stream user changes
+// @Description  Server-Sent Events feed of user.created, user.updated and user.deleted events.
+// @Description  Reconnecting clients resume from the Last-Event-ID header (or lastEventId query
+// @Description  parameter) as long as the event is still in the replay buffer.
+// @Tags         users
+// @Produce      text/event-stream
+// @Param        types          query     string  false  "Comma-separated event types to receive"
+// @Param        lastEventId    query     int     false  "Resume after this event ID"
+// @Param        Last-Event-ID  header    int     false  "Resume after this event ID"
+// @Success      200            {string}  string  "Event stream"
+// @Failure      400            {string}  string  "Invalid event type or event ID"
+// @Failure      503            {string}  string  "Service is shutting down"
+// @Router       /users/events [get]
+func (c *eventsController) StreamUserEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	types, err := parseEventTypes(r.URL.Query().Get("types"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	lastIDStr := r.Header.Get("Last-Event-ID")
+	if lastIDStr == "" {
+		lastIDStr = r.URL.Query().Get("lastEventId")
+	}
+	var lastID uint64
+	if lastIDStr != "" {
+		lastID, err = strconv.ParseUint(lastIDStr, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	stream, missed, err := c.broker.Subscribe(types, lastID, lastIDStr != "")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	defer stream.Close()
+
+	// The stream outlives HTTP_WRITE_TIMEOUT by design.
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	for _, se := range missed {
+		if err := writeEvent(w, se); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(c.heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case se, ok := <-stream.C:
+			if !ok {
+				return
+			}
+			if err := writeEvent(w, se); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+func parseEventTypes(s string) ([]entity.UserEventType, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var types []entity.UserEventType
+	for _, part := range strings.Split(s, ",") {
+		t := entity.UserEventType(strings.TrimSpace(part))
+		if !isUserEventType(t) {
+			return nil, fmt.Errorf("unknown event type %q", t)
+		}
+		types = append(types, t)
+	}
+	return types, nil
+}
+
+func isUserEventType(t entity.UserEventType) bool {
+	for _, known := range entity.UserEventTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+func writeEvent(w http.ResponseWriter, se service.StreamedUserEvent) error {
+	data, err := json.Marshal(se.Event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", se.ID, se.Event.Type, data)
+	return err
+}
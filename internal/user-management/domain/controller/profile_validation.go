@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // IANA time zones on hosts without a zoneinfo database
+
+	entity "user-management/internal/user-management/domain/entities"
+
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	"golang.org/x/text/language"
+)
+
+// e164 is a phone number in E.164 form: a plus, a country code that does
+// not start with 0 and at most 15 digits in all.
+var e164 = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+
+// profileValidations are the validate tags of UserProfile that validator
+// does not have. min_age=N takes a date in entity.DateLayout at least N
+// years ago.
+var profileValidations = map[string]validator.Func{
+	"phone": func(fl validator.FieldLevel) bool {
+		return e164.MatchString(fl.Field().String())
+	},
+	"locale": func(fl validator.FieldLevel) bool {
+		// language.Parse takes en_US too, which is not a BCP 47 tag.
+		s := fl.Field().String()
+		_, err := language.Parse(s)
+		return err == nil && !strings.Contains(s, "_")
+	},
+	"iana_timezone": func(fl validator.FieldLevel) bool {
+		name := fl.Field().String()
+		if name == "" || name == "Local" {
+			return false
+		}
+		_, err := time.LoadLocation(name)
+		return err == nil
+	},
+	"birthdate": func(fl validator.FieldLevel) bool {
+		d, err := time.Parse(entity.DateLayout, fl.Field().String())
+		return err == nil && !d.After(today())
+	},
+	"min_age": func(fl validator.FieldLevel) bool {
+		years, err := strconv.Atoi(fl.Param())
+		if err != nil {
+			panic("min_age: bad param " + strconv.Quote(fl.Param()))
+		}
+		d, err := time.Parse(entity.DateLayout, fl.Field().String())
+		return err == nil && !d.AddDate(years, 0, 0).After(today())
+	},
+}
+
+// today is the current date in UTC, as dates without a time zone compare.
+func today() time.Time {
+	y, m, d := time.Now().UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// profileMessages are the messages of profileValidations, and of http_url
+// which validator has none for, by locale. {0} is the field and {1} the
+// param of the tag.
+var profileMessages = map[string]map[string]string{
+	"en": {
+		"phone":         "{0} must be a phone number in E.164 format, e.g. +14155550123",
+		"locale":        "{0} must be a BCP 47 language tag, e.g. en-US",
+		"iana_timezone": "{0} must be an IANA time zone, e.g. Europe/Berlin",
+		"birthdate":     "{0} must be a date in the past in YYYY-MM-DD format",
+		"min_age":       "{0} must be at least {1} years ago",
+		"http_url":      "{0} must be an http or https URL",
+	},
+	"de": {
+		"phone":         "{0} muss eine Telefonnummer im E.164-Format sein, z. B. +14155550123",
+		"locale":        "{0} muss ein BCP-47-Sprach-Tag sein, z. B. de-DE",
+		"iana_timezone": "{0} muss eine IANA-Zeitzone sein, z. B. Europe/Berlin",
+		"birthdate":     "{0} muss ein vergangenes Datum im Format JJJJ-MM-TT sein",
+		"min_age":       "{0} muss mindestens {1} Jahre zurückliegen",
+		"http_url":      "{0} muss eine http- oder https-URL sein",
+	},
+	"es": {
+		"phone":         "{0} debe ser un número de teléfono en formato E.164, p. ej. +14155550123",
+		"locale":        "{0} debe ser una etiqueta de idioma BCP 47, p. ej. es-ES",
+		"iana_timezone": "{0} debe ser una zona horaria IANA, p. ej. Europe/Madrid",
+		"birthdate":     "{0} debe ser una fecha pasada en formato AAAA-MM-DD",
+		"min_age":       "{0} debe ser de hace al menos {1} años",
+		"http_url":      "{0} debe ser una URL http o https",
+	},
+	"fr": {
+		"phone":         "{0} doit être un numéro de téléphone au format E.164, par ex. +14155550123",
+		"locale":        "{0} doit être une étiquette de langue BCP 47, par ex. fr-FR",
+		"iana_timezone": "{0} doit être un fuseau horaire IANA, par ex. Europe/Paris",
+		"birthdate":     "{0} doit être une date passée au format AAAA-MM-JJ",
+		"min_age":       "{0} doit dater d'au moins {1} ans",
+		"http_url":      "{0} doit être une URL http ou https",
+	},
+}
+
+// registerProfileValidations adds profileValidations to v.
+func registerProfileValidations(v *validator.Validate) {
+	for tag, fn := range profileValidations {
+		if err := v.RegisterValidation(tag, fn); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// registerProfileTranslations adds the profileMessages of locale to trans.
+func registerProfileTranslations(v *validator.Validate, locale string, trans ut.Translator) error {
+	for tag, text := range profileMessages[locale] {
+		err := v.RegisterTranslation(tag, trans,
+			func(ut ut.Translator) error { return ut.Add(tag, text, true) },
+			func(ut ut.Translator, fe validator.FieldError) string {
+				msg, err := ut.T(fe.Tag(), fe.Field(), fe.Param())
+				if err != nil {
+					return fe.Error()
+				}
+				return msg
+			})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	entity "user-management/internal/user-management/domain/entities"
+	"user-management/internal/user-management/helper"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfileValidation(t *testing.T) {
+	ago := func(years, days int) string {
+		return time.Now().UTC().AddDate(-years, 0, -days).Format(entity.DateLayout)
+	}
+	post := func(profile string) *helper.Problem {
+		return postUser(t, "application/json", "", `{"name":"Ann","email":"ann@example.com","profile":`+profile+`}`)
+	}
+
+	assert.Nil(t, post(`{}`))
+	assert.Nil(t, post(`{"display_name":"Ann B.","phone":"+14155550123","locale":"de-CH","timezone":"Europe/Berlin",`+
+		`"avatar_url":"https://example.com/ann.png","birthdate":"1990-02-28"}`))
+	assert.Nil(t, post(fmt.Sprintf(`{"birthdate":%q}`, ago(13, 0))))
+	assert.Nil(t, post(`{"locale":"zh-Hant-TW","timezone":"UTC"}`))
+
+	for _, tt := range []struct {
+		profile, rule, param, message, field string
+	}{
+		{`{"phone":"4155550123"}`, "phone", "", "phone must be a phone number in E.164 format, e.g. +14155550123", "phone"},
+		{`{"phone":"+0155550123"}`, "phone", "", "", "phone"},
+		{`{"phone":"+1 415 555 0123"}`, "phone", "", "", "phone"},
+		{`{"phone":"+1234567890123456"}`, "phone", "", "", "phone"},
+		{`{"locale":"en_US"}`, "locale", "", "locale must be a BCP 47 language tag, e.g. en-US", "locale"},
+		{`{"locale":"not a locale"}`, "locale", "", "", "locale"},
+		{`{"timezone":"Mars/Olympus"}`, "iana_timezone", "", "timezone must be an IANA time zone, e.g. Europe/Berlin", "timezone"},
+		{`{"timezone":"Local"}`, "iana_timezone", "", "", "timezone"},
+		{`{"avatar_url":"ftp://example.com/ann.png"}`, "http_url", "", "avatar_url must be an http or https URL", "avatar_url"},
+		{`{"birthdate":"01/02/1990"}`, "birthdate", "", "birthdate must be a date in the past in YYYY-MM-DD format", "birthdate"},
+		{`{"birthdate":"1990-02-30"}`, "birthdate", "", "", "birthdate"},
+		{fmt.Sprintf(`{"birthdate":%q}`, ago(0, -1)), "birthdate", "", "", "birthdate"},
+		{fmt.Sprintf(`{"birthdate":%q}`, ago(13, -1)), "min_age", "13", "birthdate must be at least 13 years ago", "birthdate"},
+	} {
+		t.Run(tt.profile, func(t *testing.T) {
+			p := post(tt.profile)
+			require.NotNil(t, p)
+			assert.Equal(t, 400, p.Status)
+			require.Len(t, p.Errors, 1)
+			assert.Equal(t, "profile."+tt.field, p.Errors[0].Field)
+			assert.Equal(t, tt.rule, p.Errors[0].Rule)
+			assert.Equal(t, tt.param, p.Errors[0].Param)
+			if tt.message != "" {
+				assert.Equal(t, tt.message, p.Errors[0].Message)
+			}
+		})
+	}
+}
+
+func TestProfileValidationTranslatesMessages(t *testing.T) {
+	for _, tt := range []struct{ acceptLanguage, message string }{
+		{"de", "phone muss eine Telefonnummer im E.164-Format sein, z. B. +14155550123"},
+		{"es", "phone debe ser un número de teléfono en formato E.164, p. ej. +14155550123"},
+		{"fr", "phone doit être un numéro de téléphone au format E.164, par ex. +14155550123"},
+		{"ja", "phone must be a phone number in E.164 format, e.g. +14155550123"},
+	} {
+		p := postUser(t, "application/json", tt.acceptLanguage, `{"name":"Ann","email":"ann@example.com","profile":{"phone":"123"}}`)
+		require.NotNil(t, p)
+		require.Len(t, p.Errors, 1)
+		assert.Equal(t, tt.message, p.Errors[0].Message, tt.acceptLanguage)
+	}
+
+	// Every locale has a message for every tag.
+	for locale := range profileMessages {
+		assert.Equal(t, slices.Sorted(maps.Keys(profileMessages["en"])), slices.Sorted(maps.Keys(profileMessages[locale])), locale)
+	}
+}
+
+func TestUserProfileToEntity(t *testing.T) {
+	var none *UserProfile
+	assert.Nil(t, none.toEntity())
+	assert.Equal(t, &entity.UserProfile{}, (&UserProfile{}).toEntity())
+	assert.Equal(t, &entity.UserProfile{Locale: "en-US", Phone: "+14155550123"},
+		(&UserProfile{Locale: "en-us", Phone: "+14155550123"}).toEntity())
+
+	assert.Nil(t, toUserResponse(entity.User{ID: 1, Profile: &entity.UserProfile{}}).Profile)
+	assert.Equal(t, &UserProfile{Timezone: "UTC"},
+		toUserResponse(entity.User{ID: 1, Profile: &entity.UserProfile{Timezone: "UTC"}}).Profile)
+}
@@ -0,0 +1,148 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"user-management/internal/user-management/helper"
+	"user-management/internal/user-management/middleware"
+)
+
+// requestError is a client error in a request body, answered with a
+// problem response by writeRequestError.
+type requestError struct {
+	status int
+	detail string
+	fields []helper.FieldError
+}
+
+func (e *requestError) Error() string {
+	return e.detail
+}
+
+func badRequest(format string, args ...any) *requestError {
+	return &requestError{status: http.StatusBadRequest, detail: fmt.Sprintf(format, args...)}
+}
+
+// readJSON decodes the body of r into dst and validates it. The body must
+// be a single JSON value with only the fields dst has, sent as
+// application/json. The error is a *requestError.
+func readJSON(r *http.Request, dst any) error {
+	if err := checkContentType(r); err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		return decodeError(err)
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return decodeError(err)
+		}
+		return badRequest("request body must contain a single JSON value")
+	}
+	return validateRequest(r, dst)
+}
+
+func checkContentType(r *http.Request) error {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err == nil && (mediaType == "application/json" ||
+		strings.HasPrefix(mediaType, "application/") && strings.HasSuffix(mediaType, "+json")) {
+		return nil
+	}
+	return &requestError{
+		status: http.StatusUnsupportedMediaType,
+		detail: "Content-Type must be application/json",
+	}
+}
+
+func decodeError(err error) error {
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+		tooLarge  *http.MaxBytesError
+	)
+	switch {
+	case errors.Is(err, io.EOF):
+		return badRequest("request body must not be empty")
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return badRequest("request body is not valid JSON: unexpected end")
+	case errors.As(err, &syntaxErr):
+		return badRequest("request body is not valid JSON at offset %d: %s", syntaxErr.Offset, syntaxErr)
+	case errors.As(err, &typeErr):
+		want := jsonType(typeErr.Type)
+		field := typeErr.Field
+		if field == "" {
+			return badRequest("request body must be a JSON %s", want)
+		}
+		e := badRequest("request body failed validation")
+		e.fields = []helper.FieldError{{
+			Field:   field,
+			Rule:    "type",
+			Param:   want,
+			Message: fmt.Sprintf("%s must be a %s, not a %s", field, want, typeErr.Value),
+		}}
+		return e
+	case errors.As(err, &tooLarge):
+		return &requestError{
+			status: http.StatusRequestEntityTooLarge,
+			detail: fmt.Sprintf("request body must not be larger than %d bytes", tooLarge.Limit),
+		}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		e := badRequest("request body has an unknown field %q", field)
+		e.fields = []helper.FieldError{{
+			Field:   field,
+			Rule:    "unknown",
+			Message: fmt.Sprintf("%s is not a known field", field),
+		}}
+		return e
+	}
+	return badRequest("request body is invalid: %s", strings.TrimPrefix(err.Error(), "json: "))
+}
+
+// jsonType names the JSON type values of t are decoded from.
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// writeRequestError answers a failed readJSON with a problem response.
+func writeRequestError(w http.ResponseWriter, r *http.Request, err error) {
+	p := requestProblem(err)
+	p.RequestID = middleware.RequestIDFromContext(r.Context())
+	helper.WriteProblem(w, p)
+}
+
+// requestProblem describes a failed readJSON or validateRequest.
+func requestProblem(err error) helper.Problem {
+	re := &requestError{status: http.StatusBadRequest, detail: err.Error()}
+	errors.As(err, &re)
+	p := helper.NewProblem(re.status, re.detail)
+	p.Errors = re.fields
+	return p
+}
+
+// problem is the body of error responses, named for the swagger docs.
+type problem = helper.Problem
@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"user-management/internal/user-management/helper"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// postUser runs body through readJSON as a user and returns the problem
+// written for it, or nil when it was accepted.
+func postUser(t *testing.T, contentType, acceptLanguage, body string) *helper.Problem {
+	t.Helper()
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var u CreateUserRequest
+		if err := readJSON(r, &u); err != nil {
+			writeRequestError(w, r, err)
+		}
+	})
+	r := httptest.NewRequest("POST", "/users", strings.NewReader(body))
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+	if acceptLanguage != "" {
+		r.Header.Set("Accept-Language", acceptLanguage)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code == http.StatusOK {
+		return nil
+	}
+
+	assert.Equal(t, helper.ProblemContentType, w.Header().Get("Content-Type"))
+	var p helper.Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+	assert.Equal(t, w.Code, p.Status)
+	return &p
+}
+
+func TestReadJSON(t *testing.T) {
+	assert.Nil(t, postUser(t, "application/json", "", `{"name":"Ann","email":"ann@example.com"}`))
+	assert.Nil(t, postUser(t, "application/json; charset=utf-8", "", `{"name":"Ann","email":"ann@example.com"}`+"\n"))
+	assert.Nil(t, postUser(t, "application/merge-patch+json", "", `{"name":"Ann","email":"ann@example.com"}`))
+
+	for _, tt := range []struct {
+		name, contentType, body string
+		status                  int
+		detail                  string
+		fields                  []helper.FieldError
+	}{
+		{"no content type", "", `{}`, 415, "Content-Type must be application/json", nil},
+		{"form", "application/x-www-form-urlencoded", `name=Ann`, 415, "Content-Type must be application/json", nil},
+		{"empty", "application/json", ``, 400, "request body must not be empty", nil},
+		{"truncated", "application/json", `{"name":"Ann"`, 400, "request body is not valid JSON: unexpected end", nil},
+		{"syntax", "application/json", `{"name":}`, 400, "request body is not valid JSON at offset 9: invalid character '}' looking for beginning of value", nil},
+		{"two values", "application/json", `{"name":"Ann","email":"ann@example.com"}{}`, 400, "request body must contain a single JSON value", nil},
+		{"trailing garbage", "application/json", `{"name":"Ann","email":"ann@example.com"} x`, 400, "request body must contain a single JSON value", nil},
+		{"not an object", "application/json", `[]`, 400, "request body must be a JSON object", nil},
+		{"unknown field", "application/json", `{"id":7,"name":"Ann","email":"ann@example.com"}`, 400,
+			`request body has an unknown field "id"`,
+			[]helper.FieldError{{Field: "id", Rule: "unknown", Message: "id is not a known field"}}},
+		{"wrong type", "application/json", `{"name":42,"email":"ann@example.com"}`, 400,
+			"request body failed validation",
+			[]helper.FieldError{{Field: "name", Rule: "type", Param: "string", Message: "name must be a string, not a number"}}},
+		{"invalid fields", "application/json", `{"name":"A","email":"ann"}`, 400,
+			"request body failed validation",
+			[]helper.FieldError{
+				{Field: "name", Rule: "min", Param: "2", Message: "name must be at least 2 characters in length"},
+				{Field: "email", Rule: "email", Message: "email must be a valid email address"},
+			}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			p := postUser(t, tt.contentType, "", tt.body)
+			require.NotNil(t, p)
+			assert.Equal(t, tt.status, p.Status)
+			assert.Equal(t, tt.detail, p.Detail)
+			assert.Equal(t, tt.fields, p.Errors)
+		})
+	}
+}
+
+func TestReadJSONTranslatesMessages(t *testing.T) {
+	for _, tt := range []struct{ acceptLanguage, message string }{
+		{"de-CH, en;q=0.5", "name muss mindestens 2 Zeichen lang sein"},
+		{"fr;q=0.9, es", "name debe tener al menos 2 caracteres de longitud"},
+		{"ja, fr;q=0.1", "name doit faire une taille minimum de 2 caractères"},
+		{"ja", "name must be at least 2 characters in length"},
+		{"", "name must be at least 2 characters in length"},
+	} {
+		p := postUser(t, "application/json", tt.acceptLanguage, `{"name":"A","email":"ann@example.com"}`)
+		require.NotNil(t, p)
+		require.Len(t, p.Errors, 1)
+		assert.Equal(t, "min", p.Errors[0].Rule, tt.acceptLanguage)
+		assert.Equal(t, tt.message, p.Errors[0].Message, tt.acceptLanguage)
+	}
+}
+
+func TestReadJSONTooLarge(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, 16)
+		var u CreateUserRequest
+		if err := readJSON(r, &u); err != nil {
+			writeRequestError(w, r, err)
+		}
+	})
+	r := httptest.NewRequest("POST", "/users", strings.NewReader(`{"name":"Annabelle","email":"ann@example.com"}`))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Contains(t, w.Body.String(), "request body must not be larger than 16 bytes")
+}
@@ -0,0 +1,170 @@
+package controller
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	entity "user-management/internal/user-management/domain/entities"
+	"user-management/internal/user-management/domain/userfile"
+
+	"github.com/rs/zerolog"
+)
+
+// exportTypes are the formats of GET /users/export, the default first.
+var exportTypes = []string{"application/x-ndjson", "text/csv"}
+
+// exportWriteGrace is how long past the deadline of an export its
+// response may still be written to, so that running out of time can be
+// answered.
+const exportWriteGrace = 5 * time.Second
+
+// StreamUsers godoc
+// @Summary      Export users
+// @Description  All users in ID order, filtered as GET /users, streamed from the database as they
+// @Description  are read, so any number of them takes the same memory. What is written is sent
+// @Description  every HTTP_EXPORT_FLUSH_INTERVAL. The export is cut off after HTTP_EXPORT_TIMEOUT
+// @Description  (or when the client goes away); once rows have been sent, a failure aborts the
+// @Description  connection, so a truncated export never looks complete.
+// @Tags         users
+// @Produce      application/x-ndjson,text/csv
+// @Param        name    query     string  false  "Case-insensitive substring of the name"
+// @Param        email   query     string  false  "Exact email"
+// @Param        limit   query     int     false  "Maximum number of users"
+// @Param        offset  query     int     false  "Number of users to skip"
+// @Success      200     {array}   UserResponse  "A JSON object per line, or CSV with a header row and the profile fields as columns"
+// @Failure      400     {string}  string   "Invalid filter"
+// @Failure      406     {object}  problem  "Requested media type not available"
+// @Failure      500     {string}  string   "Internal server error"
+// @Failure      503     {string}  string   "Timed out before the first user"
+// @Router       /users/export [get]
+func (c *controller) StreamUsers(w http.ResponseWriter, r *http.Request) {
+	mediaType, ok := negotiate(w, r, exportTypes)
+	if !ok {
+		return
+	}
+
+	filter, err := parseUserFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// The export may outlast HTTP_WRITE_TIMEOUT; it has its own deadline.
+	rc := http.NewResponseController(w)
+	var writeDeadline time.Time
+	if deadline, ok := r.Context().Deadline(); ok {
+		writeDeadline = deadline.Add(exportWriteGrace)
+	}
+	_ = rc.SetWriteDeadline(writeDeadline)
+
+	ex := &userExport{w: w, rc: rc, mediaType: mediaType, flushInterval: c.opts.ExportFlushInterval}
+	err = c.userService.StreamUsers(r.Context(), filter, ex.write)
+	if err == nil {
+		err = ex.finish()
+	}
+	if err == nil {
+		return
+	}
+
+	log := zerolog.Ctx(r.Context())
+	switch {
+	case errors.Is(r.Context().Err(), context.Canceled):
+		// The client went away; there is no one left to tell.
+		log.Info().Int("users", ex.n).Msg("Export canceled by the client")
+		return
+	case errors.Is(err, context.DeadlineExceeded):
+		log.Warn().Int("users", ex.n).Msg("Export ran out of time")
+	default:
+		log.Error().Err(err).Int("users", ex.n).Msg("Export failed")
+	}
+	if !ex.started {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	panic(http.ErrAbortHandler)
+}
+
+// userExport writes the users of GET /users/export as they come. The
+// response starts with the first user, so that a failing query can still
+// be answered with an error.
+type userExport struct {
+	w             http.ResponseWriter
+	rc            *http.ResponseController
+	mediaType     string
+	flushInterval time.Duration
+
+	started   bool
+	bw        *bufio.Writer
+	enc       userfile.Encoder
+	n         int
+	lastFlush time.Time
+}
+
+func (ex *userExport) write(u entity.User) error {
+	if err := ex.start(); err != nil {
+		return err
+	}
+	if err := ex.enc.Encode(u); err != nil {
+		return err
+	}
+	ex.n++
+	if time.Since(ex.lastFlush) >= ex.flushInterval {
+		return ex.flush()
+	}
+	return nil
+}
+
+func (ex *userExport) start() error {
+	if ex.started {
+		return nil
+	}
+	ex.started = true
+
+	h := ex.w.Header()
+	ext := "ndjson"
+	if ex.mediaType == "text/csv" {
+		ext = "csv"
+		h.Set("Content-Type", "text/csv; charset=utf-8")
+	} else {
+		h.Set("Content-Type", ex.mediaType)
+	}
+	h.Set("Content-Disposition", `attachment; filename="users.`+ext+`"`)
+	h.Set("X-Accel-Buffering", "no")
+	ex.w.WriteHeader(http.StatusOK)
+
+	ex.bw = bufio.NewWriter(ex.w)
+	ex.lastFlush = time.Now()
+	var err error
+	if ext == "csv" {
+		ex.enc, err = userfile.NewCSVEncoder(ex.bw, csvCell)
+	} else {
+		ex.enc, err = userfile.NewEncoder(ex.bw, ext)
+	}
+	return err
+}
+
+// flush sends what has been written to the client.
+func (ex *userExport) flush() error {
+	if err := ex.enc.Close(); err != nil {
+		return err
+	}
+	if err := ex.bw.Flush(); err != nil {
+		return err
+	}
+	ex.lastFlush = time.Now()
+	if err := ex.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+// finish ends an export, which may have had no users.
+func (ex *userExport) finish() error {
+	if err := ex.start(); err != nil {
+		return err
+	}
+	return ex.flush()
+}
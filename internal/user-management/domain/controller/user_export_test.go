@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	entity "user-management/internal/user-management/domain/entities"
+	"user-management/internal/user-management/domain/service"
+	"user-management/internal/user-management/infrastructure/repository"
+	"user-management/mocks"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestUserService returns a user service on an in-memory repository
+// with the given users.
+func newTestUserService(t *testing.T, users ...entity.User) service.IUserService {
+	t.Helper()
+	repo := repository.NewInMemoryUserRepository()
+	for _, u := range users {
+		_, err := repo.Create(context.Background(), u)
+		require.NoError(t, err)
+	}
+	return service.NewUserService(repo, repository.NewFuzzyUserSearcher(repo), nil, service.UserServiceOptions{BatchSize: 2})
+}
+
+func TestStreamUsers(t *testing.T) {
+	svc := newTestUserService(t,
+		entity.User{Name: "=Anna", Email: "anna@example.com"},
+		entity.User{Name: "Bob", Email: "bob@example.com"},
+		entity.User{Name: "Hannah", Email: "hannah@example.com"},
+	)
+	c := NewController(svc, ControllerOptions{})
+
+	for _, tt := range []struct {
+		url, accept, contentType, body string
+	}{
+		{"/users/export", "", "application/x-ndjson",
+			`{"id":1,"name":"=Anna","email":"anna@example.com"}` + "\n" +
+				`{"id":2,"name":"Bob","email":"bob@example.com"}` + "\n" +
+				`{"id":3,"name":"Hannah","email":"hannah@example.com"}` + "\n"},
+		{"/users/export?name=ann", "text/csv", "text/csv; charset=utf-8",
+			"id,name,email,display_name,phone,locale,timezone,avatar_url,birthdate\n1,'=Anna,anna@example.com,,,,,,\n3,Hannah,hannah@example.com,,,,,,\n"},
+		{"/users/export?name=zed", "text/csv", "text/csv; charset=utf-8", "id,name,email,display_name,phone,locale,timezone,avatar_url,birthdate\n"},
+		{"/users/export?email=bob@example.com", "application/*", "application/x-ndjson",
+			`{"id":2,"name":"Bob","email":"bob@example.com"}` + "\n"},
+	} {
+		r := httptest.NewRequest("GET", tt.url, nil)
+		if tt.accept != "" {
+			r.Header.Set("Accept", tt.accept)
+		}
+		w := httptest.NewRecorder()
+		c.StreamUsers(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code, tt.url)
+		assert.Equal(t, tt.contentType, w.Header().Get("Content-Type"), tt.url)
+		assert.Equal(t, tt.body, w.Body.String(), tt.url)
+		assert.True(t, w.Flushed, tt.url)
+	}
+
+	w := httptest.NewRecorder()
+	c.StreamUsers(w, httptest.NewRequest("GET", "/users/export?limit=x", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestStreamUsersFailure(t *testing.T) {
+	svc := mocks.NewIUserService(t)
+	c := NewController(svc, ControllerOptions{})
+
+	svc.On("StreamUsers", mock.Anything, entity.UserFilter{}, mock.Anything).
+		Return(errors.New("db down")).Once()
+	w := httptest.NewRecorder()
+	c.StreamUsers(w, httptest.NewRequest("GET", "/users/export", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code, "nothing was sent yet, so there is still a status to send")
+
+	svc.On("StreamUsers", mock.Anything, entity.UserFilter{}, mock.Anything).
+		Run(func(args mock.Arguments) {
+			fn := args.Get(2).(func(entity.User) error)
+			_ = fn(entity.User{ID: 1, Name: "Anna", Email: "anna@example.com"})
+		}).
+		Return(errors.New("connection reset")).Once()
+	w = httptest.NewRecorder()
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		c.StreamUsers(w, httptest.NewRequest("GET", "/users/export", nil))
+	}, "a truncated export is aborted, not ended as if complete")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	svc.On("StreamUsers", mock.Anything, entity.UserFilter{}, mock.Anything).
+		Run(func(args mock.Arguments) {
+			fn := args.Get(2).(func(entity.User) error)
+			_ = fn(entity.User{ID: 1, Name: "Anna", Email: "anna@example.com"})
+			cancel()
+		}).
+		Return(context.Canceled).Once()
+	w = httptest.NewRecorder()
+	assert.NotPanics(t, func() {
+		c.StreamUsers(w, httptest.NewRequest("GET", "/users/export", nil).WithContext(ctx))
+	}, "a client that went away is not answered")
+}
@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"errors"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+
+	entity "user-management/internal/user-management/domain/entities"
+	"user-management/internal/user-management/helper"
+)
+
+// searchParams are the query parameters of GET /users/search.
+type searchParams struct {
+	Q      string `json:"q" validate:"required,max=200"`
+	Limit  int    `json:"limit" validate:"min=1,max=100"`
+	Offset int    `json:"offset" validate:"min=0"`
+}
+
+// SearchUsers godoc
+// @Summary      Search users
+// @Description  Find users by words of their name or email, such as part of a name or a misspelled
+// @Description  email. Every word has to match; hits are ranked best first, with the parts that
+// @Description  matched highlighted. On MySQL the FULLTEXT index matches word starts, falling back
+// @Description  to close matches when it finds nothing (USER_SEARCH_FUZZY_FALLBACK).
+// @Tags         users
+// @Produce      json,application/msgpack
+// @Param        q       query     string  true   "Words to look for"
+// @Param        limit   query     int     false  "Hits per page, up to 100"  default(20)
+// @Param        offset  query     int     false  "Number of hits to skip"
+// @Success      200     {object}  UserSearchResponse
+// @Failure      400     {object}  problem  "Invalid query"
+// @Failure      406     {object}  problem  "Requested media type not available"
+// @Failure      500     {string}  string   "Internal server error"
+// @Router       /users/search [get]
+func (c *controller) SearchUsers(w http.ResponseWriter, r *http.Request) {
+	mediaType, ok := negotiate(w, r, objectTypes)
+	if !ok {
+		return
+	}
+
+	params, err := parseSearchParams(r)
+	if err != nil {
+		writeRequestError(w, r, err)
+		return
+	}
+
+	result, err := c.userService.SearchUsers(r.Context(), entity.UserSearchQuery{
+		Text:   params.Q,
+		Limit:  params.Limit,
+		Offset: params.Offset,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	resp := UserSearchResponse{
+		Total:  result.Total,
+		Limit:  params.Limit,
+		Offset: params.Offset,
+		Items:  make([]UserSearchHit, 0, len(result.Hits)),
+	}
+	for _, hit := range result.Hits {
+		resp.Items = append(resp.Items, toUserSearchHit(hit))
+	}
+	writeResponse(w, mediaType, http.StatusOK, resp)
+}
+
+func parseSearchParams(r *http.Request) (searchParams, error) {
+	q := r.URL.Query()
+	params := searchParams{Q: strings.TrimSpace(q.Get("q")), Limit: 20}
+	for key, dst := range map[string]*int{"limit": &params.Limit, "offset": &params.Offset} {
+		v := q.Get(key)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			e := badRequest("invalid %s %q", key, v)
+			e.fields = []helper.FieldError{{Field: key, Rule: "number", Message: key + " must be a number"}}
+			return searchParams{}, e
+		}
+		*dst = n
+	}
+	err := validateRequest(r, params)
+	var re *requestError
+	if errors.As(err, &re) {
+		re.detail = "query parameters failed validation"
+	}
+	return params, err
+}
+
+func toUserSearchHit(hit entity.UserSearchHit) UserSearchHit {
+	out := UserSearchHit{UserResponse: toUserResponse(hit.User), Score: hit.Score}
+	values := map[string]string{"name": hit.User.Name, "email": hit.User.Email}
+	for field, value := range values {
+		var hs []entity.Highlight
+		for _, h := range hit.Highlights {
+			if h.Field == field {
+				hs = append(hs, h)
+			}
+		}
+		if len(hs) == 0 {
+			continue
+		}
+		if out.Highlights == nil {
+			out.Highlights = make(map[string]string)
+		}
+		out.Highlights[field] = highlight(value, hs)
+	}
+	return out
+}
+
+// highlight escapes value for HTML and puts the parts hs mark in <em>
+// tags.
+func highlight(value string, hs []entity.Highlight) string {
+	var b strings.Builder
+	last := 0
+	for _, h := range hs {
+		if h.Start < last || h.End > len(value) || h.Start > h.End {
+			continue
+		}
+		b.WriteString(html.EscapeString(value[last:h.Start]))
+		b.WriteString("<em>")
+		b.WriteString(html.EscapeString(value[h.Start:h.End]))
+		b.WriteString("</em>")
+		last = h.End
+	}
+	b.WriteString(html.EscapeString(value[last:]))
+	return b.String()
+}
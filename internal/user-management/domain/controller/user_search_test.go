@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	entity "user-management/internal/user-management/domain/entities"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchUsers(t *testing.T) {
+	svc := newTestUserService(t,
+		entity.User{Name: "John <Doe>", Email: "john.doe@example.com"},
+		entity.User{Name: "Johanna", Email: "jo@example.org"},
+		entity.User{Name: "Bob", Email: "bob@example.com"},
+	)
+	c := NewController(svc, ControllerOptions{})
+
+	w := httptest.NewRecorder()
+	c.SearchUsers(w, httptest.NewRequest("GET", "/users/search?q=john&limit=1", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"total": 1, "limit": 1, "offset": 0,
+		"items": [{
+			"id": 1, "name": "John <Doe>", "email": "john.doe@example.com", "score": 1,
+			"highlights": {"name": "<em>John</em> &lt;Doe&gt;", "email": "<em>john</em>.doe@example.com"}
+		}]
+	}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	c.SearchUsers(w, httptest.NewRequest("GET", "/users/search?q=johana", nil))
+	var resp UserSearchResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 1, resp.Total)
+	assert.Equal(t, 20, resp.Limit)
+	require.Len(t, resp.Items, 1)
+	assert.Equal(t, "<em>Johanna</em>", resp.Items[0].Highlights["name"], "close matches highlight the word")
+
+	w = httptest.NewRecorder()
+	c.SearchUsers(w, httptest.NewRequest("GET", "/users/search?q=nobody", nil))
+	assert.JSONEq(t, `{"total": 0, "limit": 20, "offset": 0, "items": []}`, w.Body.String())
+
+	for url, field := range map[string]string{
+		"/users/search":                  "q",
+		"/users/search?q=%20":            "q",
+		"/users/search?q=jo&limit=0":     "limit",
+		"/users/search?q=jo&limit=101":   "limit",
+		"/users/search?q=jo&offset=-1":   "offset",
+		"/users/search?q=jo&offset=next": "offset",
+	} {
+		w = httptest.NewRecorder()
+		c.SearchUsers(w, httptest.NewRequest("GET", url, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, url)
+		var p struct {
+			Errors []struct{ Field string } `json:"errors"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p), url)
+		require.Len(t, p.Errors, 1, url)
+		assert.Equal(t, field, p.Errors[0].Field, url)
+	}
+}
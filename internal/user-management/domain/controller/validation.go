@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+
+	entity "user-management/internal/user-management/domain/entities"
+	"user-management/internal/user-management/helper"
+
+	"github.com/go-playground/locales/de"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/es"
+	"github.com/go-playground/locales/fr"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	de_translations "github.com/go-playground/validator/v10/translations/de"
+	en_translations "github.com/go-playground/validator/v10/translations/en"
+	es_translations "github.com/go-playground/validator/v10/translations/es"
+	fr_translations "github.com/go-playground/validator/v10/translations/fr"
+	"golang.org/x/text/language"
+)
+
+// requestValidator checks request bodies against their validate tags and
+// names fields by their JSON name. Its messages come in every language of
+// translators, English when the client accepts none of them.
+var requestValidator, translators = newRequestValidator()
+
+func newRequestValidator() (*validator.Validate, *ut.UniversalTranslator) {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	registerProfileValidations(v)
+
+	uni := ut.New(en.New(), en.New(), de.New(), es.New(), fr.New())
+	for locale, register := range map[string]func(*validator.Validate, ut.Translator) error{
+		"en": en_translations.RegisterDefaultTranslations,
+		"de": de_translations.RegisterDefaultTranslations,
+		"es": es_translations.RegisterDefaultTranslations,
+		"fr": fr_translations.RegisterDefaultTranslations,
+	} {
+		trans, _ := uni.GetTranslator(locale)
+		if err := register(v, trans); err != nil {
+			panic(err)
+		}
+		if err := registerProfileTranslations(v, locale, trans); err != nil {
+			panic(err)
+		}
+	}
+	return v, uni
+}
+
+// translator returns the translator for the most preferred language of
+// r's Accept-Language header that there is one for.
+func translator(r *http.Request) ut.Translator {
+	tags, _, _ := language.ParseAcceptLanguage(r.Header.Get("Accept-Language"))
+	locales := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		base, _ := tag.Base()
+		locales = append(locales, base.String())
+	}
+	trans, _ := translators.FindTranslator(locales...)
+	return trans
+}
+
+// validateRequest checks v, a decoded request body, and returns a
+// *requestError listing every invalid field.
+func validateRequest(r *http.Request, v any) error {
+	return validateIn(translator(r), v)
+}
+
+// validateIn is validateRequest with the messages from trans.
+func validateIn(trans ut.Translator, v any) error {
+	err := requestValidator.Struct(v)
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		return err
+	}
+
+	fields := make([]helper.FieldError, 0, len(fieldErrs))
+	for _, fe := range fieldErrs {
+		fields = append(fields, helper.FieldError{
+			Field:   fieldPath(fe),
+			Rule:    fe.Tag(),
+			Param:   fe.Param(),
+			Message: fe.Translate(trans),
+		})
+	}
+	return &requestError{
+		status: http.StatusBadRequest,
+		detail: "request body failed validation",
+		fields: fields,
+	}
+}
+
+// englishTranslator has the messages of CheckNewUser.
+var englishTranslator, _ = translators.GetTranslator("en")
+
+// CheckNewUser checks u like the body of POST /users, with the messages in
+// English, and returns it as POST /users would store it. users import
+// checks rows with it.
+func CheckNewUser(u entity.User) (entity.User, []helper.FieldError) {
+	req := CreateUserRequest{Name: u.Name, Email: u.Email, Profile: (*UserProfile)(u.Profile)}
+	err := validateIn(englishTranslator, req)
+	if err == nil {
+		return req.toEntity(), nil
+	}
+	re := &requestError{detail: err.Error()}
+	errors.As(err, &re)
+	if len(re.fields) == 0 {
+		return u, []helper.FieldError{{Message: re.detail}}
+	}
+	return u, re.fields
+}
+
+// fieldPath returns the JSON path of the field fe is about, e.g.
+// events[0] for the namespace WebhookSubscription.events[0].
+func fieldPath(fe validator.FieldError) string {
+	_, path, ok := strings.Cut(fe.Namespace(), ".")
+	if !ok {
+		return fe.Field()
+	}
+	return path
+}
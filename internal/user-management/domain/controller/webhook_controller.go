@@ -0,0 +1,238 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	entity "user-management/internal/user-management/domain/entities"
+	"user-management/internal/user-management/domain/service"
+
+	"github.com/gorilla/mux"
+)
+
+type webhookController struct {
+	webhookService service.IWebhookService
+}
+
+func NewWebhookController(webhookService service.IWebhookService) *webhookController {
+	return &webhookController{
+		webhookService: webhookService,
+	}
+}
+
+// CreateSubscription godoc
+// @Summary      Create a webhook subscription
+// @Description  Subscribe a URL to user events. The secret is used to sign every delivery.
+// @Tags         webhooks
+// @Accept       json
+// @Produce      json
+// @Param        subscription  body      entity.WebhookSubscription  true  "Subscription"
+// @Success      201           {object}  entity.WebhookSubscription
+// @Failure      400           {object}  problem  "Invalid request"
+// @Failure      413           {object}  problem  "Request body too large"
+// @Failure      415           {object}  problem  "Content-Type is not application/json"
+// @Failure      500           {string}  string  "Internal server error"
+// @Router       /webhooks [post]
+func (c *webhookController) CreateSubscription(w http.ResponseWriter, r *http.Request) {
+	var sub entity.WebhookSubscription
+	if err := readJSON(r, &sub); err != nil {
+		writeRequestError(w, r, err)
+		return
+	}
+
+	created, err := c.webhookService.CreateSubscription(r.Context(), sub)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// GetSubscriptions godoc
+// @Summary      List webhook subscriptions
+// @Description  Get all webhook subscriptions. Secrets are not returned.
+// @Tags         webhooks
+// @Produce      json
+// @Success      200  {array}   entity.WebhookSubscription
+// @Failure      500  {string}  string  "Internal server error"
+// @Router       /webhooks [get]
+func (c *webhookController) GetSubscriptions(w http.ResponseWriter, r *http.Request) {
+	subs, err := c.webhookService.ListSubscriptions(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for i := range subs {
+		subs[i].Secret = ""
+	}
+	json.NewEncoder(w).Encode(subs)
+}
+
+// GetSubscriptionByID godoc
+// @Summary      Get webhook subscription
+// @Description  Retrieve a single webhook subscription. The secret is not returned.
+// @Tags         webhooks
+// @Produce      json
+// @Param        id   path      int  true  "Subscription ID"
+// @Success      200  {object}  entity.WebhookSubscription
+// @Failure      400  {string}  string  "Invalid subscription ID"
+// @Failure      404  {string}  string  "Subscription not found"
+// @Router       /webhooks/{id} [get]
+func (c *webhookController) GetSubscriptionByID(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid subscription ID", http.StatusBadRequest)
+		return
+	}
+
+	sub, err := c.webhookService.GetSubscriptionByID(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	sub.Secret = ""
+	json.NewEncoder(w).Encode(sub)
+}
+
+// UpdateSubscription godoc
+// @Summary      Update webhook subscription
+// @Description  Replace the URL, events, secret and active flag of a subscription
+// @Tags         webhooks
+// @Accept       json
+// @Param        id            path      int                         true  "Subscription ID"
+// @Param        subscription  body      entity.WebhookSubscription  true  "Subscription"
+// @Success      200           {string}  string  "OK"
+// @Failure      400           {object}  problem  "Invalid input"
+// @Failure      404           {string}  string  "Subscription not found"
+// @Failure      413           {object}  problem  "Request body too large"
+// @Failure      415           {object}  problem  "Content-Type is not application/json"
+// @Failure      500           {string}  string  "Internal server error"
+// @Router       /webhooks/{id} [put]
+func (c *webhookController) UpdateSubscription(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid subscription ID", http.StatusBadRequest)
+		return
+	}
+
+	var sub entity.WebhookSubscription
+	if err := readJSON(r, &sub); err != nil {
+		writeRequestError(w, r, err)
+		return
+	}
+
+	sub.ID = id
+
+	if err := c.webhookService.UpdateSubscription(r.Context(), sub); err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// DeleteSubscription godoc
+// @Summary      Delete webhook subscription
+// @Description  Remove a webhook subscription by ID
+// @Tags         webhooks
+// @Param        id   path      int  true  "Subscription ID"
+// @Success      204  {string}  string  "No Content"
+// @Failure      400  {string}  string  "Invalid subscription ID"
+// @Failure      404  {string}  string  "Subscription not found"
+// @Failure      500  {string}  string  "Internal server error"
+// @Router       /webhooks/{id} [delete]
+func (c *webhookController) DeleteSubscription(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid subscription ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := c.webhookService.DeleteSubscription(r.Context(), id); err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetDeliveries godoc
+// @Summary      List webhook deliveries
+// @Description  Inspect webhook deliveries, newest first, optionally filtered by status
+// @Tags         webhooks-admin
+// @Produce      json
+// @Param        status  query     string  false  "Delivery status"  Enums(pending, succeeded, dead)
+// @Success      200     {array}   entity.WebhookDelivery
+// @Failure      400     {string}  string  "Invalid status"
+// @Failure      500     {string}  string  "Internal server error"
+// @Router       /admin/webhooks/deliveries [get]
+func (c *webhookController) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	status := entity.WebhookDeliveryStatus(r.URL.Query().Get("status"))
+	switch status {
+	case "", entity.DeliveryPending, entity.DeliverySucceeded, entity.DeliveryDead:
+	default:
+		http.Error(w, "Invalid status", http.StatusBadRequest)
+		return
+	}
+
+	deliveries, err := c.webhookService.ListDeliveries(r.Context(), status)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+// GetDeliveryByID godoc
+// @Summary      Get webhook delivery
+// @Description  Inspect a single webhook delivery, including its payload and last error
+// @Tags         webhooks-admin
+// @Produce      json
+// @Param        id   path      int  true  "Delivery ID"
+// @Success      200  {object}  entity.WebhookDelivery
+// @Failure      400  {string}  string  "Invalid delivery ID"
+// @Failure      404  {string}  string  "Delivery not found"
+// @Router       /admin/webhooks/deliveries/{id} [get]
+func (c *webhookController) GetDeliveryByID(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid delivery ID", http.StatusBadRequest)
+		return
+	}
+
+	delivery, err := c.webhookService.GetDeliveryByID(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	json.NewEncoder(w).Encode(delivery)
+}
+
+// ReplayDelivery godoc
+// @Summary      Replay webhook delivery
+// @Description  Queue a delivery again with a fresh attempt budget, e.g. after it was dead-lettered
+// @Tags         webhooks-admin
+// @Produce      json
+// @Param        id   path      int  true  "Delivery ID"
+// @Success      202  {object}  entity.WebhookDelivery
+// @Failure      400  {string}  string  "Invalid delivery ID"
+// @Failure      404  {string}  string  "Delivery not found"
+// @Failure      500  {string}  string  "Internal server error"
+// @Router       /admin/webhooks/deliveries/{id}/replay [post]
+func (c *webhookController) ReplayDelivery(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid delivery ID", http.StatusBadRequest)
+		return
+	}
+
+	delivery, err := c.webhookService.ReplayDelivery(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(delivery)
+}
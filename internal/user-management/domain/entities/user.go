@@ -1,25 +1,96 @@
 package entity
 
-import "user-management/internal/user-management/infrastructure/model"
+import (
+	"time"
 
+	"user-management/internal/user-management/infrastructure/model"
+)
+
+// User is a user of the domain. Requests and responses of the HTTP API
+// have their own types in the controller package; the JSON form is that of
+// events and webhook payloads.
 type User struct {
-	ID    int64  `json:"id" bun:",pk,autoincrement"`
-	Name  string `json:"name" validate:"required,min=2"`
-	Email string `json:"email" validate:"required,email"`
+	ID    int64  `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	// Profile is nil for users without one. Updating a user with a nil
+	// Profile leaves the stored one as it is.
+	Profile *UserProfile `json:"profile,omitempty"`
+}
+
+// DateLayout is the layout of dates such as UserProfile.Birthdate.
+const DateLayout = "2006-01-02"
+
+// UserProfile holds the optional details of a user; empty fields are
+// unknown. Phone is in E.164 form, Locale a BCP 47 language tag, Timezone
+// an IANA time zone name and Birthdate in DateLayout.
+type UserProfile struct {
+	DisplayName string `json:"display_name,omitempty"`
+	Phone       string `json:"phone,omitempty"`
+	Locale      string `json:"locale,omitempty"`
+	Timezone    string `json:"timezone,omitempty"`
+	AvatarURL   string `json:"avatar_url,omitempty"`
+	Birthdate   string `json:"birthdate,omitempty"`
+}
+
+// UserFilter narrows down a user listing. Name matches case-insensitively
+// anywhere in the name, Email must match exactly, as must one of Emails,
+// and AfterID leaves out users with that ID or lower, to page through all
+// users by the last ID seen. Zero values mean no filtering; a zero Limit
+// means no limit.
+type UserFilter struct {
+	Name    string
+	Email   string
+	Emails  []string
+	AfterID int64
+	Limit   int
+	Offset  int
 }
 
 func ToEntity(u model.User) User {
-	return User{
+	e := User{
 		ID:    u.ID,
 		Name:  u.Name,
 		Email: u.Email,
 	}
+	p := UserProfile{
+		DisplayName: u.DisplayName,
+		Phone:       u.Phone,
+		Locale:      u.Locale,
+		Timezone:    u.Timezone,
+		AvatarURL:   u.AvatarURL,
+	}
+	if !u.Birthdate.IsZero() {
+		p.Birthdate = u.Birthdate.Format(DateLayout)
+	}
+	if p != (UserProfile{}) {
+		e.Profile = &p
+	}
+	return e
 }
 
+// FromEntity converts e to its row. A Birthdate that does not parse is
+// stored as unknown.
 func FromEntity(e User) model.User {
-	return model.User{
+	u := model.User{
 		ID:    e.ID,
 		Name:  e.Name,
 		Email: e.Email,
 	}
+	if p := e.Profile; p != nil {
+		u.DisplayName = p.DisplayName
+		u.Phone = p.Phone
+		u.Locale = p.Locale
+		u.Timezone = p.Timezone
+		u.AvatarURL = p.AvatarURL
+		u.Birthdate, _ = time.Parse(DateLayout, p.Birthdate)
+	}
+	return u
+}
+
+// UserBatchResult is what became of one item of a batch operation: the
+// user as stored, or the error that kept it from being stored.
+type UserBatchResult struct {
+	User User
+	Err  error
 }
@@ -0,0 +1,19 @@
+package entity
+
+import "time"
+
+type UserEventType string
+
+const (
+	UserCreated UserEventType = "user.created"
+	UserUpdated UserEventType = "user.updated"
+	UserDeleted UserEventType = "user.deleted"
+)
+
+var UserEventTypes = []UserEventType{UserCreated, UserUpdated, UserDeleted}
+
+type UserEvent struct {
+	Type       UserEventType `json:"type"`
+	User       User          `json:"user"`
+	OccurredAt time.Time     `json:"occurred_at"`
+}
@@ -0,0 +1,35 @@
+package entity
+
+// UserSearchQuery looks users up by free text: every word of Text has to
+// match the name or the email, exactly or close to it. Limit and Offset
+// page through the hits; a zero Limit means no limit.
+type UserSearchQuery struct {
+	Text   string
+	Limit  int
+	Offset int
+}
+
+// UserSearchResult is a page of hits, best first, and how many hits
+// there are in all.
+type UserSearchResult struct {
+	Hits  []UserSearchHit
+	Total int
+}
+
+// UserSearchHit is a user that matched, how well (higher is better) and
+// where. Scores rank the hits of one result only: the MySQL FULLTEXT
+// relevance is unbounded, the in-process ranking goes up to 1, and a
+// result has one or the other.
+type UserSearchHit struct {
+	User       User
+	Score      float64
+	Highlights []Highlight
+}
+
+// Highlight marks a match in a field of a user: the bytes Start to End of
+// its value. Highlights of a field are in order and do not overlap.
+type Highlight struct {
+	Field string
+	Start int
+	End   int
+}
@@ -0,0 +1,117 @@
+package entity
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"user-management/internal/user-management/infrastructure/model"
+
+	"github.com/uptrace/bun"
+)
+
+type WebhookSubscription struct {
+	ID        int64     `json:"id"`
+	URL       string    `json:"url" validate:"required,url"`
+	Events    []string  `json:"events" validate:"required,min=1,dive,oneof=user.created user.updated user.deleted"`
+	Secret    string    `json:"secret,omitempty" validate:"required,min=16"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Subscribed reports whether the subscription wants events of type t.
+func (s WebhookSubscription) Subscribed(t UserEventType) bool {
+	for _, e := range s.Events {
+		if e == string(t) {
+			return true
+		}
+	}
+	return false
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	DeliveryPending   WebhookDeliveryStatus = "pending"
+	DeliverySucceeded WebhookDeliveryStatus = "succeeded"
+	DeliveryDead      WebhookDeliveryStatus = "dead"
+)
+
+type WebhookDelivery struct {
+	ID             int64                 `json:"id"`
+	SubscriptionID int64                 `json:"subscription_id"`
+	EventType      UserEventType         `json:"event_type"`
+	Payload        json.RawMessage       `json:"payload" swaggertype:"object"`
+	Status         WebhookDeliveryStatus `json:"status"`
+	Attempts       int                   `json:"attempts"`
+	LastError      string                `json:"last_error,omitempty"`
+	LastStatusCode int                   `json:"last_status_code,omitempty"`
+	NextAttemptAt  time.Time             `json:"next_attempt_at"`
+	DeliveredAt    *time.Time            `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time             `json:"created_at"`
+}
+
+func ToSubscriptionEntity(s model.WebhookSubscription) WebhookSubscription {
+	var events []string
+	if s.Events != "" {
+		events = strings.Split(s.Events, ",")
+	}
+	return WebhookSubscription{
+		ID:        s.ID,
+		URL:       s.URL,
+		Events:    events,
+		Secret:    s.Secret,
+		Active:    s.Active,
+		CreatedAt: s.CreatedAt,
+	}
+}
+
+func FromSubscriptionEntity(e WebhookSubscription) model.WebhookSubscription {
+	return model.WebhookSubscription{
+		ID:        e.ID,
+		URL:       e.URL,
+		Events:    strings.Join(e.Events, ","),
+		Secret:    e.Secret,
+		Active:    e.Active,
+		CreatedAt: e.CreatedAt,
+	}
+}
+
+func ToDeliveryEntity(d model.WebhookDelivery) WebhookDelivery {
+	e := WebhookDelivery{
+		ID:             d.ID,
+		SubscriptionID: d.SubscriptionID,
+		EventType:      UserEventType(d.EventType),
+		Payload:        json.RawMessage(d.Payload),
+		Status:         WebhookDeliveryStatus(d.Status),
+		Attempts:       d.Attempts,
+		LastError:      d.LastError,
+		LastStatusCode: d.LastStatusCode,
+		NextAttemptAt:  d.NextAttemptAt,
+		CreatedAt:      d.CreatedAt,
+	}
+	if !d.DeliveredAt.IsZero() {
+		t := d.DeliveredAt.Time
+		e.DeliveredAt = &t
+	}
+	return e
+}
+
+func FromDeliveryEntity(e WebhookDelivery) model.WebhookDelivery {
+	d := model.WebhookDelivery{
+		ID:             e.ID,
+		SubscriptionID: e.SubscriptionID,
+		EventType:      string(e.EventType),
+		Payload:        string(e.Payload),
+		Status:         string(e.Status),
+		Attempts:       e.Attempts,
+		LastError:      e.LastError,
+		LastStatusCode: e.LastStatusCode,
+		NextAttemptAt:  e.NextAttemptAt,
+		CreatedAt:      e.CreatedAt,
+	}
+	if e.DeliveredAt != nil {
+		d.DeliveredAt = bun.NullTime{Time: *e.DeliveredAt}
+	}
+	return d
+}
@@ -1,30 +1,69 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 type IPInfoClient interface {
-	GetInfo(ip string) (map[string]interface{}, error)
+	GetInfo(ctx context.Context, ip string) (map[string]interface{}, error)
 }
 
+// ErrNoIPInfoToken is returned by lookups while there is no API token;
+// they are off until one is configured.
+var ErrNoIPInfoToken = errors.New("ipinfo: no API token (USER_GEO_API_TOKEN)")
+
 type httpIPInfoClient struct {
-	apiToken string
+	apiToken func() string
+	client   *http.Client
 }
 
-func NewIPInfoClient(token string) IPInfoClient {
-	return &httpIPInfoClient{apiToken: token}
+// NewIPInfoClient returns a client that asks token for the API token on
+// every request, so a rotated token is used right away. Requests are
+// traced as children of the span in their context, which they pass on in
+// the traceparent header.
+func NewIPInfoClient(token func() string) IPInfoClient {
+	return &httpIPInfoClient{
+		apiToken: token,
+		client: &http.Client{
+			Transport: otelhttp.NewTransport(http.DefaultTransport,
+				otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+					return "ipinfo " + r.Method
+				}),
+			),
+		},
+	}
 }
 
-func (c *httpIPInfoClient) GetInfo(ip string) (map[string]interface{}, error) {
-	url := fmt.Sprintf("https://ipinfo.io/%s?token=%s", ip, c.apiToken)
-	resp, err := http.Get(url)
+func (c *httpIPInfoClient) GetInfo(ctx context.Context, ip string) (map[string]interface{}, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("https://ipinfo.io/%s", url.PathEscape(ip)), nil)
+	if err != nil {
+		return nil, err
+	}
+	// In a header rather than the query string, the token cannot end up in
+	// the URL that errors quote.
+	token := c.apiToken()
+	if token == "" {
+		return nil, ErrNoIPInfoToken
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := c.client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ipinfo: %s", resp.Status)
+	}
 
 	var result map[string]interface{}
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
@@ -32,3 +71,42 @@ func (c *httpIPInfoClient) GetInfo(ip string) (map[string]interface{}, error) {
 	}
 	return result, nil
 }
+
+// InstrumentIPInfoClient wraps client to record the latency of its lookups
+// and count the failed ones, and registers the metrics with reg.
+func InstrumentIPInfoClient(client IPInfoClient, reg prometheus.Registerer) IPInfoClient {
+	c := &instrumentedIPInfoClient{
+		next: client,
+		duration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "ipinfo_request_duration_seconds",
+			Help:    "Duration of ipinfo.io lookups, failed ones included.",
+			Buckets: prometheus.DefBuckets,
+		}),
+		errors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "ipinfo_request_errors_total",
+			Help: "ipinfo.io lookups that failed.",
+		}),
+	}
+	reg.MustRegister(c.duration, c.errors)
+	return c
+}
+
+type instrumentedIPInfoClient struct {
+	next     IPInfoClient
+	duration prometheus.Histogram
+	errors   prometheus.Counter
+}
+
+func (c *instrumentedIPInfoClient) GetInfo(ctx context.Context, ip string) (map[string]interface{}, error) {
+	start := time.Now()
+	info, err := c.next.GetInfo(ctx, ip)
+	if errors.Is(err, ErrNoIPInfoToken) {
+		// Nothing was looked up.
+		return nil, err
+	}
+	c.duration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		c.errors.Inc()
+	}
+	return info, err
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"user-management/mocks"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestInstrumentIPInfoClient(t *testing.T) {
+	next := mocks.NewIPInfoClient(t)
+	next.On("GetInfo", mock.Anything, "1.1.1.1").Return(map[string]interface{}{"city": "Test"}, nil)
+	next.On("GetInfo", mock.Anything, "2.2.2.2").Return(nil, errors.New("ipinfo: 429 Too Many Requests"))
+
+	reg := prometheus.NewRegistry()
+	c := InstrumentIPInfoClient(next, reg)
+
+	info, err := c.GetInfo(context.Background(), "1.1.1.1")
+	assert.NoError(t, err)
+	assert.Equal(t, "Test", info["city"])
+	_, err = c.GetInfo(context.Background(), "2.2.2.2")
+	assert.Error(t, err)
+
+	ic := c.(*instrumentedIPInfoClient)
+	assert.Equal(t, 1.0, testutil.ToFloat64(ic.errors))
+	assert.Equal(t, 1, testutil.CollectAndCount(reg, "ipinfo_request_duration_seconds"))
+}
+
+func TestIPInfoClientWithoutToken(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	c := InstrumentIPInfoClient(NewIPInfoClient(func() string { return "" }), reg)
+
+	_, err := c.GetInfo(context.Background(), "1.1.1.1")
+	assert.ErrorIs(t, err, ErrNoIPInfoToken)
+	assert.Equal(t, 0.0, testutil.ToFloat64(c.(*instrumentedIPInfoClient).errors), "no lookup, no failure")
+}
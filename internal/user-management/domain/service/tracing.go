@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+
+	entity "user-management/internal/user-management/domain/entities"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("user-management/internal/user-management/domain/service")
+
+// endSpan records err, if any, on span and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func startBatchSpan(ctx context.Context, name string, size int, transactional bool) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(
+		attribute.Int("batch.size", size),
+		attribute.Bool("batch.transactional", transactional),
+	))
+}
+
+// endBatchSpan records how many items of a batch failed on span, and the
+// first error as that of the span, and ends it.
+func endBatchSpan(span trace.Span, results []entity.UserBatchResult) {
+	var first error
+	failed := 0
+	for _, res := range results {
+		if res.Err != nil {
+			failed++
+			if first == nil {
+				first = res.Err
+			}
+		}
+	}
+	span.SetAttributes(attribute.Int("batch.failed", failed))
+	endSpan(span, first)
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	entity "user-management/internal/user-management/domain/entities"
+)
+
+var ErrBrokerClosed = errors.New("user event broker is closed")
+
+// StreamedUserEvent is a user event tagged with the broker-assigned,
+// strictly increasing ID used for Last-Event-ID resume.
+type StreamedUserEvent struct {
+	ID    uint64
+	Event entity.UserEvent
+}
+
+// UserEventBroker fans user events out to live streams and keeps the most
+// recent ones in a bounded buffer so reconnecting clients can catch up.
+type UserEventBroker struct {
+	mu      sync.Mutex
+	nextID  uint64
+	replay  []StreamedUserEvent
+	size    int
+	streams map[*UserEventStream]struct{}
+	closed  bool
+}
+
+func NewUserEventBroker(replaySize int) *UserEventBroker {
+	if replaySize <= 0 {
+		replaySize = 1000
+	}
+	return &UserEventBroker{
+		size:    replaySize,
+		replay:  make([]StreamedUserEvent, 0, replaySize),
+		streams: make(map[*UserEventStream]struct{}),
+	}
+}
+
+// UserEventStream receives the events of one subscriber. C is closed when
+// the broker shuts down, or when the subscriber falls so far behind that
+// its queue overflows; the client is then expected to reconnect with the
+// last ID it saw.
+type UserEventStream struct {
+	C <-chan StreamedUserEvent
+
+	c      chan StreamedUserEvent
+	types  map[entity.UserEventType]bool
+	broker *UserEventBroker
+}
+
+func (s *UserEventStream) wants(t entity.UserEventType) bool {
+	return len(s.types) == 0 || s.types[t]
+}
+
+// Close unsubscribes the stream. It is safe to call more than once.
+func (s *UserEventStream) Close() {
+	b := s.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.drop(s)
+}
+
+func (b *UserEventBroker) Publish(_ context.Context, event entity.UserEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+
+	b.nextID++
+	se := StreamedUserEvent{ID: b.nextID, Event: event}
+
+	if len(b.replay) == b.size {
+		copy(b.replay, b.replay[1:])
+		b.replay = b.replay[:len(b.replay)-1]
+	}
+	b.replay = append(b.replay, se)
+
+	for s := range b.streams {
+		if !s.wants(event.Type) {
+			continue
+		}
+		select {
+		case s.c <- se:
+		default:
+			b.drop(s)
+		}
+	}
+}
+
+// Subscribe registers a stream for the given event types (all types when
+// empty). When resume is set, buffered events newer than lastID that match
+// the filter are returned so the caller can send them before reading C.
+func (b *UserEventBroker) Subscribe(types []entity.UserEventType, lastID uint64, resume bool) (*UserEventStream, []StreamedUserEvent, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, nil, ErrBrokerClosed
+	}
+
+	c := make(chan StreamedUserEvent, 64)
+	s := &UserEventStream{C: c, c: c, broker: b}
+	if len(types) > 0 {
+		s.types = make(map[entity.UserEventType]bool, len(types))
+		for _, t := range types {
+			s.types[t] = true
+		}
+	}
+
+	var missed []StreamedUserEvent
+	if resume {
+		for _, se := range b.replay {
+			if se.ID > lastID && s.wants(se.Event.Type) {
+				missed = append(missed, se)
+			}
+		}
+	}
+
+	b.streams[s] = struct{}{}
+	return s, missed, nil
+}
+
+// Close ends every open stream and rejects new subscribers.
+func (b *UserEventBroker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for s := range b.streams {
+		b.drop(s)
+	}
+}
+
+func (b *UserEventBroker) drop(s *UserEventStream) {
+	if _, ok := b.streams[s]; !ok {
+		return
+	}
+	delete(b.streams, s)
+	close(s.c)
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"testing"
+	entity "user-management/internal/user-management/domain/entities"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func userEvent(t entity.UserEventType, id int64) entity.UserEvent {
+	return entity.UserEvent{Type: t, User: entity.User{ID: id}}
+}
+
+func TestUserEventBroker_DeliversMatchingEvents(t *testing.T) {
+	b := NewUserEventBroker(10)
+
+	stream, missed, err := b.Subscribe([]entity.UserEventType{entity.UserDeleted}, 0, false)
+	assert.NoError(t, err)
+	assert.Empty(t, missed)
+	defer stream.Close()
+
+	b.Publish(context.Background(), userEvent(entity.UserCreated, 1))
+	b.Publish(context.Background(), userEvent(entity.UserDeleted, 1))
+
+	se := <-stream.C
+	assert.Equal(t, uint64(2), se.ID)
+	assert.Equal(t, entity.UserDeleted, se.Event.Type)
+	assert.Len(t, stream.C, 0)
+}
+
+func TestUserEventBroker_ResumeFromBoundedBuffer(t *testing.T) {
+	b := NewUserEventBroker(3)
+	for i := int64(1); i <= 5; i++ {
+		b.Publish(context.Background(), userEvent(entity.UserUpdated, i))
+	}
+
+	stream, missed, err := b.Subscribe(nil, 3, true)
+	assert.NoError(t, err)
+	defer stream.Close()
+	if assert.Len(t, missed, 2) {
+		assert.Equal(t, uint64(4), missed[0].ID)
+		assert.Equal(t, uint64(5), missed[1].ID)
+	}
+
+	// Only the last three events are kept, so resuming from an evicted
+	// ID returns whatever is still buffered.
+	stream2, missed, err := b.Subscribe(nil, 0, true)
+	assert.NoError(t, err)
+	defer stream2.Close()
+	assert.Len(t, missed, 3)
+	assert.Equal(t, uint64(3), missed[0].ID)
+}
+
+func TestUserEventBroker_SlowStreamIsDropped(t *testing.T) {
+	b := NewUserEventBroker(10)
+	stream, _, err := b.Subscribe(nil, 0, false)
+	assert.NoError(t, err)
+
+	for i := int64(0); i <= int64(cap(stream.c)); i++ {
+		b.Publish(context.Background(), userEvent(entity.UserCreated, i))
+	}
+
+	n := 0
+	for range stream.C {
+		n++
+	}
+	assert.Equal(t, cap(stream.c), n)
+	stream.Close()
+}
+
+func TestUserEventBroker_CloseEndsStreams(t *testing.T) {
+	b := NewUserEventBroker(10)
+	stream, _, err := b.Subscribe(nil, 0, false)
+	assert.NoError(t, err)
+
+	b.Close()
+
+	_, ok := <-stream.C
+	assert.False(t, ok)
+	stream.Close()
+
+	_, _, err = b.Subscribe(nil, 0, false)
+	assert.ErrorIs(t, err, ErrBrokerClosed)
+
+	b.Publish(context.Background(), userEvent(entity.UserCreated, 1))
+}
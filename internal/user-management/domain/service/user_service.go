@@ -1,57 +1,408 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
 	"user-management/internal/user-management/domain"
 	entity "user-management/internal/user-management/domain/entities"
+	"user-management/internal/user-management/helper"
 
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type IUserService interface {
-	RegisterUser(user entity.User, ip string) (map[string]interface{}, error)
-	ListUsers() ([]entity.User, error)
-	GetUserByID(id int64) (entity.User, error)
-	UpdateUser(user entity.User) error
-	DeleteUser(id int64) error
+	// RegisterUser creates user and returns it with its ID, along with
+	// what ipinfo knows about ip, nil when the lookup failed.
+	RegisterUser(ctx context.Context, user entity.User, ip string) (entity.User, map[string]interface{}, error)
+	ListUsers(ctx context.Context, filter entity.UserFilter) ([]entity.User, error)
+	// SearchUsers finds users by free text, best matches first.
+	SearchUsers(ctx context.Context, query entity.UserSearchQuery) (entity.UserSearchResult, error)
+	// StreamUsers calls fn with each user ListUsers would return, as they
+	// are read, and stops at the first error fn returns.
+	StreamUsers(ctx context.Context, filter entity.UserFilter, fn func(user entity.User) error) error
+	GetUserByID(ctx context.Context, id int64) (entity.User, error)
+	// UpdateUser replaces the user with user.ID and returns it as stored,
+	// with the stored profile when user has none.
+	UpdateUser(ctx context.Context, user entity.User) (entity.User, error)
+	DeleteUser(ctx context.Context, id int64) error
+
+	// CreateUsers creates users, BatchSize per statement and without
+	// looking up addresses. The result at each index tells what became of
+	// the user at that index. With transactional, either all users are
+	// created or none; the users that did not fail themselves then fail
+	// with ErrBatchAborted. UpdateUsers and DeleteUsers work the same way.
+	CreateUsers(ctx context.Context, users []entity.User, transactional bool) []entity.UserBatchResult
+	UpdateUsers(ctx context.Context, users []entity.User, transactional bool) []entity.UserBatchResult
+	DeleteUsers(ctx context.Context, ids []int64, transactional bool) []entity.UserBatchResult
+}
+
+// ErrBatchAborted is the error of the items of a transactional batch that
+// were not applied because another item failed.
+var ErrBatchAborted = errors.New("not applied because another item of the transactional batch failed")
+
+// UserServiceOptions tunes the batch operations of the user service.
+type UserServiceOptions struct {
+	// BatchSize is the number of users created per statement, 100 if not
+	// positive.
+	BatchSize int
 }
 
 type userService struct {
 	repo         domain.IUserRepository
+	searcher     domain.IUserSearcher
 	ipInfoClient IPInfoClient
+	opts         UserServiceOptions
+	publishers   []domain.IUserEventPublisher
+}
+
+// NewUserService returns the user service over r. SearchUsers asks
+// searcher, which may be nil where nothing searches.
+func NewUserService(r domain.IUserRepository, searcher domain.IUserSearcher, ipInfoClient IPInfoClient, opts UserServiceOptions, publishers ...domain.IUserEventPublisher) IUserService {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	return &userService{repo: r, searcher: searcher, ipInfoClient: ipInfoClient, opts: opts, publishers: publishers}
 }
 
-func NewUserService(r domain.IUserRepository, ipInfoClient IPInfoClient) IUserService {
-	return &userService{repo: r, ipInfoClient: ipInfoClient}
+func (s *userService) publish(ctx context.Context, t entity.UserEventType, user entity.User) {
+	event := entity.UserEvent{Type: t, User: user, OccurredAt: time.Now().UTC()}
+	for _, p := range s.publishers {
+		p.Publish(ctx, event)
+	}
 }
 
-func (s *userService) RegisterUser(user entity.User, ip string) (map[string]interface{}, error) {
-	ipInfo, err := s.ipInfoClient.GetInfo(ip)
+func (s *userService) RegisterUser(ctx context.Context, user entity.User, ip string) (_ entity.User, _ map[string]interface{}, err error) {
+	ctx, span := tracer.Start(ctx, "userService.RegisterUser")
+	defer func() { endSpan(span, err) }()
+
+	ipInfo, err := s.ipInfoClient.GetInfo(ctx, ip)
 	if err != nil {
-		log.Error().Msgf("RegisterUser error getting Geo API")
-		ipInfo = map[string]interface{}{
-			"Couldn't call the geo API": "true",
+		// Registering does not depend on the lookup.
+		if !errors.Is(err, ErrNoIPInfoToken) {
+			log.Ctx(ctx).Error().Err(err).Msg("RegisterUser error getting Geo API")
 		}
+		ipInfo = nil
+	}
+	id, err := s.repo.Create(ctx, user)
+	if err != nil {
+		return entity.User{}, nil, err
 	}
-	id, err := s.repo.Create(user)
+	user.ID = id
+	s.publish(ctx, entity.UserCreated, user)
+	return user, ipInfo, nil
+}
+
+func (s *userService) ListUsers(ctx context.Context, filter entity.UserFilter) (_ []entity.User, err error) {
+	ctx, span := tracer.Start(ctx, "userService.ListUsers")
+	defer func() { endSpan(span, err) }()
+
+	return s.repo.GetAll(ctx, filter)
+}
+
+func (s *userService) SearchUsers(ctx context.Context, query entity.UserSearchQuery) (_ entity.UserSearchResult, err error) {
+	ctx, span := tracer.Start(ctx, "userService.SearchUsers", trace.WithAttributes(
+		attribute.Int("search.limit", query.Limit),
+		attribute.Int("search.offset", query.Offset),
+	))
+	defer func() { endSpan(span, err) }()
+
+	if s.searcher == nil {
+		return entity.UserSearchResult{}, errors.New("user search is not available")
+	}
+	result, err := s.searcher.Search(ctx, query)
+	span.SetAttributes(attribute.Int("search.total", result.Total))
+	return result, err
+}
+
+func (s *userService) StreamUsers(ctx context.Context, filter entity.UserFilter, fn func(user entity.User) error) (err error) {
+	ctx, span := tracer.Start(ctx, "userService.StreamUsers")
+	n := 0
+	defer func() {
+		span.SetAttributes(attribute.Int("users.streamed", n))
+		endSpan(span, err)
+	}()
+
+	return s.repo.Stream(ctx, filter, func(user entity.User) error {
+		n++
+		return fn(user)
+	})
+}
+
+func (s *userService) GetUserByID(ctx context.Context, id int64) (_ entity.User, err error) {
+	ctx, span := tracer.Start(ctx, "userService.GetUserByID", trace.WithAttributes(attribute.Int64("user.id", id)))
+	defer func() { endSpan(span, err) }()
+
+	return s.repo.GetByID(ctx, id)
+}
+
+func (s *userService) UpdateUser(ctx context.Context, user entity.User) (_ entity.User, err error) {
+	ctx, span := tracer.Start(ctx, "userService.UpdateUser", trace.WithAttributes(attribute.Int64("user.id", user.ID)))
+	defer func() { endSpan(span, err) }()
+
+	err = s.repo.RunInTx(ctx, func(ctx context.Context) error {
+		var err error
+		user, err = s.update(ctx, user)
+		return err
+	})
 	if err != nil {
-		return map[string]interface{}{}, err
+		return entity.User{}, err
 	}
-	ipInfo["usedId"] = id
-	return ipInfo, nil
+	s.publish(ctx, entity.UserUpdated, user)
+	return user, nil
 }
 
-func (s *userService) ListUsers() ([]entity.User, error) {
-	return s.repo.GetAll()
+// update updates user and returns it as stored, reading the stored
+// profile back when user has none.
+func (s *userService) update(ctx context.Context, user entity.User) (entity.User, error) {
+	if err := s.repo.Update(ctx, user); err != nil {
+		return user, err
+	}
+	if user.Profile != nil {
+		return user, nil
+	}
+	stored, err := s.repo.GetByID(ctx, user.ID)
+	user.Profile = stored.Profile
+	return user, err
 }
 
-func (s *userService) GetUserByID(id int64) (entity.User, error) {
-	return s.repo.GetByID(id)
+func (s *userService) DeleteUser(ctx context.Context, id int64) (err error) {
+	ctx, span := tracer.Start(ctx, "userService.DeleteUser", trace.WithAttributes(attribute.Int64("user.id", id)))
+	defer func() { endSpan(span, err) }()
+
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.publish(ctx, entity.UserDeleted, entity.User{ID: id})
+	return nil
 }
 
-func (s *userService) UpdateUser(user entity.User) error {
-	return s.repo.Update(user)
+func (s *userService) CreateUsers(ctx context.Context, users []entity.User, transactional bool) []entity.UserBatchResult {
+	ctx, span := startBatchSpan(ctx, "userService.CreateUsers", len(users), transactional)
+	results := make([]entity.UserBatchResult, len(users))
+	defer func() { endBatchSpan(span, results) }()
+
+	for i, u := range users {
+		u.ID = 0
+		results[i].User = u
+	}
+	if !transactional {
+		for start := 0; start < len(users); start += s.opts.BatchSize {
+			s.createChunk(ctx, results[start:min(start+s.opts.BatchSize, len(users))])
+		}
+	} else if i, err := duplicateEmail(users); err != nil {
+		results[i].Err = err
+		abortBatch(results, err)
+	} else {
+		var chunk []entity.UserBatchResult
+		err := s.repo.RunInTx(ctx, func(ctx context.Context) error {
+			for start := 0; start < len(users); start += s.opts.BatchSize {
+				chunk = results[start:min(start+s.opts.BatchSize, len(users))]
+				if err := s.insertChunk(ctx, chunk); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			s.blameChunk(ctx, chunk, err)
+			abortBatch(results, err)
+			for i := range results {
+				results[i].User.ID = 0
+			}
+		}
+	}
+
+	s.publishResults(ctx, entity.UserCreated, results)
+	return results
 }
 
-func (s *userService) DeleteUser(id int64) error {
-	return s.repo.Delete(id)
+// insertChunk creates the users of chunk in one statement and sets their
+// IDs, or fails for all of them.
+func (s *userService) insertChunk(ctx context.Context, chunk []entity.UserBatchResult) error {
+	users := make([]entity.User, 0, len(chunk))
+	for _, res := range chunk {
+		users = append(users, res.User)
+	}
+	ids, err := s.repo.CreateMany(ctx, users)
+	if err != nil {
+		return err
+	}
+	for i, id := range ids {
+		chunk[i].User.ID = id
+	}
+	return nil
+}
+
+// createChunk creates the users of chunk. When the statement for all of
+// them fails, they are created one by one to tell which ones are to blame.
+func (s *userService) createChunk(ctx context.Context, chunk []entity.UserBatchResult) {
+	if err := s.insertChunk(ctx, chunk); err == nil {
+		return
+	}
+	for i := range chunk {
+		id, err := s.repo.Create(ctx, chunk[i].User)
+		chunk[i].User.ID = id
+		chunk[i].Err = err
+	}
+}
+
+// blameChunk sets the error of the users of chunk, whose statement failed
+// a transactional batch with err. A taken email is blamed on the users
+// that have one; any other error on all of them.
+func (s *userService) blameChunk(ctx context.Context, chunk []entity.UserBatchResult, err error) {
+	blamed := false
+	if helper.HasStatus(err, helper.AlreadyExists) {
+		for i, res := range chunk {
+			taken, lookupErr := s.repo.GetAll(ctx, entity.UserFilter{Email: res.User.Email, Limit: 1})
+			if lookupErr == nil && len(taken) > 0 {
+				chunk[i].Err = helper.NewError(helper.AlreadyExists, fmt.Errorf("email %q is already registered", res.User.Email))
+				blamed = true
+			}
+		}
+	}
+	if !blamed {
+		for i := range chunk {
+			chunk[i].Err = err
+		}
+	}
+}
+
+// duplicateEmail returns the index of the first user whose email an
+// earlier user has too, and the error for it.
+func duplicateEmail(users []entity.User) (int, error) {
+	seen := make(map[string]bool, len(users))
+	for i, u := range users {
+		if seen[u.Email] {
+			return i, helper.NewError(helper.AlreadyExists, fmt.Errorf("email %q is given more than once", u.Email))
+		}
+		seen[u.Email] = true
+	}
+	return 0, nil
+}
+
+func (s *userService) UpdateUsers(ctx context.Context, users []entity.User, transactional bool) []entity.UserBatchResult {
+	ctx, span := startBatchSpan(ctx, "userService.UpdateUsers", len(users), transactional)
+	results := make([]entity.UserBatchResult, len(users))
+	defer func() { endBatchSpan(span, results) }()
+
+	for i, u := range users {
+		results[i].User = u
+	}
+	if !transactional {
+		for i, u := range users {
+			results[i].User, results[i].Err = s.update(ctx, u)
+		}
+	} else {
+		err := s.repo.RunInTx(ctx, func(ctx context.Context) error {
+			for i, u := range users {
+				var err error
+				if results[i].User, err = s.update(ctx, u); err != nil {
+					results[i].Err = err
+					return err
+				}
+			}
+			return nil
+		})
+		abortBatch(results, err)
+	}
+
+	s.publishResults(ctx, entity.UserUpdated, results)
+	return results
+}
+
+func (s *userService) DeleteUsers(ctx context.Context, ids []int64, transactional bool) []entity.UserBatchResult {
+	ctx, span := startBatchSpan(ctx, "userService.DeleteUsers", len(ids), transactional)
+	results := make([]entity.UserBatchResult, len(ids))
+	defer func() { endBatchSpan(span, results) }()
+
+	for i, id := range ids {
+		results[i].User = entity.User{ID: id}
+	}
+	if !transactional {
+		for start := 0; start < len(ids); start += s.opts.BatchSize {
+			s.deleteChunk(ctx, results[start:min(start+s.opts.BatchSize, len(ids))])
+		}
+	} else {
+		err := s.repo.RunInTx(ctx, func(ctx context.Context) error {
+			for start := 0; start < len(ids); start += s.opts.BatchSize {
+				if err := s.deleteChunk(ctx, results[start:min(start+s.opts.BatchSize, len(ids))]); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		abortBatch(results, err)
+	}
+
+	s.publishResults(ctx, entity.UserDeleted, results)
+	return results
+}
+
+// deleteChunk deletes the users of chunk in one statement and returns the
+// first error it set. Users that do not exist, or that an earlier item
+// already deleted, are not found.
+func (s *userService) deleteChunk(ctx context.Context, chunk []entity.UserBatchResult) error {
+	ids := make([]int64, 0, len(chunk))
+	for _, res := range chunk {
+		ids = append(ids, res.User.ID)
+	}
+	deleted, err := s.repo.DeleteMany(ctx, ids)
+	if err != nil {
+		for i := range chunk {
+			chunk[i].Err = err
+		}
+		return err
+	}
+
+	existed := make(map[int64]bool, len(deleted))
+	for _, id := range deleted {
+		existed[id] = true
+	}
+	var first error
+	for i, res := range chunk {
+		if existed[res.User.ID] {
+			existed[res.User.ID] = false
+			continue
+		}
+		chunk[i].Err = helper.NewError(helper.NotFound, fmt.Errorf("user %d does not exist", res.User.ID))
+		if first == nil {
+			first = chunk[i].Err
+		}
+	}
+	return first
+}
+
+// abortBatch settles the results of a transactional batch that ended
+// with err: the items that did not fail themselves fail with
+// ErrBatchAborted, or all with err when none did, e.g. when the commit
+// failed.
+func abortBatch(results []entity.UserBatchResult, err error) {
+	if err == nil {
+		return
+	}
+	failed := false
+	for _, res := range results {
+		failed = failed || res.Err != nil
+	}
+	for i := range results {
+		switch {
+		case !failed:
+			results[i].Err = err
+		case results[i].Err == nil:
+			results[i].Err = ErrBatchAborted
+		}
+	}
+}
+
+func (s *userService) publishResults(ctx context.Context, t entity.UserEventType, results []entity.UserBatchResult) {
+	for _, res := range results {
+		if res.Err == nil {
+			s.publish(ctx, t, res.User)
+		}
+	}
 }
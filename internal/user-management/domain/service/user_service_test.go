@@ -1,17 +1,26 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	entity "user-management/internal/user-management/domain/entities"
+	"user-management/internal/user-management/helper"
 	"user-management/mocks"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var ctx = context.Background()
+
 func mockIPServer(t *testing.T, responseBody string, statusCode int) *httptest.Server {
 	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(statusCode)
@@ -21,125 +30,421 @@ func mockIPServer(t *testing.T, responseBody string, statusCode int) *httptest.S
 
 func TestRegisterUser_Success(t *testing.T) {
 	mockRepo := new(mocks.IUserRepository)
-	mockRepo.On("Create", mock.Anything).Return(int64(1), nil)
+	mockRepo.On("Create", mock.Anything, mock.Anything).Return(int64(1), nil)
 
 	ipServer := mockIPServer(t, `{"city":"TestCity","country":"TC"}`, 200)
 	defer ipServer.Close()
 
 	mockClient := mocks.NewIPInfoClient(t)
-	mockClient.On("GetInfo", "1.1.1.1").Return(map[string]interface{}{"city": "Test"}, nil)
+	mockClient.On("GetInfo", mock.Anything, "1.1.1.1").Return(map[string]interface{}{"city": "Test"}, nil)
 
 	svc := &userService{repo: mockRepo, ipInfoClient: mockClient}
 
 	user := entity.User{Name: "Aren", Email: "aren@example.com"}
-	result, err := svc.RegisterUser(user, "1.1.1.1")
+	created, geo, err := svc.RegisterUser(ctx, user, "1.1.1.1")
 	assert.NoError(t, err)
-	assert.Equal(t, int64(1), result["usedId"])
+	assert.Equal(t, entity.User{ID: 1, Name: "Aren", Email: "aren@example.com"}, created)
+	assert.Equal(t, map[string]interface{}{"city": "Test"}, geo)
 }
 
 func TestRegisterUser_IPInfoFails(t *testing.T) {
 	mockRepo := new(mocks.IUserRepository)
-	mockRepo.On("Create", mock.Anything).Return(int64(2), nil)
+	mockRepo.On("Create", mock.Anything, mock.Anything).Return(int64(2), nil)
 
 	mockClient := mocks.NewIPInfoClient(t)
-	mockClient.On("GetInfo", "8.8.8.8").Return(nil, errors.New("ipinfo down"))
+	mockClient.On("GetInfo", mock.Anything, "8.8.8.8").Return(nil, errors.New("ipinfo down"))
 
 	svc := &userService{repo: mockRepo, ipInfoClient: mockClient}
 
 	user := entity.User{Name: "Test", Email: "t@x.com"}
-	result, err := svc.RegisterUser(user, "8.8.8.8")
+	created, geo, err := svc.RegisterUser(ctx, user, "8.8.8.8")
 
 	assert.NoError(t, err)
-	assert.Equal(t, int64(2), result["usedId"])
+	assert.Equal(t, int64(2), created.ID)
+	assert.Nil(t, geo)
 }
 
 func TestRegisterUser_RepoFails(t *testing.T) {
 	mockRepo := new(mocks.IUserRepository)
-	mockRepo.On("Create", mock.Anything).Return(int64(0), errors.New("db error"))
+	mockRepo.On("Create", mock.Anything, mock.Anything).Return(int64(0), errors.New("db error"))
 
 	mockClient := mocks.NewIPInfoClient(t)
-	mockClient.On("GetInfo", mock.Anything).Return(map[string]interface{}{"city": "Test"}, nil)
+	mockClient.On("GetInfo", mock.Anything, mock.Anything).Return(map[string]interface{}{"city": "Test"}, nil)
 	svc := &userService{repo: mockRepo, ipInfoClient: mockClient}
 
 	user := entity.User{Name: "Fail", Email: "f@x.com"}
-	_, err := svc.RegisterUser(user, "9.9.9.9")
+	_, _, err := svc.RegisterUser(ctx, user, "9.9.9.9")
 	assert.Error(t, err)
 }
 
 func TestListUsers_Success(t *testing.T) {
 	mockRepo := new(mocks.IUserRepository)
 	users := []entity.User{{ID: 1, Name: "Test"}}
-	mockRepo.On("GetAll").Return(users, nil)
+	filter := entity.UserFilter{Name: "te", Limit: 10}
+	mockRepo.On("GetAll", mock.Anything, filter).Return(users, nil)
 
 	svc := &userService{repo: mockRepo}
-	out, err := svc.ListUsers()
+	out, err := svc.ListUsers(ctx, filter)
 	assert.NoError(t, err)
 	assert.Equal(t, users, out)
 }
 
+func TestSearchUsers(t *testing.T) {
+	searcher := new(mocks.IUserSearcher)
+	query := entity.UserSearchQuery{Text: "jon", Limit: 20}
+	result := entity.UserSearchResult{Total: 1, Hits: []entity.UserSearchHit{{User: entity.User{ID: 1}, Score: 0.9}}}
+	searcher.On("Search", mock.Anything, query).Return(result, nil)
+
+	svc := NewUserService(new(mocks.IUserRepository), searcher, nil, UserServiceOptions{})
+	out, err := svc.SearchUsers(ctx, query)
+	assert.NoError(t, err)
+	assert.Equal(t, result, out)
+
+	_, err = NewUserService(new(mocks.IUserRepository), nil, nil, UserServiceOptions{}).SearchUsers(ctx, query)
+	assert.Error(t, err)
+}
+
+func TestStreamUsers(t *testing.T) {
+	mockRepo := new(mocks.IUserRepository)
+	filter := entity.UserFilter{Name: "te"}
+	mockRepo.On("Stream", mock.Anything, filter, mock.Anything).
+		Run(func(args mock.Arguments) {
+			fn := args.Get(2).(func(entity.User) error)
+			for _, u := range []entity.User{{ID: 1}, {ID: 2}, {ID: 3}} {
+				if fn(u) != nil {
+					return
+				}
+			}
+		}).
+		Return(errors.New("stopped"))
+
+	svc := &userService{repo: mockRepo}
+	var got []int64
+	err := svc.StreamUsers(ctx, filter, func(u entity.User) error {
+		got = append(got, u.ID)
+		if u.ID == 2 {
+			return errors.New("stopped")
+		}
+		return nil
+	})
+	assert.EqualError(t, err, "stopped")
+	assert.Equal(t, []int64{1, 2}, got)
+}
+
 func TestListUsers_Error(t *testing.T) {
 	mockRepo := new(mocks.IUserRepository)
-	mockRepo.On("GetAll").Return(nil, errors.New("db fail"))
+	mockRepo.On("GetAll", mock.Anything, entity.UserFilter{}).Return(nil, errors.New("db fail"))
 
 	svc := &userService{repo: mockRepo}
-	_, err := svc.ListUsers()
+	_, err := svc.ListUsers(ctx, entity.UserFilter{})
 	assert.Error(t, err)
 }
 
 func TestGetUserByID_Success(t *testing.T) {
 	mockRepo := new(mocks.IUserRepository)
 	u := entity.User{ID: 2, Name: "A"}
-	mockRepo.On("GetByID", int64(2)).Return(u, nil)
+	mockRepo.On("GetByID", mock.Anything, int64(2)).Return(u, nil)
 
 	svc := &userService{repo: mockRepo}
-	out, err := svc.GetUserByID(2)
+	out, err := svc.GetUserByID(ctx, 2)
 	assert.NoError(t, err)
 	assert.Equal(t, u, out)
 }
 
 func TestGetUserByID_Error(t *testing.T) {
 	mockRepo := new(mocks.IUserRepository)
-	mockRepo.On("GetByID", int64(9)).Return(entity.User{}, errors.New("not found"))
+	mockRepo.On("GetByID", mock.Anything, int64(9)).Return(entity.User{}, errors.New("not found"))
 
 	svc := &userService{repo: mockRepo}
-	_, err := svc.GetUserByID(9)
+	_, err := svc.GetUserByID(ctx, 9)
 	assert.Error(t, err)
 }
 
 func TestUpdateUser_Success(t *testing.T) {
-	mockRepo := new(mocks.IUserRepository)
+	mockRepo := mocks.NewIUserRepository(t)
+	inTx(mockRepo)
 	u := entity.User{ID: 3, Name: "U"}
-	mockRepo.On("Update", u).Return(nil)
+	mockRepo.On("Update", mock.Anything, u).Return(nil)
+	mockRepo.On("GetByID", mock.Anything, int64(3)).
+		Return(entity.User{ID: 3, Name: "U", Profile: &entity.UserProfile{Timezone: "UTC"}}, nil)
+
+	svc := &userService{repo: mockRepo}
+	stored, err := svc.UpdateUser(ctx, u)
+	assert.NoError(t, err)
+	assert.Equal(t, entity.User{ID: 3, Name: "U", Profile: &entity.UserProfile{Timezone: "UTC"}}, stored, "the stored profile is kept")
+}
+
+func TestUpdateUser_WithProfile(t *testing.T) {
+	mockRepo := mocks.NewIUserRepository(t)
+	inTx(mockRepo)
+	u := entity.User{ID: 3, Name: "U", Profile: &entity.UserProfile{Locale: "en-US"}}
+	mockRepo.On("Update", mock.Anything, u).Return(nil)
 
 	svc := &userService{repo: mockRepo}
-	err := svc.UpdateUser(u)
+	stored, err := svc.UpdateUser(ctx, u)
 	assert.NoError(t, err)
+	assert.Equal(t, u, stored)
 }
 
 func TestUpdateUser_Error(t *testing.T) {
-	mockRepo := new(mocks.IUserRepository)
+	mockRepo := mocks.NewIUserRepository(t)
+	inTx(mockRepo)
 	u := entity.User{ID: 3, Name: "Bad"}
-	mockRepo.On("Update", u).Return(errors.New("update fail"))
+	mockRepo.On("Update", mock.Anything, u).Return(errors.New("update fail"))
 
 	svc := &userService{repo: mockRepo}
-	err := svc.UpdateUser(u)
+	_, err := svc.UpdateUser(ctx, u)
 	assert.Error(t, err)
 }
 
 func TestDeleteUser_Success(t *testing.T) {
 	mockRepo := new(mocks.IUserRepository)
-	mockRepo.On("Delete", int64(4)).Return(nil)
+	mockRepo.On("Delete", mock.Anything, int64(4)).Return(nil)
 
 	svc := &userService{repo: mockRepo}
-	err := svc.DeleteUser(4)
+	err := svc.DeleteUser(ctx, 4)
 	assert.NoError(t, err)
 }
 
 func TestDeleteUser_Error(t *testing.T) {
 	mockRepo := new(mocks.IUserRepository)
-	mockRepo.On("Delete", int64(7)).Return(errors.New("delete fail"))
+	mockRepo.On("Delete", mock.Anything, int64(7)).Return(errors.New("delete fail"))
 
 	svc := &userService{repo: mockRepo}
-	err := svc.DeleteUser(7)
+	err := svc.DeleteUser(ctx, 7)
+	assert.Error(t, err)
+}
+
+func TestUserEvents_Published(t *testing.T) {
+	mockRepo := new(mocks.IUserRepository)
+	mockRepo.On("Create", mock.Anything, mock.Anything).Return(int64(5), nil)
+	inTx(mockRepo)
+	mockRepo.On("Update", mock.Anything, mock.Anything).Return(nil)
+	mockRepo.On("GetByID", mock.Anything, int64(5)).Return(entity.User{ID: 5, Name: "Aren"}, nil)
+	mockRepo.On("Delete", mock.Anything, int64(5)).Return(nil)
+
+	mockClient := mocks.NewIPInfoClient(t)
+	mockClient.On("GetInfo", mock.Anything, mock.Anything).Return(map[string]interface{}{}, nil)
+
+	publisher := mocks.NewIUserEventPublisher(t)
+	for _, typ := range entity.UserEventTypes {
+		typ := typ
+		publisher.On("Publish", mock.Anything, mock.MatchedBy(func(e entity.UserEvent) bool {
+			return e.Type == typ && e.User.ID == 5
+		})).Once()
+	}
+
+	svc := NewUserService(mockRepo, nil, mockClient, UserServiceOptions{}, publisher)
+
+	_, _, err := svc.RegisterUser(ctx, entity.User{Name: "Aren", Email: "aren@example.com"}, "1.1.1.1")
+	assert.NoError(t, err)
+	_, err = svc.UpdateUser(ctx, entity.User{ID: 5, Name: "Aren"})
+	assert.NoError(t, err)
+	assert.NoError(t, svc.DeleteUser(ctx, 5))
+}
+
+func TestUserEvents_NotPublishedOnFailure(t *testing.T) {
+	mockRepo := new(mocks.IUserRepository)
+	mockRepo.On("Delete", mock.Anything, int64(7)).Return(errors.New("delete fail"))
+
+	publisher := mocks.NewIUserEventPublisher(t)
+
+	svc := NewUserService(mockRepo, nil, nil, UserServiceOptions{}, publisher)
+	assert.Error(t, svc.DeleteUser(ctx, 7))
+}
+
+func TestRegisterUser_Traced(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	inSpan := mock.MatchedBy(func(ctx context.Context) bool {
+		return trace.SpanContextFromContext(ctx).IsValid()
+	})
+	mockRepo := new(mocks.IUserRepository)
+	mockRepo.On("Create", inSpan, mock.Anything).Return(int64(0), errors.New("db error"))
+	mockClient := mocks.NewIPInfoClient(t)
+	mockClient.On("GetInfo", inSpan, "1.1.1.1").Return(map[string]interface{}{}, nil)
+
+	svc := NewUserService(mockRepo, nil, mockClient, UserServiceOptions{})
+	_, _, err := svc.RegisterUser(ctx, entity.User{Name: "Aren"}, "1.1.1.1")
 	assert.Error(t, err)
+
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 1) {
+		assert.Equal(t, "userService.RegisterUser", spans[0].Name())
+		assert.Equal(t, codes.Error, spans[0].Status().Code)
+		assert.Equal(t, "db error", spans[0].Status().Description)
+	}
+	mockRepo.AssertExpectations(t)
+}
+
+// inTx makes mockRepo run transactions by calling their function.
+func inTx(mockRepo *mocks.IUserRepository) {
+	mockRepo.On("RunInTx", mock.Anything, mock.Anything).
+		Return(func(ctx context.Context, fn func(context.Context) error) error { return fn(ctx) })
+}
+
+func TestCreateUsers_Chunked(t *testing.T) {
+	users := []entity.User{
+		{Name: "Aren", Email: "aren@example.com"},
+		{Name: "Bob", Email: "bob@example.com"},
+		{Name: "Carl", Email: "carl@example.com"},
+	}
+	mockRepo := mocks.NewIUserRepository(t)
+	mockRepo.On("CreateMany", mock.Anything, users[:2]).Return([]int64{1, 2}, nil).Once()
+	mockRepo.On("CreateMany", mock.Anything, users[2:]).Return([]int64{3}, nil).Once()
+	publisher := mocks.NewIUserEventPublisher(t)
+	publisher.On("Publish", mock.Anything, mock.MatchedBy(func(e entity.UserEvent) bool { return e.Type == entity.UserCreated })).Times(3)
+
+	svc := NewUserService(mockRepo, nil, nil, UserServiceOptions{BatchSize: 2}, publisher)
+	results := svc.CreateUsers(ctx, users, false)
+
+	assert.Equal(t, []entity.UserBatchResult{
+		{User: entity.User{ID: 1, Name: "Aren", Email: "aren@example.com"}},
+		{User: entity.User{ID: 2, Name: "Bob", Email: "bob@example.com"}},
+		{User: entity.User{ID: 3, Name: "Carl", Email: "carl@example.com"}},
+	}, results)
+}
+
+func TestCreateUsers_FailedChunkRetriedPerUser(t *testing.T) {
+	users := []entity.User{
+		{Name: "Aren", Email: "aren@example.com"},
+		{Name: "Bob", Email: "bob@example.com"},
+	}
+	taken := helper.NewError(helper.AlreadyExists, errors.New("duplicate"))
+	mockRepo := mocks.NewIUserRepository(t)
+	mockRepo.On("CreateMany", mock.Anything, users).Return(nil, taken)
+	mockRepo.On("Create", mock.Anything, users[0]).Return(int64(0), taken)
+	mockRepo.On("Create", mock.Anything, users[1]).Return(int64(7), nil)
+
+	svc := NewUserService(mockRepo, nil, nil, UserServiceOptions{})
+	results := svc.CreateUsers(ctx, users, false)
+
+	assert.ErrorIs(t, results[0].Err, taken)
+	assert.NoError(t, results[1].Err)
+	assert.Equal(t, int64(7), results[1].User.ID)
+}
+
+func TestCreateUsers_TransactionalBlamesTakenEmail(t *testing.T) {
+	users := []entity.User{
+		{Name: "Aren", Email: "aren@example.com"},
+		{Name: "Bob", Email: "bob@example.com"},
+		{Name: "Carl", Email: "carl@example.com"},
+	}
+	mockRepo := mocks.NewIUserRepository(t)
+	inTx(mockRepo)
+	mockRepo.On("CreateMany", mock.Anything, users[:2]).Return([]int64{1, 2}, nil)
+	mockRepo.On("CreateMany", mock.Anything, users[2:]).Return(nil, helper.NewError(helper.AlreadyExists, errors.New("duplicate")))
+	mockRepo.On("GetAll", mock.Anything, entity.UserFilter{Email: "carl@example.com", Limit: 1}).Return([]entity.User{{ID: 9}}, nil)
+	publisher := mocks.NewIUserEventPublisher(t)
+
+	svc := NewUserService(mockRepo, nil, nil, UserServiceOptions{BatchSize: 2}, publisher)
+	results := svc.CreateUsers(ctx, users, true)
+
+	assert.ErrorIs(t, results[0].Err, ErrBatchAborted)
+	assert.Zero(t, results[0].User.ID)
+	assert.ErrorIs(t, results[1].Err, ErrBatchAborted)
+	assert.True(t, helper.HasStatus(results[2].Err, helper.AlreadyExists), "%v", results[2].Err)
+}
+
+func TestCreateUsers_TransactionalDuplicateInBatch(t *testing.T) {
+	mockRepo := mocks.NewIUserRepository(t)
+
+	svc := NewUserService(mockRepo, nil, nil, UserServiceOptions{})
+	results := svc.CreateUsers(ctx, []entity.User{
+		{Name: "Aren", Email: "aren@example.com"},
+		{Name: "Aren", Email: "aren@example.com"},
+	}, true)
+
+	assert.ErrorIs(t, results[0].Err, ErrBatchAborted)
+	assert.True(t, helper.HasStatus(results[1].Err, helper.AlreadyExists), "%v", results[1].Err)
+}
+
+func TestCreateUsers_TransactionalCommitFails(t *testing.T) {
+	mockRepo := mocks.NewIUserRepository(t)
+	mockRepo.On("RunInTx", mock.Anything, mock.Anything).Return(func(ctx context.Context, fn func(context.Context) error) error {
+		_ = fn(ctx)
+		return errors.New("commit failed")
+	})
+	mockRepo.On("CreateMany", mock.Anything, mock.Anything).Return([]int64{1}, nil)
+
+	svc := NewUserService(mockRepo, nil, nil, UserServiceOptions{})
+	results := svc.CreateUsers(ctx, []entity.User{{Name: "Aren", Email: "aren@example.com"}}, true)
+
+	assert.EqualError(t, results[0].Err, "commit failed")
+	assert.Zero(t, results[0].User.ID)
+}
+
+func TestUpdateUsers_KeepsStoredProfile(t *testing.T) {
+	users := []entity.User{
+		{ID: 1, Name: "Aren"},
+		{ID: 2, Name: "Bob", Profile: &entity.UserProfile{Locale: "en-US"}},
+	}
+	stored := entity.User{ID: 1, Name: "Aren", Profile: &entity.UserProfile{Timezone: "UTC"}}
+	mockRepo := mocks.NewIUserRepository(t)
+	mockRepo.On("Update", mock.Anything, users[0]).Return(nil)
+	mockRepo.On("GetByID", mock.Anything, int64(1)).Return(stored, nil)
+	mockRepo.On("Update", mock.Anything, users[1]).Return(nil)
+	publisher := mocks.NewIUserEventPublisher(t)
+	for _, u := range []entity.User{stored, users[1]} {
+		publisher.On("Publish", mock.Anything, mock.MatchedBy(func(e entity.UserEvent) bool {
+			return e.Type == entity.UserUpdated && e.User.ID == u.ID && assert.ObjectsAreEqual(u.Profile, e.User.Profile)
+		})).Once()
+	}
+
+	svc := NewUserService(mockRepo, nil, nil, UserServiceOptions{}, publisher)
+	results := svc.UpdateUsers(ctx, users, false)
+
+	assert.Equal(t, []entity.UserBatchResult{{User: stored}, {User: users[1]}}, results)
+}
+
+func TestUpdateUsers_Transactional(t *testing.T) {
+	users := []entity.User{{ID: 1, Name: "Aren"}, {ID: 2, Name: "Bob"}, {ID: 3, Name: "Carl"}}
+	mockRepo := mocks.NewIUserRepository(t)
+	inTx(mockRepo)
+	mockRepo.On("Update", mock.Anything, users[0]).Return(nil)
+	mockRepo.On("GetByID", mock.Anything, int64(1)).Return(users[0], nil)
+	mockRepo.On("Update", mock.Anything, users[1]).Return(helper.NewError(helper.NotFound, errors.New("no rows")))
+	publisher := mocks.NewIUserEventPublisher(t)
+
+	svc := NewUserService(mockRepo, nil, nil, UserServiceOptions{}, publisher)
+	results := svc.UpdateUsers(ctx, users, true)
+
+	assert.ErrorIs(t, results[0].Err, ErrBatchAborted)
+	assert.True(t, helper.HasStatus(results[1].Err, helper.NotFound), "%v", results[1].Err)
+	assert.ErrorIs(t, results[2].Err, ErrBatchAborted)
+}
+
+func TestDeleteUsers(t *testing.T) {
+	mockRepo := mocks.NewIUserRepository(t)
+	mockRepo.On("DeleteMany", mock.Anything, []int64{1, 404, 1}).Return([]int64{1}, nil)
+	publisher := mocks.NewIUserEventPublisher(t)
+	publisher.On("Publish", mock.Anything, mock.MatchedBy(func(e entity.UserEvent) bool {
+		return e.Type == entity.UserDeleted && e.User.ID == 1
+	})).Once()
+
+	svc := NewUserService(mockRepo, nil, nil, UserServiceOptions{}, publisher)
+	results := svc.DeleteUsers(ctx, []int64{1, 404, 1}, false)
+
+	assert.NoError(t, results[0].Err)
+	assert.True(t, helper.HasStatus(results[1].Err, helper.NotFound), "%v", results[1].Err)
+	// Deleting the same user twice finds it only once.
+	assert.True(t, helper.HasStatus(results[2].Err, helper.NotFound), "%v", results[2].Err)
+}
+
+func TestDeleteUsers_Transactional(t *testing.T) {
+	mockRepo := mocks.NewIUserRepository(t)
+	inTx(mockRepo)
+	mockRepo.On("DeleteMany", mock.Anything, []int64{1, 2}).Return([]int64{1, 2}, nil)
+	mockRepo.On("DeleteMany", mock.Anything, []int64{404}).Return(nil, nil)
+	publisher := mocks.NewIUserEventPublisher(t)
+
+	svc := NewUserService(mockRepo, nil, nil, UserServiceOptions{BatchSize: 2}, publisher)
+	results := svc.DeleteUsers(ctx, []int64{1, 2, 404}, true)
+
+	assert.ErrorIs(t, results[0].Err, ErrBatchAborted)
+	assert.ErrorIs(t, results[1].Err, ErrBatchAborted)
+	assert.True(t, helper.HasStatus(results[2].Err, helper.NotFound), "%v", results[2].Err)
 }
@@ -0,0 +1,329 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"user-management/internal/user-management/domain"
+	entity "user-management/internal/user-management/domain/entities"
+	"user-management/internal/user-management/helper"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	WebhookSignatureHeader = "X-Webhook-Signature"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+)
+
+// SignWebhook returns the value of the signature header for a delivery body
+// sent at timestamp (unix seconds). Receivers recompute it over
+// "<timestamp>.<body>" with their shared secret.
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+type WebhookOptions struct {
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	PollInterval time.Duration
+	BatchSize    int
+	Client       *http.Client
+}
+
+type IWebhookService interface {
+	domain.IUserEventPublisher
+
+	CreateSubscription(ctx context.Context, sub entity.WebhookSubscription) (entity.WebhookSubscription, error)
+	ListSubscriptions(ctx context.Context) ([]entity.WebhookSubscription, error)
+	GetSubscriptionByID(ctx context.Context, id int64) (entity.WebhookSubscription, error)
+	UpdateSubscription(ctx context.Context, sub entity.WebhookSubscription) error
+	DeleteSubscription(ctx context.Context, id int64) error
+
+	ListDeliveries(ctx context.Context, status entity.WebhookDeliveryStatus) ([]entity.WebhookDelivery, error)
+	GetDeliveryByID(ctx context.Context, id int64) (entity.WebhookDelivery, error)
+	ReplayDelivery(ctx context.Context, id int64) (entity.WebhookDelivery, error)
+
+	// ProcessDue sends every pending delivery whose next attempt is due.
+	ProcessDue(ctx context.Context) error
+	// Run calls ProcessDue on every poll interval, and right after new
+	// deliveries are queued, until ctx is done.
+	Run(ctx context.Context)
+}
+
+type webhookService struct {
+	repo   domain.IWebhookRepository
+	opts   WebhookOptions
+	now    func() time.Time
+	notify chan struct{}
+}
+
+func NewWebhookService(repo domain.IWebhookRepository, opts WebhookOptions) IWebhookService {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 8
+	}
+	if opts.BaseBackoff <= 0 {
+		opts.BaseBackoff = 30 * time.Second
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = 6 * time.Hour
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 5 * time.Second
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &webhookService{
+		repo:   repo,
+		opts:   opts,
+		now:    time.Now,
+		notify: make(chan struct{}, 1),
+	}
+}
+
+func (s *webhookService) CreateSubscription(ctx context.Context, sub entity.WebhookSubscription) (entity.WebhookSubscription, error) {
+	sub.CreatedAt = s.now().UTC()
+	id, err := s.repo.CreateSubscription(ctx, sub)
+	if err != nil {
+		return entity.WebhookSubscription{}, err
+	}
+	sub.ID = id
+	return sub, nil
+}
+
+func (s *webhookService) ListSubscriptions(ctx context.Context) ([]entity.WebhookSubscription, error) {
+	return s.repo.GetSubscriptions(ctx)
+}
+
+func (s *webhookService) GetSubscriptionByID(ctx context.Context, id int64) (entity.WebhookSubscription, error) {
+	return s.repo.GetSubscriptionByID(ctx, id)
+}
+
+func (s *webhookService) UpdateSubscription(ctx context.Context, sub entity.WebhookSubscription) error {
+	if _, err := s.repo.GetSubscriptionByID(ctx, sub.ID); err != nil {
+		return err
+	}
+	return s.repo.UpdateSubscription(ctx, sub)
+}
+
+func (s *webhookService) DeleteSubscription(ctx context.Context, id int64) error {
+	return s.repo.DeleteSubscription(ctx, id)
+}
+
+func (s *webhookService) ListDeliveries(ctx context.Context, status entity.WebhookDeliveryStatus) ([]entity.WebhookDelivery, error) {
+	return s.repo.GetDeliveries(ctx, status)
+}
+
+func (s *webhookService) GetDeliveryByID(ctx context.Context, id int64) (entity.WebhookDelivery, error) {
+	return s.repo.GetDeliveryByID(ctx, id)
+}
+
+// ReplayDelivery puts a delivery back into the queue with a fresh attempt
+// budget, whatever state it ended up in.
+func (s *webhookService) ReplayDelivery(ctx context.Context, id int64) (entity.WebhookDelivery, error) {
+	d, err := s.repo.GetDeliveryByID(ctx, id)
+	if err != nil {
+		return entity.WebhookDelivery{}, err
+	}
+	d.Status = entity.DeliveryPending
+	d.Attempts = 0
+	d.LastError = ""
+	d.LastStatusCode = 0
+	d.DeliveredAt = nil
+	d.NextAttemptAt = s.now().UTC()
+	if err := s.repo.UpdateDelivery(ctx, d); err != nil {
+		return entity.WebhookDelivery{}, err
+	}
+	s.wake()
+	return d, nil
+}
+
+// Publish queues a delivery for every active subscription interested in
+// the event. Failures are logged: a partner outage must never fail the
+// user operation that produced the event. The deliveries are queued even
+// when ctx is canceled, as the change they report is stored.
+func (s *webhookService) Publish(ctx context.Context, event entity.UserEvent) {
+	ctx = context.WithoutCancel(ctx)
+	subs, err := s.repo.GetActiveSubscriptions(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("webhooks: loading subscriptions")
+		return
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Error().Err(err).Msg("webhooks: encoding event")
+		return
+	}
+
+	now := s.now().UTC()
+	queued := 0
+	for _, sub := range subs {
+		if !sub.Subscribed(event.Type) {
+			continue
+		}
+		_, err := s.repo.CreateDelivery(ctx, entity.WebhookDelivery{
+			SubscriptionID: sub.ID,
+			EventType:      event.Type,
+			Payload:        payload,
+			Status:         entity.DeliveryPending,
+			NextAttemptAt:  now,
+			CreatedAt:      now,
+		})
+		if err != nil {
+			log.Error().Err(err).Int64("subscription", sub.ID).Msg("webhooks: queueing delivery")
+			continue
+		}
+		queued++
+	}
+
+	if queued > 0 {
+		s.wake()
+	}
+}
+
+func (s *webhookService) wake() {
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (s *webhookService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.ProcessDue(ctx); err != nil {
+			log.Error().Err(err).Msg("webhooks: processing deliveries")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.notify:
+		}
+	}
+}
+
+func (s *webhookService) ProcessDue(ctx context.Context) error {
+	due, err := s.repo.GetDueDeliveries(ctx, s.now().UTC(), s.opts.BatchSize)
+	if err != nil {
+		return err
+	}
+	for _, d := range due {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := s.deliver(ctx, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *webhookService) deliver(ctx context.Context, d entity.WebhookDelivery) error {
+	sub, err := s.repo.GetSubscriptionByID(ctx, d.SubscriptionID)
+	switch {
+	case helper.HasStatus(err, helper.NotFound):
+		d.Status = entity.DeliveryDead
+		d.LastError = "subscription no longer exists"
+		return s.repo.UpdateDelivery(ctx, d)
+	case err != nil:
+		return err
+	case !sub.Active:
+		// Replaying the delivery sends it once the subscription is active
+		// again.
+		d.Status = entity.DeliveryDead
+		d.LastError = "subscription is inactive"
+		return s.repo.UpdateDelivery(ctx, d)
+	}
+
+	code, sendErr := s.send(ctx, sub, d)
+	now := s.now().UTC()
+
+	d.Attempts++
+	d.LastStatusCode = code
+	switch {
+	case sendErr == nil:
+		d.Status = entity.DeliverySucceeded
+		d.LastError = ""
+		d.DeliveredAt = &now
+	case d.Attempts >= s.opts.MaxAttempts:
+		d.Status = entity.DeliveryDead
+		d.LastError = sendErr.Error()
+	default:
+		d.LastError = sendErr.Error()
+		d.NextAttemptAt = now.Add(s.backoff(d.Attempts))
+	}
+
+	if sendErr != nil {
+		log.Warn().
+			Int64("delivery", d.ID).
+			Int("attempt", d.Attempts).
+			Str("status", string(d.Status)).
+			Msgf("webhooks: delivery failed: %v", sendErr)
+	}
+
+	return s.repo.UpdateDelivery(ctx, d)
+}
+
+// backoff returns the delay before the next attempt once attempts have
+// failed: BaseBackoff, doubled per attempt and capped at MaxBackoff.
+func (s *webhookService) backoff(attempts int) time.Duration {
+	d := s.opts.BaseBackoff
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= s.opts.MaxBackoff {
+			return s.opts.MaxBackoff
+		}
+	}
+	return d
+}
+
+func (s *webhookService) send(ctx context.Context, sub entity.WebhookSubscription, d entity.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	ts := s.now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, string(d.EventType))
+	req.Header.Set(WebhookDeliveryHeader, strconv.FormatInt(d.ID, 10))
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(ts, 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(sub.Secret, ts, d.Payload))
+
+	resp, err := s.opts.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver responded with %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
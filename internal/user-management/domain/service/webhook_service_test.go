@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	entity "user-management/internal/user-management/domain/entities"
+	"user-management/internal/user-management/helper"
+	"user-management/mocks"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var webhookNow = time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestWebhookService(repo *mocks.IWebhookRepository) *webhookService {
+	svc := NewWebhookService(repo, WebhookOptions{
+		MaxAttempts: 3,
+		BaseBackoff: time.Minute,
+		MaxBackoff:  time.Hour,
+	}).(*webhookService)
+	svc.now = func() time.Time { return webhookNow }
+	return svc
+}
+
+func dueDelivery(attempts int) entity.WebhookDelivery {
+	return entity.WebhookDelivery{
+		ID:             10,
+		SubscriptionID: 1,
+		EventType:      entity.UserCreated,
+		Payload:        []byte(`{"type":"user.created","user":{"id":1}}`),
+		Status:         entity.DeliveryPending,
+		Attempts:       attempts,
+		NextAttemptAt:  webhookNow,
+	}
+}
+
+func TestProcessDue_DeliversSignedPayload(t *testing.T) {
+	const secret = "0123456789abcdef"
+
+	var gotBody []byte
+	var gotHeader http.Header
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotBody, _ = io.ReadAll(r.Body)
+		gotHeader = r.Header.Clone()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	repo := mocks.NewIWebhookRepository(t)
+	repo.On("GetDueDeliveries", mock.Anything, webhookNow, 100).Return([]entity.WebhookDelivery{dueDelivery(0)}, nil)
+	repo.On("GetSubscriptionByID", mock.Anything, int64(1)).
+		Return(entity.WebhookSubscription{ID: 1, URL: receiver.URL, Secret: secret, Active: true}, nil)
+	repo.On("UpdateDelivery", mock.Anything, mock.MatchedBy(func(d entity.WebhookDelivery) bool {
+		return d.Status == entity.DeliverySucceeded && d.Attempts == 1 &&
+			d.LastStatusCode == http.StatusNoContent && d.DeliveredAt != nil
+	})).Return(nil)
+
+	svc := newTestWebhookService(repo)
+	assert.NoError(t, svc.ProcessDue(context.Background()))
+
+	ts, err := strconv.ParseInt(gotHeader.Get(WebhookTimestampHeader), 10, 64)
+	assert.NoError(t, err)
+	assert.Equal(t, webhookNow.Unix(), ts)
+	assert.Equal(t, SignWebhook(secret, ts, gotBody), gotHeader.Get(WebhookSignatureHeader))
+	assert.Equal(t, "user.created", gotHeader.Get(WebhookEventHeader))
+	assert.Equal(t, "10", gotHeader.Get(WebhookDeliveryHeader))
+	assert.JSONEq(t, `{"type":"user.created","user":{"id":1}}`, string(gotBody))
+}
+
+func TestProcessDue_FailureSchedulesBackoff(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+
+	repo := mocks.NewIWebhookRepository(t)
+	repo.On("GetDueDeliveries", mock.Anything, webhookNow, 100).Return([]entity.WebhookDelivery{dueDelivery(1)}, nil)
+	repo.On("GetSubscriptionByID", mock.Anything, int64(1)).
+		Return(entity.WebhookSubscription{ID: 1, URL: receiver.URL, Secret: "s", Active: true}, nil)
+	repo.On("UpdateDelivery", mock.Anything, mock.MatchedBy(func(d entity.WebhookDelivery) bool {
+		return d.Status == entity.DeliveryPending && d.Attempts == 2 &&
+			d.LastStatusCode == http.StatusServiceUnavailable &&
+			d.NextAttemptAt.Equal(webhookNow.Add(2*time.Minute))
+	})).Return(nil)
+
+	svc := newTestWebhookService(repo)
+	assert.NoError(t, svc.ProcessDue(context.Background()))
+}
+
+func TestProcessDue_DeadLettersAfterMaxAttempts(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	repo := mocks.NewIWebhookRepository(t)
+	repo.On("GetDueDeliveries", mock.Anything, webhookNow, 100).Return([]entity.WebhookDelivery{dueDelivery(2)}, nil)
+	repo.On("GetSubscriptionByID", mock.Anything, int64(1)).
+		Return(entity.WebhookSubscription{ID: 1, URL: receiver.URL, Secret: "s", Active: true}, nil)
+	repo.On("UpdateDelivery", mock.Anything, mock.MatchedBy(func(d entity.WebhookDelivery) bool {
+		return d.Status == entity.DeliveryDead && d.Attempts == 3 && d.LastError != ""
+	})).Return(nil)
+
+	svc := newTestWebhookService(repo)
+	assert.NoError(t, svc.ProcessDue(context.Background()))
+}
+
+func TestProcessDue_SubscriptionGone(t *testing.T) {
+	repo := mocks.NewIWebhookRepository(t)
+	repo.On("GetDueDeliveries", mock.Anything, webhookNow, 100).Return([]entity.WebhookDelivery{dueDelivery(0)}, nil)
+	repo.On("GetSubscriptionByID", mock.Anything, int64(1)).
+		Return(entity.WebhookSubscription{}, helper.NewError(helper.NotFound, errors.New("no rows")))
+	repo.On("UpdateDelivery", mock.Anything, mock.MatchedBy(func(d entity.WebhookDelivery) bool {
+		return d.Status == entity.DeliveryDead && d.Attempts == 0
+	})).Return(nil)
+
+	svc := newTestWebhookService(repo)
+	assert.NoError(t, svc.ProcessDue(context.Background()))
+}
+
+func TestProcessDue_SubscriptionInactive(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("delivery sent to an inactive subscription")
+	}))
+	defer receiver.Close()
+
+	repo := mocks.NewIWebhookRepository(t)
+	repo.On("GetDueDeliveries", mock.Anything, webhookNow, 100).Return([]entity.WebhookDelivery{dueDelivery(1)}, nil)
+	repo.On("GetSubscriptionByID", mock.Anything, int64(1)).
+		Return(entity.WebhookSubscription{ID: 1, URL: receiver.URL, Secret: "s", Active: false}, nil)
+	repo.On("UpdateDelivery", mock.Anything, mock.MatchedBy(func(d entity.WebhookDelivery) bool {
+		return d.Status == entity.DeliveryDead && d.Attempts == 1 && d.LastError == "subscription is inactive"
+	})).Return(nil)
+
+	svc := newTestWebhookService(repo)
+	assert.NoError(t, svc.ProcessDue(context.Background()))
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	svc := newTestWebhookService(mocks.NewIWebhookRepository(t))
+
+	assert.Equal(t, time.Minute, svc.backoff(1))
+	assert.Equal(t, 2*time.Minute, svc.backoff(2))
+	assert.Equal(t, 32*time.Minute, svc.backoff(6))
+	assert.Equal(t, time.Hour, svc.backoff(7))
+	assert.Equal(t, time.Hour, svc.backoff(30))
+}
+
+func TestPublish_QueuesMatchingSubscriptions(t *testing.T) {
+	repo := mocks.NewIWebhookRepository(t)
+	repo.On("GetActiveSubscriptions", mock.Anything).Return([]entity.WebhookSubscription{
+		{ID: 1, Events: []string{"user.created", "user.deleted"}},
+		{ID: 2, Events: []string{"user.updated"}},
+	}, nil)
+	repo.On("CreateDelivery", mock.Anything, mock.MatchedBy(func(d entity.WebhookDelivery) bool {
+		return d.SubscriptionID == 1 && d.EventType == entity.UserCreated &&
+			d.Status == entity.DeliveryPending && d.NextAttemptAt.Equal(webhookNow) &&
+			strings.Contains(string(d.Payload), `"occurred_at":`)
+	})).Return(int64(1), nil).Once()
+
+	svc := newTestWebhookService(repo)
+	svc.Publish(context.Background(), entity.UserEvent{Type: entity.UserCreated, User: entity.User{ID: 5}})
+
+	select {
+	case <-svc.notify:
+	default:
+		t.Fatal("expected the delivery worker to be woken up")
+	}
+}
+
+func TestReplayDelivery_ResetsDeadDelivery(t *testing.T) {
+	dead := dueDelivery(3)
+	dead.Status = entity.DeliveryDead
+	dead.LastError = "receiver responded with 500"
+
+	repo := mocks.NewIWebhookRepository(t)
+	repo.On("GetDeliveryByID", mock.Anything, int64(10)).Return(dead, nil)
+	repo.On("UpdateDelivery", mock.Anything, mock.MatchedBy(func(d entity.WebhookDelivery) bool {
+		return d.Status == entity.DeliveryPending && d.Attempts == 0 && d.LastError == ""
+	})).Return(nil)
+
+	svc := newTestWebhookService(repo)
+	out, err := svc.ReplayDelivery(context.Background(), 10)
+	assert.NoError(t, err)
+	assert.Equal(t, entity.DeliveryPending, out.Status)
+}
@@ -0,0 +1,185 @@
+// Package userfile moves users between the service and files, as the
+// users export and users import commands do.
+package userfile
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	entity "user-management/internal/user-management/domain/entities"
+	"user-management/internal/user-management/domain/service"
+)
+
+// Formats are the file formats users are exported to and imported from:
+// csv with a header row, ndjson with an object per line, and json with
+// one array of objects.
+var Formats = []string{"csv", "ndjson", "json"}
+
+// Export writes all users to w in ID order, in one of Formats, and
+// returns how many it wrote. It lists batchSize users at a time (100 if
+// not positive), so many users take no more memory than a few. Unlike
+// GET /users as CSV, values are written as they are, for Import to read
+// back.
+func Export(ctx context.Context, svc service.IUserService, w io.Writer, format string, batchSize int) (int, error) {
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	bw := bufio.NewWriter(w)
+	enc, err := NewEncoder(bw, format)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	filter := entity.UserFilter{Limit: batchSize}
+	for {
+		users, err := svc.ListUsers(ctx, filter)
+		if err != nil {
+			return n, err
+		}
+		for _, u := range users {
+			if err := enc.Encode(u); err != nil {
+				return n, err
+			}
+			n++
+		}
+		if len(users) < batchSize {
+			break
+		}
+		filter.AfterID = users[len(users)-1].ID
+	}
+	if err := enc.Close(); err != nil {
+		return n, err
+	}
+	return n, bw.Flush()
+}
+
+// Encoder writes users one by one in one of Formats.
+type Encoder interface {
+	Encode(u entity.User) error
+	// Close ends the file. Closing the ndjson and csv encoders only
+	// flushes them, so they can keep going after.
+	Close() error
+}
+
+// NewEncoder returns an Encoder of format writing to w.
+func NewEncoder(w io.Writer, format string) (Encoder, error) {
+	switch format {
+	case "csv":
+		return NewCSVEncoder(w, nil)
+	case "ndjson":
+		return &ndjsonEncoder{enc: json.NewEncoder(w)}, nil
+	case "json":
+		return &jsonEncoder{w: w}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q (want %s)", format, strings.Join(Formats, ", "))
+	}
+}
+
+// NewCSVEncoder returns the csv Encoder, which writes the header row
+// right away. A non-nil cell rewrites every value, e.g. to keep
+// spreadsheets from running it.
+func NewCSVEncoder(w io.Writer, cell func(string) string) (Encoder, error) {
+	e := &csvEncoder{w: csv.NewWriter(w), cell: cell}
+	return e, e.w.Write(CSVHeader())
+}
+
+// CSVHeader is the header row of the csv format: the fields of a user,
+// then those of its profile.
+func CSVHeader() []string {
+	return append([]string{"id"}, importFields...)
+}
+
+// CSVRecord is the row of u in the csv format. The profile columns of a
+// user without a profile are empty.
+func CSVRecord(u entity.User) []string {
+	var p entity.UserProfile
+	if u.Profile != nil {
+		p = *u.Profile
+	}
+	return []string{strconv.FormatInt(u.ID, 10), u.Name, u.Email,
+		p.DisplayName, p.Phone, p.Locale, p.Timezone, p.AvatarURL, p.Birthdate}
+}
+
+type csvEncoder struct {
+	w    *csv.Writer
+	cell func(string) string
+}
+
+func (e *csvEncoder) Encode(u entity.User) error {
+	row := CSVRecord(u)
+	if e.cell != nil {
+		for i, v := range row {
+			row[i] = e.cell(v)
+		}
+	}
+	return e.w.Write(row)
+}
+
+func (e *csvEncoder) Close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// record is a user in the ndjson and json formats.
+type record struct {
+	ID      int64               `json:"id"`
+	Name    string              `json:"name"`
+	Email   string              `json:"email"`
+	Profile *entity.UserProfile `json:"profile,omitempty"`
+}
+
+func toRecord(u entity.User) record {
+	rec := record{ID: u.ID, Name: u.Name, Email: u.Email}
+	if u.Profile != nil && *u.Profile != (entity.UserProfile{}) {
+		rec.Profile = u.Profile
+	}
+	return rec
+}
+
+type ndjsonEncoder struct {
+	enc *json.Encoder
+}
+
+func (e *ndjsonEncoder) Encode(u entity.User) error {
+	return e.enc.Encode(toRecord(u))
+}
+
+func (e *ndjsonEncoder) Close() error {
+	return nil
+}
+
+// jsonEncoder writes one array, a user per line.
+type jsonEncoder struct {
+	w io.Writer
+	n int
+}
+
+func (e *jsonEncoder) Encode(u entity.User) error {
+	b, err := json.Marshal(toRecord(u))
+	if err != nil {
+		return err
+	}
+	sep := ",\n"
+	if e.n == 0 {
+		sep = "[\n"
+	}
+	e.n++
+	_, err = io.WriteString(e.w, sep+string(b))
+	return err
+}
+
+func (e *jsonEncoder) Close() error {
+	end := "\n]\n"
+	if e.n == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(e.w, end)
+	return err
+}
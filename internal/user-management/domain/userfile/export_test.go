@@ -0,0 +1,76 @@
+package userfile
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	entity "user-management/internal/user-management/domain/entities"
+	"user-management/internal/user-management/domain/service"
+	"user-management/internal/user-management/infrastructure/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestUserService returns a user service on an in-memory repository
+// with the given users.
+func newTestUserService(t *testing.T, users ...entity.User) service.IUserService {
+	t.Helper()
+	repo := repository.NewInMemoryUserRepository()
+	for _, u := range users {
+		_, err := repo.Create(context.Background(), u)
+		require.NoError(t, err)
+	}
+	return service.NewUserService(repo, repository.NewFuzzyUserSearcher(repo), nil, service.UserServiceOptions{BatchSize: 2})
+}
+
+func TestExport(t *testing.T) {
+	var users []entity.User
+	for i := 1; i <= 5; i++ {
+		users = append(users, entity.User{Name: fmt.Sprintf("User %d", i), Email: fmt.Sprintf("u%d@example.com", i)})
+	}
+	users[0].Name = "=Aren, the first"
+	users[1].Profile = &entity.UserProfile{Phone: "+14155550123", Locale: "en-US"}
+	svc := newTestUserService(t, users...)
+
+	for format, want := range map[string]string{
+		"csv": "id,name,email,display_name,phone,locale,timezone,avatar_url,birthdate\n" +
+			"1,\"=Aren, the first\",u1@example.com,,,,,,\n" +
+			"2,User 2,u2@example.com,,+14155550123,en-US,,,\n" +
+			"3,User 3,u3@example.com,,,,,,\n" +
+			"4,User 4,u4@example.com,,,,,,\n" +
+			"5,User 5,u5@example.com,,,,,,\n",
+		"ndjson": `{"id":1,"name":"=Aren, the first","email":"u1@example.com"}` + "\n" +
+			`{"id":2,"name":"User 2","email":"u2@example.com","profile":{"phone":"+14155550123","locale":"en-US"}}` + "\n" +
+			`{"id":3,"name":"User 3","email":"u3@example.com"}` + "\n" +
+			`{"id":4,"name":"User 4","email":"u4@example.com"}` + "\n" +
+			`{"id":5,"name":"User 5","email":"u5@example.com"}` + "\n",
+		"json": "[\n" +
+			`{"id":1,"name":"=Aren, the first","email":"u1@example.com"},` + "\n" +
+			`{"id":2,"name":"User 2","email":"u2@example.com","profile":{"phone":"+14155550123","locale":"en-US"}},` + "\n" +
+			`{"id":3,"name":"User 3","email":"u3@example.com"},` + "\n" +
+			`{"id":4,"name":"User 4","email":"u4@example.com"},` + "\n" +
+			`{"id":5,"name":"User 5","email":"u5@example.com"}` + "\n]\n",
+	} {
+		t.Run(format, func(t *testing.T) {
+			var out strings.Builder
+			n, err := Export(context.Background(), svc, &out, format, 2)
+			require.NoError(t, err)
+			assert.Equal(t, 5, n)
+			assert.Equal(t, want, out.String())
+		})
+	}
+}
+
+func TestExportEmpty(t *testing.T) {
+	var out strings.Builder
+	n, err := Export(context.Background(), newTestUserService(t), &out, "json", 2)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	assert.Equal(t, "[]\n", out.String())
+
+	_, err = Export(context.Background(), newTestUserService(t), &out, "xml", 2)
+	assert.EqualError(t, err, `unknown format "xml" (want csv, ndjson, json)`)
+}
@@ -0,0 +1,497 @@
+package userfile
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	entity "user-management/internal/user-management/domain/entities"
+	"user-management/internal/user-management/domain/service"
+	"user-management/internal/user-management/helper"
+)
+
+// ImportOptions tunes Import.
+type ImportOptions struct {
+	// Format is one of Formats.
+	Format string
+	// Columns maps fields (name, email and those of the profile, see
+	// CSVHeader) to the CSV column or JSON key holding them, where that is
+	// not the field name.
+	Columns map[string]string
+	// Check, if set, checks the user read from a row, returning it as it
+	// is to be stored or the problems with its fields, named by their JSON
+	// path (e.g. profile.phone).
+	Check func(u entity.User) (entity.User, []helper.FieldError)
+	// Upsert updates the name, and the profile if the row has one, of
+	// users whose email is taken instead of failing the row.
+	Upsert bool
+	// DryRun checks every row and counts what importing it would do
+	// without writing anything.
+	DryRun bool
+	// BatchSize is the number of rows written at a time, 100 if not
+	// positive.
+	BatchSize int
+	// Skip is the number of rows a previous run got through, see
+	// LoadCheckpoint.
+	Skip int
+	// Checkpoint, if set, is called with the number of rows done after
+	// each batch is written.
+	Checkpoint func(rows int) error
+	// Report gets a CSV line (row, column, message) for every problem with
+	// a row, after a header line before the first. Rows are numbered from
+	// 1, not counting the CSV header.
+	Report io.Writer
+}
+
+// ImportStats counts the rows Import read and what became of them, or
+// would have in a dry run.
+type ImportStats struct {
+	Rows      int
+	Skipped   int
+	Created   int
+	Updated   int
+	Unchanged int
+	Failed    int
+}
+
+// importFields are the fields of a user an import sets: name and email,
+// which every row needs, then those of the profile, which may be left out.
+// A row without any profile field leaves the stored profile as it is.
+var importFields = []string{"name", "email", "display_name", "phone", "locale", "timezone", "avatar_url", "birthdate"}
+
+// Import creates the users in r, one of Formats, BatchSize rows at a
+// time. Rows that fail opts.Check, or whose email is taken or was in an
+// earlier row, are reported to opts.Report and left out. The error is for
+// problems that stop the import, like an unreadable file or an unreachable
+// database.
+func Import(ctx context.Context, svc service.IUserService, r io.Reader, opts ImportOptions) (ImportStats, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	columns := make(map[string]string, len(importFields))
+	for _, field := range importFields {
+		columns[field] = field
+	}
+	for field, column := range opts.Columns {
+		if _, ok := columns[field]; !ok {
+			return ImportStats{}, fmt.Errorf("cannot map column %q to unknown field %q (want %s)", column, field, strings.Join(importFields, ", "))
+		}
+		columns[field] = column
+	}
+	rows, err := newRowReader(r, opts.Format, columns)
+	if err != nil {
+		return ImportStats{}, err
+	}
+
+	report := io.Discard
+	if opts.Report != nil {
+		report = opts.Report
+	}
+	imp := &userImporter{
+		svc:     svc,
+		opts:    opts,
+		columns: columns,
+		report:  csv.NewWriter(report),
+		seen:    make(map[string]int),
+	}
+	err = imp.run(ctx, rows)
+	imp.report.Flush()
+	if err == nil {
+		err = imp.report.Error()
+	}
+	return imp.stats, err
+}
+
+type userImporter struct {
+	svc     service.IUserService
+	opts    ImportOptions
+	columns map[string]string
+	report  *csv.Writer
+	// reported is set once the report has its header.
+	reported bool
+	stats    ImportStats
+	// seen has the row of every email read so far.
+	seen    map[string]int
+	pending []importRow
+}
+
+// importRow is a valid row waiting to be written.
+type importRow struct {
+	n    int
+	user entity.User
+}
+
+func (imp *userImporter) run(ctx context.Context, rows rowReader) error {
+	for {
+		values, err := rows.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var bad *badRowError
+		if err != nil && !errors.As(err, &bad) {
+			return fmt.Errorf("row %d: %w", imp.stats.Rows+1, err)
+		}
+
+		imp.stats.Rows++
+		n := imp.stats.Rows
+		if n <= imp.opts.Skip {
+			imp.stats.Skipped++
+			continue
+		}
+		if bad != nil {
+			imp.fail(n, bad.column, bad.Error())
+			continue
+		}
+		if !imp.check(n, values) {
+			continue
+		}
+		if len(imp.pending) == imp.opts.BatchSize {
+			if err := imp.flush(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return imp.flush(ctx)
+}
+
+// check validates a row with opts.Check and queues it when it is valid.
+func (imp *userImporter) check(n int, values map[string]string) bool {
+	u := imp.user(values)
+	if imp.opts.Check != nil {
+		var problems []helper.FieldError
+		if u, problems = imp.opts.Check(u); len(problems) > 0 {
+			for _, fe := range problems {
+				imp.reportLine(n, imp.columns[strings.TrimPrefix(fe.Field, "profile.")], fe.Message)
+			}
+			imp.stats.Failed++
+			return false
+		}
+	}
+	if first, ok := imp.seen[u.Email]; ok {
+		imp.fail(n, imp.columns["email"], fmt.Sprintf("email %q is already in row %d", u.Email, first))
+		return false
+	}
+	imp.seen[u.Email] = n
+	imp.pending = append(imp.pending, importRow{n: n, user: u})
+	return true
+}
+
+// user is the user of a row, with a profile if the row has any of its
+// fields.
+func (imp *userImporter) user(values map[string]string) entity.User {
+	v := func(field string) string { return values[imp.columns[field]] }
+	u := entity.User{Name: v("name"), Email: v("email")}
+	p := entity.UserProfile{
+		DisplayName: v("display_name"),
+		Phone:       v("phone"),
+		Locale:      v("locale"),
+		Timezone:    v("timezone"),
+		AvatarURL:   v("avatar_url"),
+		Birthdate:   v("birthdate"),
+	}
+	if p != (entity.UserProfile{}) {
+		u.Profile = &p
+	}
+	return u
+}
+
+// flush writes the pending rows: users with new emails are created, those
+// with taken ones updated when upserting.
+func (imp *userImporter) flush(ctx context.Context) error {
+	if len(imp.pending) == 0 {
+		return nil
+	}
+	emails := make([]string, 0, len(imp.pending))
+	for _, row := range imp.pending {
+		emails = append(emails, row.user.Email)
+	}
+	users, err := imp.svc.ListUsers(ctx, entity.UserFilter{Emails: emails})
+	if err != nil {
+		return fmt.Errorf("rows %d-%d: %w", imp.pending[0].n, imp.pending[len(imp.pending)-1].n, err)
+	}
+	taken := make(map[string]entity.User, len(users))
+	for _, u := range users {
+		taken[u.Email] = u
+	}
+
+	var creates, updates []importRow
+	for _, row := range imp.pending {
+		u, ok := taken[row.user.Email]
+		switch {
+		case !ok:
+			creates = append(creates, row)
+		case !imp.opts.Upsert:
+			imp.fail(row.n, imp.columns["email"], fmt.Sprintf("email %q is already registered", row.user.Email))
+		case u.Name == row.user.Name && (row.user.Profile == nil || profileOf(u) == *row.user.Profile):
+			imp.stats.Unchanged++
+		default:
+			row.user.ID = u.ID
+			updates = append(updates, row)
+		}
+	}
+	imp.pending = imp.pending[:0]
+
+	if imp.opts.DryRun {
+		imp.stats.Created += len(creates)
+		imp.stats.Updated += len(updates)
+		return nil
+	}
+	imp.record(creates, imp.svc.CreateUsers(ctx, rowUsers(creates), false), &imp.stats.Created)
+	imp.record(updates, imp.svc.UpdateUsers(ctx, rowUsers(updates), false), &imp.stats.Updated)
+	if imp.opts.Checkpoint != nil {
+		return imp.opts.Checkpoint(imp.stats.Rows)
+	}
+	return nil
+}
+
+// record counts the rows written in done and reports the others.
+func (imp *userImporter) record(rows []importRow, results []entity.UserBatchResult, done *int) {
+	for i, res := range results {
+		if res.Err != nil {
+			imp.fail(rows[i].n, "", helper.Cause(res.Err))
+			continue
+		}
+		*done++
+	}
+}
+
+// fail counts row n as failed and reports why.
+func (imp *userImporter) fail(n int, column, message string) {
+	imp.stats.Failed++
+	imp.reportLine(n, column, message)
+}
+
+func (imp *userImporter) reportLine(n int, column, message string) {
+	if !imp.reported {
+		imp.reported = true
+		_ = imp.report.Write([]string{"row", "column", "message"})
+	}
+	_ = imp.report.Write([]string{strconv.Itoa(n), column, message})
+}
+
+// profileOf is the profile of u, empty if it has none.
+func profileOf(u entity.User) entity.UserProfile {
+	if u.Profile == nil {
+		return entity.UserProfile{}
+	}
+	return *u.Profile
+}
+
+func rowUsers(rows []importRow) []entity.User {
+	users := make([]entity.User, 0, len(rows))
+	for _, row := range rows {
+		users = append(users, row.user)
+	}
+	return users
+}
+
+// rowReader reads the rows of an import file as values by column.
+type rowReader interface {
+	// next returns the next row, or io.EOF after the last one. A
+	// *badRowError is about this row only, the next one can be read.
+	next() (map[string]string, error)
+}
+
+// badRowError is a row that cannot be read, in a file that can.
+type badRowError struct {
+	column string
+	msg    string
+}
+
+func (e *badRowError) Error() string {
+	return e.msg
+}
+
+// newRowReader returns a reader of the rows of r, which has the given
+// columns.
+func newRowReader(r io.Reader, format string, columns map[string]string) (rowReader, error) {
+	switch format {
+	case "csv":
+		return newCSVRowReader(r, columns)
+	case "ndjson":
+		sc := bufio.NewScanner(r)
+		sc.Buffer(make([]byte, 0, 64*1024), 1<<20)
+		return &ndjsonRowReader{sc: sc, columns: columns}, nil
+	case "json":
+		dec := json.NewDecoder(r)
+		if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+			return nil, errors.New("a JSON import must be an array of objects")
+		}
+		return &jsonRowReader{dec: dec, columns: columns}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q (want %s)", format, strings.Join(Formats, ", "))
+	}
+}
+
+type csvRowReader struct {
+	r      *csv.Reader
+	header []string
+}
+
+func newCSVRowReader(r io.Reader, columns map[string]string) (*csvRowReader, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading the CSV header: %w", err)
+	}
+	if len(header) > 0 {
+		// Spreadsheets like to start UTF-8 files with a byte order mark.
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+	for _, field := range importFields[:2] {
+		if !slices.Contains(header, columns[field]) {
+			return nil, fmt.Errorf("the CSV header has no column %q for the %s", columns[field], field)
+		}
+	}
+	return &csvRowReader{r: cr, header: header}, nil
+}
+
+func (c *csvRowReader) next() (map[string]string, error) {
+	record, err := c.r.Read()
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return nil, &badRowError{msg: parseErr.Err.Error()}
+	}
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(c.header))
+	for i, column := range c.header {
+		if i < len(record) {
+			values[column] = record[i]
+		}
+	}
+	return values, nil
+}
+
+type ndjsonRowReader struct {
+	sc      *bufio.Scanner
+	columns map[string]string
+}
+
+func (j *ndjsonRowReader) next() (map[string]string, error) {
+	for j.sc.Scan() {
+		line := bytes.TrimSpace(j.sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var object map[string]any
+		if err := json.Unmarshal(line, &object); err != nil {
+			return nil, &badRowError{msg: "not a JSON object: " + strings.TrimPrefix(err.Error(), "json: ")}
+		}
+		return objectValues(object, j.columns)
+	}
+	if err := j.sc.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+type jsonRowReader struct {
+	dec     *json.Decoder
+	columns map[string]string
+}
+
+func (j *jsonRowReader) next() (map[string]string, error) {
+	if !j.dec.More() {
+		if _, err := j.dec.Token(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+	var object map[string]any
+	err := j.dec.Decode(&object)
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return nil, &badRowError{msg: "not a JSON object"}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return objectValues(object, j.columns)
+}
+
+// objectValues returns the values of the columns of a JSON object, which
+// must be strings. The profile fields may be in a "profile" object, as
+// exports have them, or next to the others.
+func objectValues(object map[string]any, columns map[string]string) (map[string]string, error) {
+	switch profile := object["profile"].(type) {
+	case nil:
+	case map[string]any:
+		for k, v := range profile {
+			if _, ok := object[k]; !ok {
+				object[k] = v
+			}
+		}
+	default:
+		return nil, &badRowError{column: "profile", msg: "profile must be an object"}
+	}
+	values := make(map[string]string, len(columns))
+	for _, column := range columns {
+		switch v := object[column].(type) {
+		case nil:
+		case string:
+			values[column] = v
+		default:
+			return nil, &badRowError{column: column, msg: fmt.Sprintf("%s must be a string", column)}
+		}
+	}
+	return values, nil
+}
+
+// Checkpoint records how far an import of File got, so that a later
+// run can skip the rows it already did.
+type Checkpoint struct {
+	File string `json:"file"`
+	Rows int    `json:"rows"`
+}
+
+// LoadCheckpoint reads the checkpoint at path. A missing file means
+// that no rows were done.
+func LoadCheckpoint(path string) (Checkpoint, error) {
+	var cp Checkpoint
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cp, nil
+	}
+	if err != nil {
+		return cp, err
+	}
+	if err := json.Unmarshal(b, &cp); err != nil {
+		return cp, fmt.Errorf("checkpoint %s: %w", path, err)
+	}
+	return cp, nil
+}
+
+// Save writes cp to path. It replaces the file in one step, so that a
+// crash leaves the old checkpoint or the new one.
+func (cp Checkpoint) Save(path string) error {
+	b, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(b, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,233 @@
+package userfile
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	entity "user-management/internal/user-management/domain/entities"
+	"user-management/internal/user-management/domain/service"
+	"user-management/internal/user-management/helper"
+	"user-management/mocks"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func listAll(t *testing.T, svc service.IUserService) []entity.User {
+	t.Helper()
+	users, err := svc.ListUsers(context.Background(), entity.UserFilter{})
+	require.NoError(t, err)
+	return users
+}
+
+// checkUser stands in for the rules of POST /users.
+func checkUser(u entity.User) (entity.User, []helper.FieldError) {
+	var problems []helper.FieldError
+	if len(u.Name) < 2 {
+		problems = append(problems, helper.FieldError{Field: "name", Message: "name must be at least 2 characters in length"})
+	}
+	if !strings.Contains(u.Email, "@") {
+		problems = append(problems, helper.FieldError{Field: "email", Message: "email must be a valid email address"})
+	}
+	if u.Profile != nil && u.Profile.Phone != "" && !strings.HasPrefix(u.Profile.Phone, "+") {
+		problems = append(problems, helper.FieldError{Field: "profile.phone", Message: "phone must be a phone number in E.164 format"})
+	}
+	return u, problems
+}
+
+func TestImportCSV(t *testing.T) {
+	svc := newTestUserService(t, entity.User{Name: "Taken", Email: "taken@example.com"})
+	in := "\ufefffull_name,mail,id,tel\n" +
+		"Aren,aren@example.com,7\n" +
+		"B,not-an-email\n" +
+		"Bob,bob@example.com,8\n" +
+		"Bobby,bob@example.com,9\n" +
+		"Other,taken@example.com\n" +
+		"Carl,carl@example.com,,+14155550123\n" +
+		"Dan,dan@example.com,,555\n"
+
+	var report strings.Builder
+	stats, err := Import(context.Background(), svc, strings.NewReader(in), ImportOptions{
+		Format:  "csv",
+		Columns: map[string]string{"name": "full_name", "email": "mail", "phone": "tel"},
+		Check:   checkUser,
+		Report:  &report,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, ImportStats{Rows: 7, Created: 3, Failed: 4}, stats)
+	assert.Equal(t, "row,column,message\n"+
+		"2,full_name,name must be at least 2 characters in length\n"+
+		"2,mail,email must be a valid email address\n"+
+		`4,mail,"email ""bob@example.com"" is already in row 3"`+"\n"+
+		"7,tel,phone must be a phone number in E.164 format\n"+
+		`5,mail,"email ""taken@example.com"" is already registered"`+"\n", report.String())
+	assert.Equal(t, []entity.User{
+		{ID: 1, Name: "Taken", Email: "taken@example.com"},
+		{ID: 2, Name: "Aren", Email: "aren@example.com"},
+		{ID: 3, Name: "Bob", Email: "bob@example.com"},
+		{ID: 4, Name: "Carl", Email: "carl@example.com", Profile: &entity.UserProfile{Phone: "+14155550123"}},
+	}, listAll(t, svc))
+}
+
+func TestImportUpsert(t *testing.T) {
+	in := `{"name":"Aren D","email":"aren@example.com"}
+{"name":"Bob","email":"bob@example.com"}
+
+{"name":"Carl","email":"carl@example.com"}
+not json
+{"name":42,"email":"dan@example.com"}
+`
+	for _, dryRun := range []bool{true, false} {
+		svc := newTestUserService(t,
+			entity.User{Name: "Aren", Email: "aren@example.com"},
+			entity.User{Name: "Bob", Email: "bob@example.com"},
+		)
+		var report strings.Builder
+		stats, err := Import(context.Background(), svc, strings.NewReader(in), ImportOptions{
+			Format: "ndjson",
+			Upsert: true,
+			DryRun: dryRun,
+			Report: &report,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, ImportStats{Rows: 5, Created: 1, Updated: 1, Unchanged: 1, Failed: 2}, stats)
+		assert.Equal(t, "row,column,message\n"+
+			"4,,not a JSON object: invalid character 'o' in literal null (expecting 'u')\n"+
+			"5,name,name must be a string\n", report.String())
+
+		names := []string{}
+		for _, u := range listAll(t, svc) {
+			names = append(names, u.Name)
+		}
+		if dryRun {
+			assert.Equal(t, []string{"Aren", "Bob"}, names)
+		} else {
+			assert.Equal(t, []string{"Aren D", "Bob", "Carl"}, names)
+		}
+	}
+}
+
+func TestImportRoundTrip(t *testing.T) {
+	users := []entity.User{
+		{ID: 1, Name: "Aren", Email: "aren@example.com", Profile: &entity.UserProfile{
+			DisplayName: "Aren D, Jr.", Phone: "+14155550123", Locale: "de-CH", Timezone: "Europe/Zurich",
+			AvatarURL: "https://example.com/aren.png", Birthdate: "1990-02-28"}},
+		{ID: 2, Name: "Bob", Email: "bob@example.com"},
+		{ID: 3, Name: "Carl", Email: "carl@example.com", Profile: &entity.UserProfile{Timezone: "UTC"}},
+	}
+	for _, format := range Formats {
+		t.Run(format, func(t *testing.T) {
+			var file strings.Builder
+			_, err := Export(context.Background(), newTestUserService(t, users...), &file, format, 2)
+			require.NoError(t, err)
+
+			svc := newTestUserService(t)
+			stats, err := Import(context.Background(), svc, strings.NewReader(file.String()), ImportOptions{Format: format, Check: checkUser})
+			require.NoError(t, err)
+			assert.Equal(t, ImportStats{Rows: 3, Created: 3}, stats)
+			assert.Equal(t, users, listAll(t, svc))
+
+			// Importing it again changes nothing.
+			stats, err = Import(context.Background(), svc, strings.NewReader(file.String()), ImportOptions{Format: format, Upsert: true})
+			require.NoError(t, err)
+			assert.Equal(t, ImportStats{Rows: 3, Unchanged: 3}, stats)
+		})
+	}
+}
+
+func TestImportUpsertProfile(t *testing.T) {
+	svc := newTestUserService(t,
+		entity.User{Name: "Aren", Email: "aren@example.com", Profile: &entity.UserProfile{Phone: "+14155550123"}},
+		entity.User{Name: "Bob", Email: "bob@example.com", Profile: &entity.UserProfile{Locale: "en-US"}},
+	)
+	in := `{"name":"Aren","email":"aren@example.com","profile":{"timezone":"UTC"}}
+{"name":"Bob","email":"bob@example.com"}
+{"name":"Carl","email":"carl@example.com","profile":"UTC"}
+`
+	var report strings.Builder
+	stats, err := Import(context.Background(), svc, strings.NewReader(in), ImportOptions{Format: "ndjson", Upsert: true, Report: &report})
+	require.NoError(t, err)
+	assert.Equal(t, ImportStats{Rows: 3, Updated: 1, Unchanged: 1, Failed: 1}, stats)
+	assert.Equal(t, "row,column,message\n3,profile,profile must be an object\n", report.String())
+	assert.Equal(t, []entity.User{
+		{ID: 1, Name: "Aren", Email: "aren@example.com", Profile: &entity.UserProfile{Timezone: "UTC"}},
+		{ID: 2, Name: "Bob", Email: "bob@example.com", Profile: &entity.UserProfile{Locale: "en-US"}},
+	}, listAll(t, svc), "a row with a profile replaces it whole, one without keeps it")
+}
+
+func TestImportResume(t *testing.T) {
+	svc := newTestUserService(t)
+	in := `[
+		{"name":"Aren","email":"aren@example.com"},
+		{"name":"Bob","email":"bob@example.com"},
+		["not", "an", "object"],
+		{"name":"Carl","email":"carl@example.com"},
+		{"name":"Dan","email":"dan@example.com"}
+	]`
+
+	var checkpoints []int
+	stats, err := Import(context.Background(), svc, strings.NewReader(in), ImportOptions{
+		Format:     "json",
+		BatchSize:  1,
+		Skip:       2,
+		Checkpoint: func(rows int) error { checkpoints = append(checkpoints, rows); return nil },
+	})
+	require.NoError(t, err)
+	assert.Equal(t, ImportStats{Rows: 5, Skipped: 2, Created: 2, Failed: 1}, stats)
+	assert.Equal(t, []int{4, 5}, checkpoints)
+	assert.Len(t, listAll(t, svc), 2)
+}
+
+func TestImportStops(t *testing.T) {
+	svc := newTestUserService(t)
+	ctx := context.Background()
+
+	_, err := Import(ctx, svc, strings.NewReader("name,mail\n"), ImportOptions{Format: "csv"})
+	assert.EqualError(t, err, `the CSV header has no column "email" for the email`)
+	_, err = Import(ctx, svc, strings.NewReader("{}"), ImportOptions{Format: "json"})
+	assert.EqualError(t, err, "a JSON import must be an array of objects")
+	_, err = Import(ctx, svc, strings.NewReader(""), ImportOptions{Format: "csv", Columns: map[string]string{"mobile": "tel"}})
+	assert.EqualError(t, err, `cannot map column "tel" to unknown field "mobile" (want name, email, display_name, phone, locale, timezone, avatar_url, birthdate)`)
+
+	// Rows before a broken part of the file are still imported.
+	stats, err := Import(ctx, svc, strings.NewReader(`[{"name":"Aren","email":"aren@example.com"}, {`), ImportOptions{Format: "json"})
+	assert.ErrorContains(t, err, "row 2: ")
+	assert.Equal(t, 1, stats.Rows)
+	assert.Empty(t, listAll(t, svc), "the batch was not written")
+}
+
+func TestImportCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "import.checkpoint")
+
+	cp, err := LoadCheckpoint(path)
+	require.NoError(t, err)
+	assert.Equal(t, Checkpoint{}, cp)
+
+	require.NoError(t, Checkpoint{File: "/data/users.csv", Rows: 1200}.Save(path))
+	cp, err = LoadCheckpoint(path)
+	require.NoError(t, err)
+	assert.Equal(t, Checkpoint{File: "/data/users.csv", Rows: 1200}, cp)
+}
+
+func TestImportLooksUpEmailsPerBatch(t *testing.T) {
+	svc := mocks.NewIUserService(t)
+	svc.On("ListUsers", mock.Anything, entity.UserFilter{Emails: []string{"a@example.com", "b@example.com"}}).
+		Return([]entity.User{{ID: 7, Name: "Bob", Email: "b@example.com"}}, nil).Once()
+	svc.On("ListUsers", mock.Anything, entity.UserFilter{Emails: []string{"c@example.com"}}).Return(nil, nil).Once()
+	svc.On("CreateUsers", mock.Anything, mock.Anything, false).Return(func(_ context.Context, users []entity.User, _ bool) []entity.UserBatchResult {
+		results := make([]entity.UserBatchResult, len(users))
+		for i, u := range users {
+			results[i].User = u
+		}
+		return results
+	})
+	svc.On("UpdateUsers", mock.Anything, []entity.User{}, false).Return(nil)
+
+	in := "name,email\nAnn,a@example.com\nBob,b@example.com\nCy,c@example.com\n"
+	stats, err := Import(context.Background(), svc, strings.NewReader(in), ImportOptions{Format: "csv", Upsert: true, BatchSize: 2})
+	require.NoError(t, err)
+	assert.Equal(t, ImportStats{Rows: 3, Created: 2, Unchanged: 1}, stats)
+}
@@ -0,0 +1,40 @@
+package helper
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Accepted is one entry of an Accept-style header: a media type, encoding
+// or language with its quality, 0 meaning "not acceptable".
+type Accepted struct {
+	Value string
+	Q     float64
+}
+
+// ParseAccept parses an Accept, Accept-Encoding or Accept-Language header
+// into its entries, most preferred first; equally preferred ones keep their
+// order. Values are lower-cased and stripped of parameters other than q.
+func ParseAccept(header string) []Accepted {
+	var entries []Accepted
+	for _, part := range strings.Split(header, ",") {
+		value, params, _ := strings.Cut(part, ";")
+		value = strings.ToLower(strings.TrimSpace(value))
+		if value == "" {
+			continue
+		}
+		q := 1.0
+		for _, p := range strings.Split(params, ";") {
+			k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+			if strings.EqualFold(k, "q") {
+				if f, err := strconv.ParseFloat(v, 64); err == nil && f >= 0 && f <= 1 {
+					q = f
+				}
+			}
+		}
+		entries = append(entries, Accepted{Value: value, Q: q})
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Q > entries[j].Q })
+	return entries
+}
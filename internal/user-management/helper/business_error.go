@@ -1,6 +1,9 @@
 package helper
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
 const (
 	Unknown            = 2
@@ -32,3 +35,19 @@ func NewError(status uint8, err error) *BusinessError {
 		Err:    err,
 	}
 }
+
+// HasStatus reports whether any error in err's chain is a BusinessError
+// with the given status.
+func HasStatus(err error, status uint8) bool {
+	var be *BusinessError
+	return errors.As(err, &be) && be.Status == status
+}
+
+// Cause is the message of err, without the status of a BusinessError.
+func Cause(err error) string {
+	var be *BusinessError
+	if errors.As(err, &be) {
+		return be.Err.Error()
+	}
+	return err.Error()
+}
@@ -0,0 +1,24 @@
+package helper
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientIP returns the address of the client that made r, preferring the
+// first X-Forwarded-For hop and X-Real-IP set by a proxy in front of us.
+func ClientIP(r *http.Request) string {
+	if ip := r.Header.Get("X-Forwarded-For"); ip != "" {
+		first, _, _ := strings.Cut(ip, ",")
+		return strings.TrimSpace(first)
+	}
+	if ip := r.Header.Get("X-Real-IP"); ip != "" {
+		return ip
+	}
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
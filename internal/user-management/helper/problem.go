@@ -0,0 +1,52 @@
+package helper
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ProblemContentType is the media type of Problem responses.
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 9457 problem details response body.
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	// Errors lists what is wrong with each field of an invalid request.
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError is a problem with one field of a request body: its JSON path
+// (e.g. events[0]), the rule it broke (e.g. min) with the rule's parameter,
+// and a message for people, in the language they asked for.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// NewProblem returns the problem for a plain HTTP status, titled with the
+// status text.
+func NewProblem(status int, detail string) Problem {
+	return Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// WriteProblem writes p as the response, with p.Status as the status code.
+func WriteProblem(w http.ResponseWriter, p Problem) {
+	h := w.Header()
+	h.Set("Content-Type", ProblemContentType)
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Del("Content-Length")
+	w.WriteHeader(p.Status)
+	_ = json.NewEncoder(w).Encode(p)
+}
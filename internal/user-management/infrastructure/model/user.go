@@ -1,10 +1,21 @@
 package model
 
-import "github.com/uptrace/bun"
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
 
 type User struct {
 	bun.BaseModel `bun:"table:users"`
-	ID            int64  `bun:",pk,autoincrement"`
+	ID            int64 `bun:",pk,autoincrement"`
 	Name          string
 	Email         string
+	// The profile columns are NULL when unknown.
+	DisplayName string    `bun:",nullzero"`
+	Phone       string    `bun:",nullzero"`
+	Locale      string    `bun:",nullzero"`
+	Timezone    string    `bun:",nullzero"`
+	AvatarURL   string    `bun:"avatar_url,nullzero"`
+	Birthdate   time.Time `bun:"type:date,nullzero"`
 }
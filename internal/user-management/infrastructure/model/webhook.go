@@ -0,0 +1,32 @@
+package model
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+type WebhookSubscription struct {
+	bun.BaseModel `bun:"table:webhook_subscriptions"`
+	ID            int64  `bun:",pk,autoincrement"`
+	URL           string `bun:",notnull"`
+	Events        string `bun:",notnull"`
+	Secret        string `bun:",notnull"`
+	Active        bool   `bun:",notnull"`
+	CreatedAt     time.Time
+}
+
+type WebhookDelivery struct {
+	bun.BaseModel  `bun:"table:webhook_deliveries"`
+	ID             int64  `bun:",pk,autoincrement"`
+	SubscriptionID int64  `bun:",notnull"`
+	EventType      string `bun:",notnull"`
+	Payload        string `bun:",type:text,notnull"`
+	Status         string `bun:",notnull"`
+	Attempts       int    `bun:",notnull"`
+	LastError      string `bun:",type:text"`
+	LastStatusCode int
+	NextAttemptAt  time.Time
+	DeliveredAt    bun.NullTime
+	CreatedAt      time.Time
+}
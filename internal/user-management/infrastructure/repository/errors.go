@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"user-management/internal/user-management/helper"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+// notFound wraps sql.ErrNoRows into a NotFound business error so callers
+// can tell a missing row apart from a failing database.
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return helper.NewError(helper.NotFound, err)
+	}
+	return err
+}
+
+func affected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return helper.NewError(helper.NotFound, sql.ErrNoRows)
+	}
+	return nil
+}
+
+// alreadyExists wraps unique constraint violations of any supported driver
+// into an AlreadyExists business error.
+func alreadyExists(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var myErr *mysql.MySQLError
+	var pgErr pgdriver.Error
+	switch {
+	case errors.As(err, &myErr) && myErr.Number == 1062,
+		errors.As(err, &pgErr) && pgErr.Field('C') == "23505",
+		strings.Contains(err.Error(), "UNIQUE constraint failed"):
+		return helper.NewError(helper.AlreadyExists, err)
+	}
+	return err
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"user-management/internal/user-management/domain"
+	entity "user-management/internal/user-management/domain/entities"
+	"user-management/internal/user-management/infrastructure/model"
+
+	"github.com/uptrace/bun"
+)
+
+// fulltextMinWordLen is InnoDB's default innodb_ft_min_token_size; MySQL
+// does not index shorter words.
+const fulltextMinWordLen = 3
+
+// fulltextMatch is the relevance of a row to a boolean-mode query.
+const fulltextMatch = "MATCH (name, email) AGAINST (? IN BOOLEAN MODE)"
+
+type fulltextUserSearcher struct {
+	dbRouter
+	fallback domain.IUserSearcher
+}
+
+// NewFulltextUserSearcher returns a searcher for MySQL that finds users
+// whose name or email has words starting with every word of the query,
+// ranked by the users_search_ft FULLTEXT index. Reads go to the database
+// returned by read, if not nil, as in NewRoutedUserRepository. When the
+// index finds nothing, e.g. for a misspelling or a query of words too
+// short to be indexed, fallback searches instead if it is not nil.
+func NewFulltextUserSearcher(db *bun.DB, read ReadDBFunc, fallback domain.IUserSearcher) domain.IUserSearcher {
+	return &fulltextUserSearcher{dbRouter: dbRouter{db: db, read: read}, fallback: fallback}
+}
+
+// userSearchRow is a user with its relevance to a search.
+type userSearchRow struct {
+	model.User `bun:",extend"`
+	Score      float64 `bun:"score,scanonly"`
+}
+
+func (s *fulltextUserSearcher) Search(ctx context.Context, query entity.UserSearchQuery) (entity.UserSearchResult, error) {
+	words := fulltextWords(query.Text)
+	if len(words) == 0 {
+		return s.searchFallback(ctx, query)
+	}
+
+	var rows []userSearchRow
+	total, err := fulltextQuery(s.reader(ctx), &rows, words, query).ScanAndCount(ctx)
+	if err != nil {
+		return entity.UserSearchResult{}, err
+	}
+	if total == 0 {
+		return s.searchFallback(ctx, query)
+	}
+
+	result := entity.UserSearchResult{Total: total, Hits: make([]entity.UserSearchHit, 0, len(rows))}
+	for _, row := range rows {
+		u := entity.ToEntity(row.User)
+		result.Hits = append(result.Hits, entity.UserSearchHit{
+			User:       u,
+			Score:      row.Score,
+			Highlights: prefixHighlights(u, words),
+		})
+	}
+	return result, nil
+}
+
+func (s *fulltextUserSearcher) searchFallback(ctx context.Context, query entity.UserSearchQuery) (entity.UserSearchResult, error) {
+	if s.fallback == nil {
+		return entity.UserSearchResult{}, nil
+	}
+	return s.fallback.Search(ctx, query)
+}
+
+// fulltextQuery selects the users matching every one of words into rows,
+// best first.
+func fulltextQuery(db bun.IDB, rows *[]userSearchRow, words []string, query entity.UserSearchQuery) *bun.SelectQuery {
+	terms := make([]string, len(words))
+	for i, w := range words {
+		terms[i] = "+" + w + "*"
+	}
+	against := strings.Join(terms, " ")
+
+	q := db.NewSelect().Model(rows).
+		ColumnExpr("?TableColumns").
+		ColumnExpr(fulltextMatch+" AS score", against).
+		Where(fulltextMatch, against).
+		OrderExpr("score DESC").
+		Order("id")
+	if query.Limit > 0 {
+		q = q.Limit(query.Limit)
+	}
+	if query.Offset > 0 {
+		q = q.Offset(query.Offset)
+	}
+	return q
+}
+
+// fulltextWords splits a query into words as the FULLTEXT parser does,
+// on anything but letters and digits, leaving out those too short to be
+// indexed. What is left cannot be taken for a boolean-mode operator.
+func fulltextWords(text string) []string {
+	var out []string
+	for _, sp := range words(text) {
+		w := strings.ToLower(text[sp[0]:sp[1]])
+		if utf8.RuneCountInString(w) >= fulltextMinWordLen && !slices.Contains(out, w) {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+// prefixHighlights marks where words start words of the fields of u, as
+// the index matched them.
+func prefixHighlights(u entity.User, words []string) []entity.Highlight {
+	var hs []entity.Highlight
+	for _, f := range searchFields(u) {
+		for _, w := range words {
+			for _, sp := range foldIndexAll(f.value, w) {
+				if wordStart(f.value, sp[0]) {
+					hs = append(hs, entity.Highlight{Field: f.name, Start: sp[0], End: sp[1]})
+				}
+			}
+		}
+	}
+	return mergeHighlights(hs)
+}
@@ -0,0 +1,259 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"user-management/internal/user-management/domain"
+	entity "user-management/internal/user-management/domain/entities"
+	"user-management/internal/user-management/helper"
+)
+
+// memoryUserRepo is a thread-safe IUserRepository kept entirely in memory,
+// for demos, local runs and tests. It follows the bun repository's
+// semantics, which the conformance suite checks.
+type memoryUserRepo struct {
+	mu     sync.RWMutex
+	lastID int64
+	users  map[int64]entity.User
+}
+
+func NewInMemoryUserRepository() domain.IUserRepository {
+	return &memoryUserRepo{users: make(map[int64]entity.User)}
+}
+
+// memoryTx collects how to undo the changes made in a transaction, see
+// RunInTx.
+type memoryTx struct {
+	undo []func()
+}
+
+type memoryTxCtxKey struct{}
+
+func (r *memoryUserRepo) Create(ctx context.Context, user entity.User) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.checkEmail(user.Email, 0); err != nil {
+		return 0, err
+	}
+	return r.insert(ctx, user), nil
+}
+
+func (r *memoryUserRepo) CreateMany(ctx context.Context, users []entity.User) ([]int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	seen := make(map[string]bool, len(users))
+	for _, u := range users {
+		if err := r.checkEmail(u.Email, 0); err != nil {
+			return nil, err
+		}
+		if seen[u.Email] {
+			return nil, helper.NewError(helper.AlreadyExists, fmt.Errorf("email %q is already registered", u.Email))
+		}
+		seen[u.Email] = true
+	}
+
+	ids := make([]int64, 0, len(users))
+	for _, u := range users {
+		ids = append(ids, r.insert(ctx, u))
+	}
+	return ids, nil
+}
+
+// insert stores user under a new ID. r.mu must be held.
+func (r *memoryUserRepo) insert(ctx context.Context, user entity.User) int64 {
+	r.lastID++
+	user.ID = r.lastID
+	r.users[user.ID] = stored(user)
+	r.onRollback(ctx, func() { delete(r.users, user.ID) })
+	return user.ID
+}
+
+func (r *memoryUserRepo) GetAll(_ context.Context, filter entity.UserFilter) ([]entity.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	name := strings.ToLower(filter.Name)
+	var emails map[string]bool
+	if len(filter.Emails) > 0 {
+		emails = make(map[string]bool, len(filter.Emails))
+		for _, e := range filter.Emails {
+			emails[e] = true
+		}
+	}
+	result := make([]entity.User, 0, len(r.users))
+	for _, u := range r.users {
+		if name != "" && !strings.Contains(strings.ToLower(u.Name), name) {
+			continue
+		}
+		if filter.Email != "" && u.Email != filter.Email {
+			continue
+		}
+		if emails != nil && !emails[u.Email] {
+			continue
+		}
+		if u.ID <= filter.AfterID {
+			continue
+		}
+		result = append(result, cloneUser(u))
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+
+	if filter.Offset > 0 {
+		if filter.Offset >= len(result) {
+			return []entity.User{}, nil
+		}
+		result = result[filter.Offset:]
+	}
+	if filter.Limit > 0 && filter.Limit < len(result) {
+		result = result[:filter.Limit]
+	}
+	return result, nil
+}
+
+// Stream calls fn with a copy of the users GetAll returns, so fn may use
+// the repository.
+func (r *memoryUserRepo) Stream(ctx context.Context, filter entity.UserFilter, fn func(user entity.User) error) error {
+	users, err := r.GetAll(ctx, filter)
+	if err != nil {
+		return err
+	}
+	for _, u := range users {
+		if err := fn(u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *memoryUserRepo) GetByID(_ context.Context, id int64) (entity.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	u, ok := r.users[id]
+	if !ok {
+		return entity.User{}, helper.NewError(helper.NotFound, sql.ErrNoRows)
+	}
+	return cloneUser(u), nil
+}
+
+func (r *memoryUserRepo) Update(ctx context.Context, user entity.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	old, ok := r.users[user.ID]
+	if !ok {
+		return helper.NewError(helper.NotFound, sql.ErrNoRows)
+	}
+	if err := r.checkEmail(user.Email, user.ID); err != nil {
+		return err
+	}
+	if user.Profile == nil {
+		user.Profile = old.Profile
+	}
+	r.users[user.ID] = stored(user)
+	r.onRollback(ctx, func() { r.users[old.ID] = old })
+	return nil
+}
+
+func (r *memoryUserRepo) Delete(ctx context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.remove(ctx, id) {
+		return helper.NewError(helper.NotFound, sql.ErrNoRows)
+	}
+	return nil
+}
+
+func (r *memoryUserRepo) DeleteMany(ctx context.Context, ids []int64) ([]int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deleted []int64
+	for _, id := range ids {
+		if r.remove(ctx, id) {
+			deleted = append(deleted, id)
+		}
+	}
+	sort.Slice(deleted, func(i, j int) bool { return deleted[i] < deleted[j] })
+	return deleted, nil
+}
+
+// remove deletes the user with id and reports whether there was one. r.mu
+// must be held.
+func (r *memoryUserRepo) remove(ctx context.Context, id int64) bool {
+	old, ok := r.users[id]
+	if !ok {
+		return false
+	}
+	delete(r.users, id)
+	r.onRollback(ctx, func() { r.users[id] = old })
+	return true
+}
+
+// RunInTx undoes the changes fn made with its ctx when fn fails or panics.
+// Unlike a database transaction, other callers see the changes before fn
+// returns. IDs are not handed out again, as with auto-increment columns.
+func (r *memoryUserRepo) RunInTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(memoryTxCtxKey{}).(*memoryTx); ok {
+		return fn(ctx)
+	}
+
+	tx := &memoryTx{}
+	done := false
+	defer func() {
+		if done {
+			return
+		}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		for i := len(tx.undo) - 1; i >= 0; i-- {
+			tx.undo[i]()
+		}
+	}()
+	err := fn(context.WithValue(ctx, memoryTxCtxKey{}, tx))
+	done = err == nil
+	return err
+}
+
+// onRollback has undo run, with r.mu held, when the transaction ctx is in
+// fails. r.mu must be held.
+func (r *memoryUserRepo) onRollback(ctx context.Context, undo func()) {
+	if tx, ok := ctx.Value(memoryTxCtxKey{}).(*memoryTx); ok {
+		tx.undo = append(tx.undo, undo)
+	}
+}
+
+// checkEmail fails when email belongs to a user other than self.
+func (r *memoryUserRepo) checkEmail(email string, self int64) error {
+	for id, u := range r.users {
+		if id != self && u.Email == email {
+			return helper.NewError(helper.AlreadyExists, fmt.Errorf("email %q is already registered", email))
+		}
+	}
+	return nil
+}
+
+// stored returns u as the bun repository would read it back: an empty
+// profile, or one whose fields do not survive the table, is normalised,
+// and the profile is a copy of that of u.
+func stored(u entity.User) entity.User {
+	return entity.ToEntity(entity.FromEntity(u))
+}
+
+// cloneUser copies the profile of u, so that neither the store nor its
+// callers see changes the other makes to it.
+func cloneUser(u entity.User) entity.User {
+	if u.Profile != nil {
+		p := *u.Profile
+		u.Profile = &p
+	}
+	return u
+}
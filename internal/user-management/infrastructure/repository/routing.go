@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/uptrace/bun"
+)
+
+// ReadDBFunc returns the database to send a read-only query to, usually a
+// healthy read replica. It is called once per query.
+type ReadDBFunc func() (*bun.DB, error)
+
+type txCtxKey struct{}
+
+type primaryCtxKey struct{}
+
+// WithPrimary marks ctx so that reads made with it go to the primary, e.g.
+// right after the same client wrote and replicas may not have caught up.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryCtxKey{}, true)
+}
+
+// PrimaryOnly reports whether reads made with ctx must use the primary.
+func PrimaryOnly(ctx context.Context) bool {
+	v, _ := ctx.Value(primaryCtxKey{}).(bool)
+	return v
+}
+
+// RunInTx runs fn in a transaction on db. Repository calls made with the
+// ctx passed to fn join the transaction, reads included.
+func RunInTx(ctx context.Context, db *bun.DB, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txCtxKey{}).(bun.Tx); ok {
+		return fn(ctx)
+	}
+	return db.RunInTx(ctx, &sql.TxOptions{}, func(ctx context.Context, tx bun.Tx) error {
+		return fn(context.WithValue(ctx, txCtxKey{}, tx))
+	})
+}
+
+// dbRouter sends writes to the primary and reads to the replicas picked by
+// read, except inside a transaction or for contexts marked WithPrimary.
+type dbRouter struct {
+	db   *bun.DB
+	read ReadDBFunc
+}
+
+func (r dbRouter) writer(ctx context.Context) bun.IDB {
+	if tx, ok := ctx.Value(txCtxKey{}).(bun.Tx); ok {
+		return tx
+	}
+	return r.db
+}
+
+func (r dbRouter) reader(ctx context.Context) bun.IDB {
+	if tx, ok := ctx.Value(txCtxKey{}).(bun.Tx); ok {
+		return tx
+	}
+	if r.read == nil || PrimaryOnly(ctx) {
+		return r.db
+	}
+	db, err := r.read()
+	if err != nil || db == nil {
+		return r.db
+	}
+	return db
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"user-management/app"
+	"user-management/cmd/migrations"
+	entity "user-management/internal/user-management/domain/entities"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/migrate"
+)
+
+func openMigratedSQLite(t *testing.T, path string) *bun.DB {
+	cfg := &app.Config{}
+	cfg.DB.Driver = app.DriverSQLite
+	cfg.DB.Database = path
+
+	db, err := app.OpenDB(context.Background(), cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	migrator := migrate.NewMigrator(db, migrations.Migrations)
+	require.NoError(t, migrator.Init(context.Background()))
+	_, err = migrator.Migrate(context.Background())
+	require.NoError(t, err)
+	return db
+}
+
+func TestRoutedUserRepository(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	primary := openMigratedSQLite(t, dir+"/primary.db")
+	replica := openMigratedSQLite(t, dir+"/replica.db")
+
+	repo := NewRoutedUserRepository(primary, func() (*bun.DB, error) { return replica, nil })
+
+	// The replica has not caught up with this write yet.
+	id, err := repo.Create(ctx, entity.User{Name: "Written", Email: "w@example.com"})
+	require.NoError(t, err)
+
+	_, err = repo.GetByID(ctx, id)
+	assert.Error(t, err, "reads go to the replica")
+
+	u, err := repo.GetByID(WithPrimary(ctx), id)
+	require.NoError(t, err)
+	assert.Equal(t, "Written", u.Name)
+
+	err = RunInTx(ctx, primary, func(ctx context.Context) error {
+		require.NoError(t, repo.Update(ctx, entity.User{ID: id, Name: "In tx", Email: "w@example.com"}))
+		u, err := repo.GetByID(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, "In tx", u.Name, "reads in a transaction see its writes")
+		return nil
+	})
+	require.NoError(t, err)
+
+	all, err := repo.GetAll(ctx, entity.UserFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, all)
+}
+
+func TestRoutedUserRepository_ReadDBErrorFallsBackToPrimary(t *testing.T) {
+	ctx := context.Background()
+	primary := openMigratedSQLite(t, t.TempDir()+"/primary.db")
+
+	repo := NewRoutedUserRepository(primary, func() (*bun.DB, error) { return nil, assert.AnError })
+
+	id, err := repo.Create(ctx, entity.User{Name: "Aren", Email: "aren@example.com"})
+	require.NoError(t, err)
+	_, err = repo.GetByID(ctx, id)
+	assert.NoError(t, err)
+}
+
+func TestRunInTx_RollsBack(t *testing.T) {
+	ctx := context.Background()
+	primary := openMigratedSQLite(t, t.TempDir()+"/primary.db")
+	repo := NewUserRepository(primary)
+
+	err := RunInTx(ctx, primary, func(ctx context.Context) error {
+		_, err := repo.Create(ctx, entity.User{Name: "Aren", Email: "aren@example.com"})
+		require.NoError(t, err)
+		return assert.AnError
+	})
+	assert.ErrorIs(t, err, assert.AnError)
+
+	all, err := repo.GetAll(ctx, entity.UserFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, all)
+}
@@ -2,6 +2,9 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"math"
+	"strings"
 	"user-management/internal/user-management/domain"
 	entity "user-management/internal/user-management/domain/entities"
 	"user-management/internal/user-management/infrastructure/model"
@@ -10,48 +13,175 @@ import (
 )
 
 type userRepo struct {
-	db *bun.DB
+	dbRouter
 }
 
 func NewUserRepository(db *bun.DB) domain.IUserRepository {
-	return &userRepo{db: db}
+	return &userRepo{dbRouter{db: db}}
 }
 
-func (r *userRepo) Create(user entity.User) (int64, error) {
+// NewRoutedUserRepository is like NewUserRepository but sends reads to the
+// database returned by read, typically App.ReadDB. Writes, and reads in a
+// transaction or marked WithPrimary, stay on db.
+func NewRoutedUserRepository(db *bun.DB, read ReadDBFunc) domain.IUserRepository {
+	return &userRepo{dbRouter{db: db, read: read}}
+}
+
+func (r *userRepo) Create(ctx context.Context, user entity.User) (int64, error) {
 	u := entity.FromEntity(user)
-	_, err := r.db.NewInsert().Model(&u).Exec(context.Background())
-	return u.ID, err
+	u.ID = 0
+	_, err := r.writer(ctx).NewInsert().Model(&u).Exec(ctx)
+	return u.ID, alreadyExists(err)
+}
+
+func (r *userRepo) CreateMany(ctx context.Context, users []entity.User) ([]int64, error) {
+	if len(users) == 0 {
+		return nil, nil
+	}
+	rows := make([]model.User, 0, len(users))
+	for _, user := range users {
+		u := entity.FromEntity(user)
+		u.ID = 0
+		rows = append(rows, u)
+	}
+	if _, err := r.writer(ctx).NewInsert().Model(&rows).Exec(ctx); err != nil {
+		return nil, alreadyExists(err)
+	}
+	ids := make([]int64, 0, len(rows))
+	for _, u := range rows {
+		ids = append(ids, u.ID)
+	}
+	return ids, nil
 }
 
-func (r *userRepo) GetAll() ([]entity.User, error) {
+func (r *userRepo) GetAll(ctx context.Context, filter entity.UserFilter) ([]entity.User, error) {
 	var users []model.User
-	err := r.db.NewSelect().Model(&users).Scan(context.Background())
+	err := listQuery(r.reader(ctx).NewSelect().Model(&users), filter).Scan(ctx)
 	if err != nil {
 		return nil, err
 	}
-	var result []entity.User
+	result := make([]entity.User, 0, len(users))
 	for _, u := range users {
 		result = append(result, entity.ToEntity(u))
 	}
 	return result, nil
 }
 
-func (r *userRepo) GetByID(id int64) (entity.User, error) {
+func (r *userRepo) Stream(ctx context.Context, filter entity.UserFilter, fn func(user entity.User) error) error {
+	rows, err := listQuery(r.reader(ctx).NewSelect().Model((*model.User)(nil)), filter).Rows(ctx)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var u model.User
+		if err := r.db.ScanRow(ctx, rows, &u); err != nil {
+			return err
+		}
+		if err := fn(entity.ToEntity(u)); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// listQuery narrows down q, a select of users, to those filter lets
+// through, in ID order.
+func listQuery(q *bun.SelectQuery, filter entity.UserFilter) *bun.SelectQuery {
+	q = q.Order("id")
+	if filter.Name != "" {
+		q = q.Where("LOWER(name) LIKE ?", "%"+strings.ToLower(filter.Name)+"%")
+	}
+	if filter.Email != "" {
+		q = q.Where("email = ?", filter.Email)
+	}
+	if len(filter.Emails) > 0 {
+		q = q.Where("email IN (?)", bun.In(filter.Emails))
+	}
+	if filter.AfterID > 0 {
+		q = q.Where("id > ?", filter.AfterID)
+	}
+	if filter.Limit > 0 {
+		q = q.Limit(filter.Limit)
+	}
+	if filter.Offset > 0 {
+		if filter.Limit == 0 {
+			// MySQL and SQLite only accept OFFSET after a LIMIT.
+			q = q.Limit(math.MaxInt32)
+		}
+		q = q.Offset(filter.Offset)
+	}
+	return q
+}
+
+func (r *userRepo) GetByID(ctx context.Context, id int64) (entity.User, error) {
 	var user model.User
-	err := r.db.NewSelect().Model(&user).Where("id = ?", id).Scan(context.Background())
+	err := r.reader(ctx).NewSelect().Model(&user).Where("id = ?", id).Scan(ctx)
 	if err != nil {
-		return entity.User{}, err
+		return entity.User{}, notFound(err)
 	}
 	return entity.ToEntity(user), nil
 }
 
-func (r *userRepo) Update(user entity.User) error {
+// profileColumns are the columns of entity.UserProfile.
+var profileColumns = []string{"display_name", "phone", "locale", "timezone", "avatar_url", "birthdate"}
+
+func (r *userRepo) Update(ctx context.Context, user entity.User) error {
+	db := r.writer(ctx)
 	u := entity.FromEntity(user)
-	_, err := r.db.NewUpdate().Model(&u).Where("id = ?", u.ID).Exec(context.Background())
-	return err
+	columns := []string{"name", "email"}
+	if user.Profile != nil {
+		columns = append(columns, profileColumns...)
+	}
+	res, err := db.NewUpdate().Model(&u).Column(columns...).Where("id = ?", u.ID).Exec(ctx)
+	if err != nil {
+		return alreadyExists(err)
+	}
+	if err := affected(res); err == nil {
+		return nil
+	}
+	// MySQL reports rows changed rather than rows matched, so an update
+	// that leaves the row as it was looks the same as a missing row.
+	exists, err := db.NewSelect().Model((*model.User)(nil)).Where("id = ?", u.ID).Exists(ctx)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return notFound(sql.ErrNoRows)
+	}
+	return nil
+}
+
+func (r *userRepo) Delete(ctx context.Context, id int64) error {
+	res, err := r.writer(ctx).NewDelete().Model(&model.User{}).Where("id = ?", id).Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return affected(res)
+}
+
+func (r *userRepo) DeleteMany(ctx context.Context, ids []int64) ([]int64, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var deleted []int64
+	err := r.RunInTx(ctx, func(ctx context.Context) error {
+		db := r.writer(ctx)
+		err := db.NewSelect().Model((*model.User)(nil)).Column("id").
+			Where("id IN (?)", bun.In(ids)).Order("id").Scan(ctx, &deleted)
+		if err != nil || len(deleted) == 0 {
+			return err
+		}
+		_, err = db.NewDelete().Model((*model.User)(nil)).Where("id IN (?)", bun.In(deleted)).Exec(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deleted, nil
 }
 
-func (r *userRepo) Delete(id int64) error {
-	_, err := r.db.NewDelete().Model(&model.User{}).Where("id = ?", id).Exec(context.Background())
-	return err
+func (r *userRepo) RunInTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return RunInTx(ctx, r.db, fn)
 }
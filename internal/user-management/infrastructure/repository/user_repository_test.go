@@ -0,0 +1,306 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"user-management/internal/user-management/domain"
+	entity "user-management/internal/user-management/domain/entities"
+	"user-management/internal/user-management/helper"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+)
+
+func TestUserRepository_Bun(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, db *bun.DB) {
+		testUserRepositoryConformance(t, func() domain.IUserRepository {
+			_, err := db.NewTruncateTable().Table("users").Exec(context.Background())
+			require.NoError(t, err)
+			return NewUserRepository(db)
+		})
+	})
+}
+
+func TestUserRepository_InMemory(t *testing.T) {
+	testUserRepositoryConformance(t, NewInMemoryUserRepository)
+}
+
+// testUserRepositoryConformance is the behaviour every IUserRepository
+// must share. newRepo returns an empty repository.
+func testUserRepositoryConformance(t *testing.T, newRepo func() domain.IUserRepository) {
+	ctx := context.Background()
+
+	t.Run("CRUD", func(t *testing.T) {
+		repo := newRepo()
+
+		id1, err := repo.Create(ctx, entity.User{Name: "Aren", Email: "aren@example.com"})
+		require.NoError(t, err)
+		id2, err := repo.Create(ctx, entity.User{Name: "Bob", Email: "bob@example.com"})
+		require.NoError(t, err)
+		assert.NotZero(t, id1)
+		assert.Greater(t, id2, id1)
+
+		u, err := repo.GetByID(ctx, id1)
+		require.NoError(t, err)
+		assert.Equal(t, entity.User{ID: id1, Name: "Aren", Email: "aren@example.com"}, u)
+
+		require.NoError(t, repo.Update(ctx, entity.User{ID: id1, Name: "Aren D", Email: "aren@example.com"}))
+		u, err = repo.GetByID(ctx, id1)
+		require.NoError(t, err)
+		assert.Equal(t, "Aren D", u.Name)
+
+		// Saving a row unchanged is not a missing row.
+		require.NoError(t, repo.Update(ctx, u))
+
+		require.NoError(t, repo.Delete(ctx, id2))
+		all, err := repo.GetAll(ctx, entity.UserFilter{})
+		require.NoError(t, err)
+		assert.Equal(t, []entity.User{u}, all)
+	})
+
+	t.Run("Profile", func(t *testing.T) {
+		repo := newRepo()
+
+		profile := &entity.UserProfile{
+			DisplayName: "Aren",
+			Phone:       "+14155550123",
+			Locale:      "en-US",
+			Timezone:    "America/New_York",
+			AvatarURL:   "https://example.com/aren.png",
+			Birthdate:   "1990-04-01",
+		}
+		id, err := repo.Create(ctx, entity.User{Name: "Aren", Email: "aren@example.com", Profile: profile})
+		require.NoError(t, err)
+		u, err := repo.GetByID(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, profile, u.Profile)
+
+		// An update without a profile keeps the stored one.
+		require.NoError(t, repo.Update(ctx, entity.User{ID: id, Name: "Aren D", Email: "aren@example.com"}))
+		u, err = repo.GetByID(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, "Aren D", u.Name)
+		assert.Equal(t, profile, u.Profile)
+
+		// One with a profile replaces it; what is left empty is cleared.
+		require.NoError(t, repo.Update(ctx, entity.User{ID: id, Name: "Aren D", Email: "aren@example.com",
+			Profile: &entity.UserProfile{Locale: "de"}}))
+		u, err = repo.GetByID(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, &entity.UserProfile{Locale: "de"}, u.Profile)
+
+		require.NoError(t, repo.Update(ctx, entity.User{ID: id, Name: "Aren D", Email: "aren@example.com",
+			Profile: &entity.UserProfile{}}))
+		u, err = repo.GetByID(ctx, id)
+		require.NoError(t, err)
+		assert.Nil(t, u.Profile)
+	})
+
+	t.Run("IDsAreNotReused", func(t *testing.T) {
+		repo := newRepo()
+
+		id1, err := repo.Create(ctx, entity.User{Name: "Aren", Email: "aren@example.com"})
+		require.NoError(t, err)
+		require.NoError(t, repo.Delete(ctx, id1))
+		id2, err := repo.Create(ctx, entity.User{Name: "Aren", Email: "aren@example.com", ID: id1})
+		require.NoError(t, err)
+		assert.Greater(t, id2, id1)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		repo := newRepo()
+
+		_, err := repo.GetByID(ctx, 404)
+		assert.True(t, helper.HasStatus(err, helper.NotFound), "GetByID: %v", err)
+		err = repo.Update(ctx, entity.User{ID: 404, Name: "Nobody", Email: "nobody@example.com"})
+		assert.True(t, helper.HasStatus(err, helper.NotFound), "Update: %v", err)
+		err = repo.Delete(ctx, 404)
+		assert.True(t, helper.HasStatus(err, helper.NotFound), "Delete: %v", err)
+	})
+
+	t.Run("UniqueEmail", func(t *testing.T) {
+		repo := newRepo()
+
+		_, err := repo.Create(ctx, entity.User{Name: "Aren", Email: "aren@example.com"})
+		require.NoError(t, err)
+		id, err := repo.Create(ctx, entity.User{Name: "Bob", Email: "bob@example.com"})
+		require.NoError(t, err)
+
+		_, err = repo.Create(ctx, entity.User{Name: "Other", Email: "aren@example.com"})
+		assert.True(t, helper.HasStatus(err, helper.AlreadyExists), "Create: %v", err)
+		err = repo.Update(ctx, entity.User{ID: id, Name: "Bob", Email: "aren@example.com"})
+		assert.True(t, helper.HasStatus(err, helper.AlreadyExists), "Update: %v", err)
+	})
+
+	t.Run("Batch", func(t *testing.T) {
+		repo := newRepo()
+
+		ids, err := repo.CreateMany(ctx, []entity.User{
+			{Name: "Aren", Email: "aren@example.com"},
+			{Name: "Bob", Email: "bob@example.com"},
+			{Name: "Carl", Email: "carl@example.com"},
+		})
+		require.NoError(t, err)
+		require.Len(t, ids, 3)
+		assert.Less(t, ids[0], ids[1])
+		assert.Less(t, ids[1], ids[2])
+		u, err := repo.GetByID(ctx, ids[1])
+		require.NoError(t, err)
+		assert.Equal(t, entity.User{ID: ids[1], Name: "Bob", Email: "bob@example.com"}, u)
+
+		// One taken email fails the whole statement.
+		_, err = repo.CreateMany(ctx, []entity.User{
+			{Name: "Dana", Email: "dana@example.com"},
+			{Name: "Other", Email: "bob@example.com"},
+		})
+		assert.True(t, helper.HasStatus(err, helper.AlreadyExists), "CreateMany: %v", err)
+		_, err = repo.CreateMany(ctx, []entity.User{
+			{Name: "Eve", Email: "eve@example.com"},
+			{Name: "Eve", Email: "eve@example.com"},
+		})
+		assert.True(t, helper.HasStatus(err, helper.AlreadyExists), "CreateMany: %v", err)
+		all, err := repo.GetAll(ctx, entity.UserFilter{})
+		require.NoError(t, err)
+		assert.Len(t, all, 3)
+
+		deleted, err := repo.DeleteMany(ctx, []int64{ids[2], 404, ids[0]})
+		require.NoError(t, err)
+		assert.Equal(t, []int64{ids[0], ids[2]}, deleted)
+		all, err = repo.GetAll(ctx, entity.UserFilter{})
+		require.NoError(t, err)
+		assert.Equal(t, []entity.User{u}, all)
+
+		deleted, err = repo.DeleteMany(ctx, []int64{404})
+		require.NoError(t, err)
+		assert.Empty(t, deleted)
+	})
+
+	t.Run("Transaction", func(t *testing.T) {
+		repo := newRepo()
+
+		id, err := repo.Create(ctx, entity.User{Name: "Aren", Email: "aren@example.com"})
+		require.NoError(t, err)
+
+		failed := errors.New("failed")
+		err = repo.RunInTx(ctx, func(ctx context.Context) error {
+			if _, err := repo.CreateMany(ctx, []entity.User{{Name: "Bob", Email: "bob@example.com"}}); err != nil {
+				return err
+			}
+			if err := repo.Update(ctx, entity.User{ID: id, Name: "Aren D", Email: "aren@example.com"}); err != nil {
+				return err
+			}
+			if _, err := repo.DeleteMany(ctx, []int64{id}); err != nil {
+				return err
+			}
+			return failed
+		})
+		assert.ErrorIs(t, err, failed)
+		all, err := repo.GetAll(ctx, entity.UserFilter{})
+		require.NoError(t, err)
+		assert.Equal(t, []entity.User{{ID: id, Name: "Aren", Email: "aren@example.com"}}, all)
+
+		require.NoError(t, repo.RunInTx(ctx, func(ctx context.Context) error {
+			_, err := repo.Create(ctx, entity.User{Name: "Bob", Email: "bob@example.com"})
+			return err
+		}))
+		all, err = repo.GetAll(ctx, entity.UserFilter{})
+		require.NoError(t, err)
+		assert.Len(t, all, 2)
+	})
+
+	t.Run("Filter", func(t *testing.T) {
+		repo := newRepo()
+
+		for _, name := range []string{"Anna", "Bob", "Hannah", "Joanna", "Zed"} {
+			_, err := repo.Create(ctx, entity.User{Name: name, Email: name + "@example.com"})
+			require.NoError(t, err)
+		}
+
+		names := func(filter entity.UserFilter) []string {
+			users, err := repo.GetAll(ctx, filter)
+			require.NoError(t, err)
+			out := []string{}
+			for _, u := range users {
+				out = append(out, u.Name)
+			}
+			return out
+		}
+
+		assert.Equal(t, []string{"Anna", "Bob", "Hannah", "Joanna", "Zed"}, names(entity.UserFilter{}))
+		assert.Equal(t, []string{"Anna", "Hannah", "Joanna"}, names(entity.UserFilter{Name: "ANN"}))
+		assert.Equal(t, []string{"Bob"}, names(entity.UserFilter{Email: "Bob@example.com"}))
+		assert.Equal(t, []string{"Anna", "Zed"}, names(entity.UserFilter{Emails: []string{"Zed@example.com", "nobody@example.com", "Anna@example.com"}}))
+		assert.Equal(t, []string{"Hannah", "Joanna"}, names(entity.UserFilter{Name: "ann", Offset: 1}))
+		assert.Equal(t, []string{"Bob", "Hannah"}, names(entity.UserFilter{Offset: 1, Limit: 2}))
+		assert.Equal(t, []string{}, names(entity.UserFilter{Offset: 10}))
+
+		all, err := repo.GetAll(ctx, entity.UserFilter{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Hannah", "Joanna"}, names(entity.UserFilter{AfterID: all[1].ID, Limit: 2}))
+		assert.Equal(t, []string{}, names(entity.UserFilter{AfterID: all[4].ID}))
+	})
+
+	t.Run("Stream", func(t *testing.T) {
+		repo := newRepo()
+
+		for _, name := range []string{"Anna", "Bob", "Hannah", "Joanna"} {
+			_, err := repo.Create(ctx, entity.User{Name: name, Email: name + "@example.com"})
+			require.NoError(t, err)
+		}
+
+		var got []entity.User
+		err := repo.Stream(ctx, entity.UserFilter{Name: "ann", Limit: 2}, func(u entity.User) error {
+			got = append(got, u)
+			return nil
+		})
+		require.NoError(t, err)
+		want, err := repo.GetAll(ctx, entity.UserFilter{Name: "ann", Limit: 2})
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+
+		stop := errors.New("stop")
+		n := 0
+		err = repo.Stream(ctx, entity.UserFilter{}, func(entity.User) error {
+			n++
+			return stop
+		})
+		assert.ErrorIs(t, err, stop)
+		assert.Equal(t, 1, n)
+
+		// The repository stays usable once a stream stopped early.
+		_, err = repo.Create(ctx, entity.User{Name: "Zed", Email: "zed@example.com"})
+		assert.NoError(t, err)
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		repo := newRepo()
+
+		const n = 20
+		var wg sync.WaitGroup
+		ids := make(chan int64, n)
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				id, err := repo.Create(ctx, entity.User{Name: "User", Email: fmt.Sprintf("u%d@example.com", i)})
+				assert.NoError(t, err)
+				ids <- id
+			}(i)
+		}
+		wg.Wait()
+		close(ids)
+
+		seen := map[int64]bool{}
+		for id := range ids {
+			assert.False(t, seen[id], "duplicate id %d", id)
+			seen[id] = true
+		}
+		all, err := repo.GetAll(ctx, entity.UserFilter{})
+		require.NoError(t, err)
+		assert.Len(t, all, n)
+	})
+}
@@ -0,0 +1,381 @@
+package repository
+
+import (
+	"container/heap"
+	"context"
+	"math"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"user-management/internal/user-management/domain"
+	entity "user-management/internal/user-management/domain/entities"
+)
+
+// fuzzyThreshold is the least similarity a word of a query needs to a
+// word of a user to match it without being part of it: one typo in four
+// letters.
+const fuzzyThreshold = 0.75
+
+// Scores of a word of a query found in a field: as a whole word, at the
+// start of one, anywhere, or only close to one (times its similarity).
+const (
+	scoreWord   = 1.0
+	scorePrefix = 0.9
+	scoreInside = 0.75
+	scoreFuzzy  = 0.7
+)
+
+type fuzzyUserSearcher struct {
+	repo domain.IUserRepository
+}
+
+// NewFuzzyUserSearcher returns a searcher that ranks the users of repo in
+// process. A word of the query matches where a name or email contains it,
+// ignoring case, or else has a word close to it by trigrams or edit
+// distance, so "jhon" finds "john.doe@example.com". Every search reads
+// all users, keeping no more than a page of hits, which suits SQLite, the
+// in-memory repository and tests rather than large tables.
+func NewFuzzyUserSearcher(repo domain.IUserRepository) domain.IUserSearcher {
+	return &fuzzyUserSearcher{repo: repo}
+}
+
+func (s *fuzzyUserSearcher) Search(ctx context.Context, query entity.UserSearchQuery) (entity.UserSearchResult, error) {
+	terms := searchTerms(query.Text)
+	if len(terms) == 0 {
+		return entity.UserSearchResult{}, nil
+	}
+
+	top := &topHits{}
+	if query.Limit > 0 {
+		top.k = query.Offset + query.Limit
+	}
+	total := 0
+	err := s.repo.Stream(ctx, entity.UserFilter{}, func(u entity.User) error {
+		if hit, ok := fuzzyMatch(u, terms); ok {
+			total++
+			top.add(hit)
+		}
+		return nil
+	})
+	if err != nil {
+		return entity.UserSearchResult{}, err
+	}
+	return entity.UserSearchResult{Hits: top.page(query.Offset), Total: total}, nil
+}
+
+// searchTerms are the distinct words of a query, lower-cased.
+func searchTerms(text string) []string {
+	var terms []string
+	for _, t := range strings.Fields(strings.ToLower(text)) {
+		if !slices.Contains(terms, t) {
+			terms = append(terms, t)
+		}
+	}
+	return terms
+}
+
+type searchField struct {
+	name, value string
+}
+
+// searchFields are the fields of a user a search looks at, in the order
+// of their highlights.
+func searchFields(u entity.User) []searchField {
+	return []searchField{{"name", u.Name}, {"email", u.Email}}
+}
+
+// fuzzyMatch scores u against terms, every one of which has to match.
+// The score is the mean of the best score of each term.
+func fuzzyMatch(u entity.User, terms []string) (entity.UserSearchHit, bool) {
+	hit := entity.UserSearchHit{User: u}
+	sum := 0.0
+	for _, t := range terms {
+		best := 0.0
+		for _, f := range searchFields(u) {
+			score, spans := matchTerm(f.value, t)
+			if score == 0 {
+				continue
+			}
+			best = max(best, score)
+			for _, sp := range spans {
+				hit.Highlights = append(hit.Highlights, entity.Highlight{Field: f.name, Start: sp[0], End: sp[1]})
+			}
+		}
+		if best == 0 {
+			return entity.UserSearchHit{}, false
+		}
+		sum += best
+	}
+	hit.Score = math.Round(sum/float64(len(terms))*1000) / 1000
+	hit.Highlights = mergeHighlights(hit.Highlights)
+	return hit, true
+}
+
+// matchTerm scores how well value matches term and returns the byte
+// spans that matched; 0 means not at all.
+func matchTerm(value, term string) (float64, [][2]int) {
+	if spans := foldIndexAll(value, term); len(spans) > 0 {
+		score := scoreInside
+		for _, sp := range spans {
+			switch {
+			case wordStart(value, sp[0]) && wordEnd(value, sp[1]):
+				score = max(score, scoreWord)
+			case wordStart(value, sp[0]):
+				score = max(score, scorePrefix)
+			}
+		}
+		return score, spans
+	}
+
+	best, bestSpan := 0.0, [2]int{}
+	candidates := words(value)
+	if len(candidates) != 1 || candidates[0] != [2]int{0, len(value)} {
+		candidates = append(candidates, [2]int{0, len(value)})
+	}
+	for _, c := range candidates {
+		if sim := similarity(term, strings.ToLower(value[c[0]:c[1]])); sim > best {
+			best, bestSpan = sim, c
+		}
+	}
+	if best < fuzzyThreshold {
+		return 0, nil
+	}
+	return scoreFuzzy * best, [][2]int{bestSpan}
+}
+
+// mergeHighlights orders highlights by field and offset and joins those
+// that overlap.
+func mergeHighlights(hs []entity.Highlight) []entity.Highlight {
+	rank := func(field string) int {
+		return slices.IndexFunc(searchFields(entity.User{}), func(f searchField) bool { return f.name == field })
+	}
+	slices.SortFunc(hs, func(a, b entity.Highlight) int {
+		if r := rank(a.Field) - rank(b.Field); r != 0 {
+			return r
+		}
+		return a.Start - b.Start
+	})
+	out := hs[:0]
+	for _, h := range hs {
+		if n := len(out); n > 0 && out[n-1].Field == h.Field && h.Start <= out[n-1].End {
+			out[n-1].End = max(out[n-1].End, h.End)
+			continue
+		}
+		out = append(out, h)
+	}
+	return out
+}
+
+// foldIndexAll returns the byte spans of the non-overlapping occurrences
+// of sub in s, ignoring case.
+func foldIndexAll(s, sub string) [][2]int {
+	var spans [][2]int
+	for i := 0; i < len(s); {
+		if n, ok := foldPrefix(s[i:], sub); ok && n > 0 {
+			spans = append(spans, [2]int{i, i + n})
+			i += n
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+	}
+	return spans
+}
+
+// foldPrefix reports whether s starts with prefix, ignoring case, and how
+// many bytes of s that prefix takes.
+func foldPrefix(s, prefix string) (int, bool) {
+	n := 0
+	for _, pr := range prefix {
+		if n >= len(s) {
+			return 0, false
+		}
+		r, size := utf8.DecodeRuneInString(s[n:])
+		if !equalFoldRune(r, pr) {
+			return 0, false
+		}
+		n += size
+	}
+	return n, true
+}
+
+func equalFoldRune(a, b rune) bool {
+	if a == b {
+		return true
+	}
+	for f := unicode.SimpleFold(a); f != a; f = unicode.SimpleFold(f) {
+		if f == b {
+			return true
+		}
+	}
+	return false
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func wordStart(s string, i int) bool {
+	r, _ := utf8.DecodeLastRuneInString(s[:i])
+	return i == 0 || !isWordRune(r)
+}
+
+func wordEnd(s string, i int) bool {
+	r, _ := utf8.DecodeRuneInString(s[i:])
+	return i == len(s) || !isWordRune(r)
+}
+
+// words returns the byte spans of the runs of letters and digits of s.
+func words(s string) [][2]int {
+	var spans [][2]int
+	start := -1
+	for i, r := range s {
+		switch {
+		case isWordRune(r) && start < 0:
+			start = i
+		case !isWordRune(r) && start >= 0:
+			spans = append(spans, [2]int{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, [2]int{start, len(s)})
+	}
+	return spans
+}
+
+// similarity of two lower-cased words, from 0 to 1: the better of their
+// trigram similarity and one minus their edit distance per rune.
+func similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	return max(trigramSimilarity(ra, rb), editSimilarity(ra, rb))
+}
+
+// trigramSimilarity is the share of trigrams a and b have in common,
+// padded as pg_trgm does so that short words and word starts count.
+func trigramSimilarity(a, b []rune) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	common := 0
+	for t := range ta {
+		if _, ok := tb[t]; ok {
+			common++
+		}
+	}
+	all := len(ta) + len(tb) - common
+	if all == 0 {
+		return 0
+	}
+	return float64(common) / float64(all)
+}
+
+func trigrams(word []rune) map[string]struct{} {
+	padded := append([]rune("  "), word...)
+	padded = append(padded, ' ')
+	set := make(map[string]struct{}, len(padded))
+	for i := 0; i+3 <= len(padded); i++ {
+		set[string(padded[i:i+3])] = struct{}{}
+	}
+	return set
+}
+
+func editSimilarity(a, b []rune) float64 {
+	longest := max(len(a), len(b))
+	if longest == 0 {
+		return 1
+	}
+	if float64(abs(len(a)-len(b))) > (1-fuzzyThreshold)*float64(longest) {
+		// The distance is at least the difference in length, too far to
+		// reach fuzzyThreshold.
+		return 0
+	}
+	return 1 - float64(editDistance(a, b))/float64(longest)
+}
+
+// editDistance returns the number of runes to insert, delete or replace,
+// or of neighbours to swap, to turn a into b (Levenshtein distance with
+// transpositions, as typos go).
+func editDistance(a, b []rune) int {
+	// Rows i-2, i-1 and i of the distances between prefixes of a and b.
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(b)]
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// topHits keeps the k best hits seen, all of them if k is 0. Its heap has
+// the worst of them on top, to be replaced by a better one.
+type topHits struct {
+	k    int
+	hits []entity.UserSearchHit
+}
+
+// betterHit orders hits best first: by score, then by ID.
+func betterHit(a, b entity.UserSearchHit) bool {
+	if a.Score != b.Score {
+		return a.Score > b.Score
+	}
+	return a.User.ID < b.User.ID
+}
+
+func (t *topHits) Len() int           { return len(t.hits) }
+func (t *topHits) Less(i, j int) bool { return betterHit(t.hits[j], t.hits[i]) }
+func (t *topHits) Swap(i, j int)      { t.hits[i], t.hits[j] = t.hits[j], t.hits[i] }
+func (t *topHits) Push(x any)         { t.hits = append(t.hits, x.(entity.UserSearchHit)) }
+func (t *topHits) Pop() any {
+	last := t.hits[len(t.hits)-1]
+	t.hits = t.hits[:len(t.hits)-1]
+	return last
+}
+
+func (t *topHits) add(hit entity.UserSearchHit) {
+	switch {
+	case t.k == 0 || len(t.hits) < t.k:
+		heap.Push(t, hit)
+	case betterHit(hit, t.hits[0]):
+		t.hits[0] = hit
+		heap.Fix(t, 0)
+	}
+}
+
+// page returns the hits kept, best first, without the first offset.
+func (t *topHits) page(offset int) []entity.UserSearchHit {
+	slices.SortFunc(t.hits, func(a, b entity.UserSearchHit) int {
+		switch {
+		case betterHit(a, b):
+			return -1
+		case betterHit(b, a):
+			return 1
+		}
+		return 0
+	})
+	if offset >= len(t.hits) {
+		return nil
+	}
+	return t.hits[offset:]
+}
@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"user-management/internal/user-management/domain"
+	entity "user-management/internal/user-management/domain/entities"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/dialect/mysqldialect"
+)
+
+var searchTestUsers = []entity.User{
+	{Name: "John Doe", Email: "john.doe@example.com"},
+	{Name: "Johanna Smith", Email: "jo.smith@example.org"},
+	{Name: "Anna Jonsson", Email: "anna@example.net"},
+	{Name: "Bob Stone", Email: "bob@stone.io"},
+}
+
+// seedSearch adds searchTestUsers to repo and returns them with their IDs.
+func seedSearch(t *testing.T, repo domain.IUserRepository) []entity.User {
+	t.Helper()
+	users := make([]entity.User, len(searchTestUsers))
+	for i, u := range searchTestUsers {
+		id, err := repo.Create(context.Background(), u)
+		require.NoError(t, err)
+		u.ID = id
+		users[i] = u
+	}
+	return users
+}
+
+func TestFuzzyUserSearcher_InMemory(t *testing.T) {
+	repo := NewInMemoryUserRepository()
+	testFuzzyUserSearcher(t, NewFuzzyUserSearcher(repo), seedSearch(t, repo))
+}
+
+func TestFuzzyUserSearcher_Bun(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, db *bun.DB) {
+		repo := NewUserRepository(db)
+		testFuzzyUserSearcher(t, NewFuzzyUserSearcher(repo), seedSearch(t, repo))
+	})
+}
+
+func testFuzzyUserSearcher(t *testing.T, s domain.IUserSearcher, users []entity.User) {
+	ctx := context.Background()
+	search := func(text string, limit, offset int) entity.UserSearchResult {
+		t.Helper()
+		res, err := s.Search(ctx, entity.UserSearchQuery{Text: text, Limit: limit, Offset: offset})
+		require.NoError(t, err)
+		return res
+	}
+	ids := func(res entity.UserSearchResult) []int64 {
+		out := []int64{}
+		for _, h := range res.Hits {
+			out = append(out, h.User.ID)
+		}
+		return out
+	}
+
+	// A word start beats a close match, which the closer the better.
+	res := search("jon", 0, 0)
+	assert.Equal(t, 2, res.Total)
+	assert.Equal(t, []int64{users[2].ID, users[0].ID}, ids(res))
+	assert.Equal(t, []entity.Highlight{{Field: "name", Start: 5, End: 8}}, res.Hits[0].Highlights)
+	assert.Equal(t, []entity.Highlight{
+		{Field: "name", Start: 0, End: 4},
+		{Field: "email", Start: 0, End: 4},
+	}, res.Hits[1].Highlights)
+	assert.Greater(t, res.Hits[0].Score, res.Hits[1].Score)
+	// A whole word beats a word start.
+	res = search("jo", 0, 0)
+	assert.Equal(t, []int64{users[1].ID, users[0].ID, users[2].ID}, ids(res))
+	assert.Greater(t, res.Hits[0].Score, res.Hits[1].Score)
+
+	// A misspelled email still finds its user.
+	res = search("jhon.deo@exmaple.com", 0, 0)
+	require.NotEmpty(t, res.Hits)
+	assert.Equal(t, users[0].ID, res.Hits[0].User.ID)
+	assert.Equal(t, []entity.Highlight{{Field: "email", Start: 0, End: 20}}, res.Hits[0].Highlights)
+
+	// Every word has to match, in any field, ignoring case.
+	res = search("SMITH jo", 0, 0)
+	assert.Equal(t, []int64{users[1].ID}, ids(res))
+
+	// Pages keep the ranking and the total.
+	res = search("jo", 1, 1)
+	assert.Equal(t, 3, res.Total)
+	assert.Equal(t, []int64{users[0].ID}, ids(res))
+	res = search("jo", 2, 5)
+	assert.Equal(t, 3, res.Total)
+	assert.Empty(t, res.Hits)
+
+	assert.Zero(t, search("zzzzzz", 0, 0).Total)
+	assert.Zero(t, search("   ", 0, 0).Total)
+}
+
+func TestMatchTerm(t *testing.T) {
+	for _, tt := range []struct {
+		value, term string
+		score       float64
+		spans       [][2]int
+	}{
+		{"John Doe", "doe", scoreWord, [][2]int{{5, 8}}},
+		{"John Doe", "jo", scorePrefix, [][2]int{{0, 2}}},
+		{"Johanna", "hann", scoreInside, [][2]int{{2, 6}}},
+		{"ÅSA Öberg", "åsa", scoreWord, [][2]int{{0, 4}}},
+		{"Hannah Anna", "ann", scorePrefix, [][2]int{{1, 4}, {7, 10}}},
+		{"Bob Stone", "stnoe", scoreFuzzy * 0.8, [][2]int{{4, 9}}},
+		{"Bob Stone", "stn", 0, nil},
+		{"Bob Stone", "xyz", 0, nil},
+	} {
+		score, spans := matchTerm(tt.value, tt.term)
+		assert.InDelta(t, tt.score, score, 0.001, "%s in %s", tt.term, tt.value)
+		assert.Equal(t, tt.spans, spans, "%s in %s", tt.term, tt.value)
+	}
+}
+
+func TestFulltextUserSearcher(t *testing.T) {
+	assert.Equal(t, []string{"john", "doe", "example", "com"}, fulltextWords(`+John "doe"@example.com -a* jo doe`))
+
+	sqldb, err := sql.Open("mysql", "user:pass@tcp(127.0.0.1:1)/db")
+	require.NoError(t, err)
+	var rows []userSearchRow
+	q := fulltextQuery(bun.NewDB(sqldb, mysqldialect.New()), &rows, []string{"john", "doe"},
+		entity.UserSearchQuery{Limit: 10, Offset: 20})
+	assert.Equal(t, "SELECT `user`.`id`, `user`.`name`, `user`.`email`, `user`.`display_name`, `user`.`phone`, "+
+		"`user`.`locale`, `user`.`timezone`, `user`.`avatar_url`, `user`.`birthdate`, "+
+		"MATCH (name, email) AGAINST ('+john* +doe*' IN BOOLEAN MODE) AS score FROM `users` AS `user` "+
+		"WHERE (MATCH (name, email) AGAINST ('+john* +doe*' IN BOOLEAN MODE)) "+
+		"ORDER BY score DESC, `id` LIMIT 10 OFFSET 20", q.String())
+
+	forEachDriver(t, func(t *testing.T, db *bun.DB) {
+		if db.Dialect().Name() != dialect.MySQL {
+			t.Skip("FULLTEXT search needs MySQL")
+		}
+		repo := NewUserRepository(db)
+		users := seedSearch(t, repo)
+		s := NewFulltextUserSearcher(db, nil, NewFuzzyUserSearcher(repo))
+		ctx := context.Background()
+
+		res, err := s.Search(ctx, entity.UserSearchQuery{Text: "Smi jo", Limit: 10})
+		require.NoError(t, err)
+		require.Equal(t, 1, res.Total)
+		assert.Equal(t, users[1].ID, res.Hits[0].User.ID)
+		assert.Equal(t, []entity.Highlight{
+			{Field: "name", Start: 8, End: 11},
+			{Field: "email", Start: 3, End: 6},
+		}, res.Hits[0].Highlights)
+
+		// Misspellings are left to the fallback.
+		res, err = s.Search(ctx, entity.UserSearchQuery{Text: "jhon.deo@exmaple.com", Limit: 10})
+		require.NoError(t, err)
+		require.NotEmpty(t, res.Hits)
+		assert.Equal(t, users[0].ID, res.Hits[0].User.ID)
+	})
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"user-management/internal/user-management/domain"
+	entity "user-management/internal/user-management/domain/entities"
+	"user-management/internal/user-management/infrastructure/model"
+
+	"github.com/uptrace/bun"
+)
+
+type webhookRepo struct {
+	db *bun.DB
+}
+
+func NewWebhookRepository(db *bun.DB) domain.IWebhookRepository {
+	return &webhookRepo{db: db}
+}
+
+func (r *webhookRepo) CreateSubscription(ctx context.Context, sub entity.WebhookSubscription) (int64, error) {
+	s := entity.FromSubscriptionEntity(sub)
+	_, err := r.db.NewInsert().Model(&s).Exec(ctx)
+	return s.ID, err
+}
+
+func (r *webhookRepo) GetSubscriptions(ctx context.Context) ([]entity.WebhookSubscription, error) {
+	return r.selectSubscriptions(ctx, r.db.NewSelect())
+}
+
+func (r *webhookRepo) GetActiveSubscriptions(ctx context.Context) ([]entity.WebhookSubscription, error) {
+	return r.selectSubscriptions(ctx, r.db.NewSelect().Where("active = ?", true))
+}
+
+func (r *webhookRepo) selectSubscriptions(ctx context.Context, q *bun.SelectQuery) ([]entity.WebhookSubscription, error) {
+	var subs []model.WebhookSubscription
+	if err := q.Model(&subs).Order("id").Scan(ctx); err != nil {
+		return nil, err
+	}
+	result := make([]entity.WebhookSubscription, 0, len(subs))
+	for _, s := range subs {
+		result = append(result, entity.ToSubscriptionEntity(s))
+	}
+	return result, nil
+}
+
+func (r *webhookRepo) GetSubscriptionByID(ctx context.Context, id int64) (entity.WebhookSubscription, error) {
+	var sub model.WebhookSubscription
+	err := r.db.NewSelect().Model(&sub).Where("id = ?", id).Scan(ctx)
+	if err != nil {
+		return entity.WebhookSubscription{}, notFound(err)
+	}
+	return entity.ToSubscriptionEntity(sub), nil
+}
+
+func (r *webhookRepo) UpdateSubscription(ctx context.Context, sub entity.WebhookSubscription) error {
+	s := entity.FromSubscriptionEntity(sub)
+	_, err := r.db.NewUpdate().Model(&s).
+		Column("url", "events", "secret", "active").
+		Where("id = ?", s.ID).
+		Exec(ctx)
+	return err
+}
+
+func (r *webhookRepo) DeleteSubscription(ctx context.Context, id int64) error {
+	res, err := r.db.NewDelete().Model(&model.WebhookSubscription{}).Where("id = ?", id).Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return affected(res)
+}
+
+func (r *webhookRepo) CreateDelivery(ctx context.Context, delivery entity.WebhookDelivery) (int64, error) {
+	d := entity.FromDeliveryEntity(delivery)
+	_, err := r.db.NewInsert().Model(&d).Exec(ctx)
+	return d.ID, err
+}
+
+func (r *webhookRepo) GetDeliveries(ctx context.Context, status entity.WebhookDeliveryStatus) ([]entity.WebhookDelivery, error) {
+	q := r.db.NewSelect()
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	return r.selectDeliveries(ctx, q.Order("id DESC"))
+}
+
+func (r *webhookRepo) GetDueDeliveries(ctx context.Context, now time.Time, limit int) ([]entity.WebhookDelivery, error) {
+	q := r.db.NewSelect().
+		Where("status = ?", entity.DeliveryPending).
+		Where("next_attempt_at <= ?", now).
+		Order("next_attempt_at").
+		Limit(limit)
+	return r.selectDeliveries(ctx, q)
+}
+
+func (r *webhookRepo) selectDeliveries(ctx context.Context, q *bun.SelectQuery) ([]entity.WebhookDelivery, error) {
+	var deliveries []model.WebhookDelivery
+	if err := q.Model(&deliveries).Scan(ctx); err != nil {
+		return nil, err
+	}
+	result := make([]entity.WebhookDelivery, 0, len(deliveries))
+	for _, d := range deliveries {
+		result = append(result, entity.ToDeliveryEntity(d))
+	}
+	return result, nil
+}
+
+func (r *webhookRepo) GetDeliveryByID(ctx context.Context, id int64) (entity.WebhookDelivery, error) {
+	var delivery model.WebhookDelivery
+	err := r.db.NewSelect().Model(&delivery).Where("id = ?", id).Scan(ctx)
+	if err != nil {
+		return entity.WebhookDelivery{}, notFound(err)
+	}
+	return entity.ToDeliveryEntity(delivery), nil
+}
+
+func (r *webhookRepo) UpdateDelivery(ctx context.Context, delivery entity.WebhookDelivery) error {
+	d := entity.FromDeliveryEntity(delivery)
+	_, err := r.db.NewUpdate().Model(&d).
+		Column("status", "attempts", "last_error", "last_status_code", "next_attempt_at", "delivered_at").
+		Where("id = ?", d.ID).
+		Exec(ctx)
+	return err
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+	entity "user-management/internal/user-management/domain/entities"
+	"user-management/internal/user-management/helper"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+)
+
+func TestWebhookRepository_Subscriptions(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, db *bun.DB) {
+		ctx := context.Background()
+		repo := NewWebhookRepository(db)
+		now := time.Now().UTC().Truncate(time.Second)
+
+		id, err := repo.CreateSubscription(ctx, entity.WebhookSubscription{
+			URL:       "https://partner.example.com/hook",
+			Events:    []string{"user.created", "user.deleted"},
+			Secret:    "0123456789abcdef",
+			Active:    true,
+			CreatedAt: now,
+		})
+		require.NoError(t, err)
+
+		_, err = repo.CreateSubscription(ctx, entity.WebhookSubscription{
+			URL:       "https://other.example.com/hook",
+			Events:    []string{"user.updated"},
+			Secret:    "0123456789abcdef",
+			CreatedAt: now,
+		})
+		require.NoError(t, err)
+
+		sub, err := repo.GetSubscriptionByID(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"user.created", "user.deleted"}, sub.Events)
+		assert.True(t, sub.Active)
+
+		active, err := repo.GetActiveSubscriptions(ctx)
+		require.NoError(t, err)
+		assert.Len(t, active, 1)
+
+		sub.Active = false
+		require.NoError(t, repo.UpdateSubscription(ctx, sub))
+		active, err = repo.GetActiveSubscriptions(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, active)
+
+		require.NoError(t, repo.DeleteSubscription(ctx, id))
+		_, err = repo.GetSubscriptionByID(ctx, id)
+		assert.True(t, helper.HasStatus(err, helper.NotFound))
+		assert.True(t, helper.HasStatus(repo.DeleteSubscription(ctx, id), helper.NotFound))
+
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err = repo.GetSubscriptions(canceled)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestWebhookRepository_Deliveries(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, db *bun.DB) {
+		ctx := context.Background()
+		repo := NewWebhookRepository(db)
+		now := time.Now().UTC().Truncate(time.Second)
+
+		due, err := repo.CreateDelivery(ctx, entity.WebhookDelivery{
+			SubscriptionID: 1,
+			EventType:      entity.UserCreated,
+			Payload:        []byte(`{"type":"user.created"}`),
+			Status:         entity.DeliveryPending,
+			NextAttemptAt:  now.Add(-time.Minute),
+			CreatedAt:      now,
+		})
+		require.NoError(t, err)
+		_, err = repo.CreateDelivery(ctx, entity.WebhookDelivery{
+			SubscriptionID: 1,
+			EventType:      entity.UserUpdated,
+			Payload:        []byte(`{"type":"user.updated"}`),
+			Status:         entity.DeliveryPending,
+			NextAttemptAt:  now.Add(time.Hour),
+			CreatedAt:      now,
+		})
+		require.NoError(t, err)
+
+		ds, err := repo.GetDueDeliveries(ctx, now, 10)
+		require.NoError(t, err)
+		require.Len(t, ds, 1)
+		assert.Equal(t, due, ds[0].ID)
+		assert.JSONEq(t, `{"type":"user.created"}`, string(ds[0].Payload))
+
+		d := ds[0]
+		d.Status = entity.DeliverySucceeded
+		d.Attempts = 1
+		d.LastStatusCode = 200
+		d.DeliveredAt = &now
+		require.NoError(t, repo.UpdateDelivery(ctx, d))
+
+		got, err := repo.GetDeliveryByID(ctx, due)
+		require.NoError(t, err)
+		assert.Equal(t, entity.DeliverySucceeded, got.Status)
+		require.NotNil(t, got.DeliveredAt)
+		assert.True(t, got.DeliveredAt.Equal(now))
+
+		succeeded, err := repo.GetDeliveries(ctx, entity.DeliverySucceeded)
+		require.NoError(t, err)
+		assert.Len(t, succeeded, 1)
+		all, err := repo.GetDeliveries(ctx, "")
+		require.NoError(t, err)
+		assert.Len(t, all, 2)
+
+		_, err = repo.GetDeliveryByID(ctx, 999)
+		assert.True(t, helper.HasStatus(err, helper.NotFound))
+	})
+}
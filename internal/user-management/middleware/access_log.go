@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"user-management/internal/user-management/helper"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog"
+)
+
+// AccessLog logs a line per request with the method, the template of the
+// router's route it matched, the status, latency, response size and client
+// IP, to the logger of the request context. Server errors are logged as
+// errors and client errors as warnings. Add it with Chain after RequestID,
+// see there.
+func AccessLog(router *mux.Router) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := &statusWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r)
+
+			status := sw.status()
+			logger := zerolog.Ctx(r.Context())
+			var e *zerolog.Event
+			switch {
+			case status >= http.StatusInternalServerError:
+				e = logger.Error()
+			case status >= http.StatusBadRequest:
+				e = logger.Warn()
+			default:
+				e = logger.Info()
+			}
+			e.Str("method", r.Method).
+				Str("route", routeTemplate(router, r)).
+				Str("path", r.URL.Path).
+				Int("status", status).
+				Dur("latency", time.Since(start)).
+				Int64("bytes", sw.bytes).
+				Str("client_ip", helper.ClientIP(r)).
+				Msg("request")
+		})
+	}
+}
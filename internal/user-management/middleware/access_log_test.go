@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/users/{id:[0-9]+}", func(w http.ResponseWriter, r *http.Request) {
+		zerolog.Ctx(r.Context()).Info().Msg("handling")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":"not found"}`))
+	})
+	h := Chain(router, RequestID, AccessLog(router))
+
+	r := httptest.NewRequest("GET", "/users/42", nil)
+	r.Header.Set(RequestIDHeader, "req-1")
+	r.RemoteAddr = "203.0.113.9:51234"
+	h.ServeHTTP(httptest.NewRecorder(), r.WithContext(logger.WithContext(r.Context())))
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+
+	var handler, access map[string]any
+	require.NoError(t, json.Unmarshal(lines[0], &handler))
+	require.NoError(t, json.Unmarshal(lines[1], &access))
+	assert.Equal(t, "req-1", handler["request_id"], "handler logs carry the request ID")
+
+	assert.Equal(t, "warn", access["level"])
+	assert.Equal(t, "request", access["message"])
+	assert.Equal(t, "req-1", access["request_id"])
+	assert.Equal(t, "GET", access["method"])
+	assert.Equal(t, "/users/{id:[0-9]+}", access["route"])
+	assert.Equal(t, "/users/42", access["path"])
+	assert.Equal(t, 404.0, access["status"])
+	assert.Equal(t, 21.0, access["bytes"])
+	assert.Equal(t, "203.0.113.9", access["client_ip"])
+	assert.Contains(t, access, "latency")
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"user-management/internal/user-management/helper"
+)
+
+// MaxBytes limits request bodies to n bytes, 0 for no limit. A request
+// announcing a larger Content-Length gets a 413 problem response right
+// away; reading past n of any other body fails with *http.MaxBytesError,
+// which handlers answer with 413 as well.
+func MaxBytes(n int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if n <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > n {
+				p := helper.NewProblem(http.StatusRequestEntityTooLarge,
+					fmt.Sprintf("request body must not be larger than %d bytes", n))
+				p.RequestID = RequestIDFromContext(r.Context())
+				helper.WriteProblem(w, p)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, n)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"user-management/internal/user-management/helper"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxBytes(t *testing.T) {
+	var readErr error
+	h := MaxBytes(16)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, readErr = io.ReadAll(r.Body)
+	}))
+
+	// Small enough.
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/users", strings.NewReader(`{"name":"Ann"}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, readErr)
+
+	// Announced as too large: rejected before the handler runs.
+	readErr = nil
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/users", strings.NewReader(`{"name":"Annabelle Smith"}`)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Equal(t, helper.ProblemContentType, w.Header().Get("Content-Type"))
+	var p helper.Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+	assert.Equal(t, "request body must not be larger than 16 bytes", p.Detail)
+
+	// Of unknown length: reading past the limit fails.
+	r := httptest.NewRequest("POST", "/users", io.NopCloser(strings.NewReader(`{"name":"Annabelle Smith"}`)))
+	r.ContentLength = -1
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	var tooLarge *http.MaxBytesError
+	assert.True(t, errors.As(readErr, &tooLarge), "got %v", readErr)
+}
+
+func TestMaxBytesUnlimited(t *testing.T) {
+	next := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	h := MaxBytes(0)(next)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/users", strings.NewReader(strings.Repeat("x", 1<<20))))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
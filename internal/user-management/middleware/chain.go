@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// Chain wraps h with middlewares, the first one outermost.
+//
+// Use it rather than router.Use for middleware that must also see requests
+// matching no route, such as Metrics and Tracing.
+func Chain(h http.Handler, middlewares ...func(http.Handler) http.Handler) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// unmatchedRoute labels requests that match no route, so that scanning for
+// random paths cannot create a metric series or span name per path.
+const unmatchedRoute = "unmatched"
+
+// routeTemplate returns the template of the router's route r matches,
+// e.g. /users/{id:[0-9]+}, or unmatchedRoute.
+func routeTemplate(router *mux.Router, r *http.Request) string {
+	var match mux.RouteMatch
+	if router.Match(r, &match) && match.Route != nil {
+		if tpl, err := match.Route.GetPathTemplate(); err == nil {
+			return tpl
+		}
+	}
+	return unmatchedRoute
+}
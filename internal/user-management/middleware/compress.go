@@ -0,0 +1,229 @@
+package middleware
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+
+	"user-management/internal/user-management/helper"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Compress compresses response bodies with the encoding the client prefers
+// of br, gzip and deflate, going by Accept-Encoding; on a tie in that
+// order. Bodies smaller than minSize bytes, and bodies
+// that do not compress well or are already encoded, are sent as they are,
+// and so are event streams, which must not sit in a compressor's buffer.
+// Add it with Chain, inside Recover.
+func Compress(minSize int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			c := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+			if c == nil || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{ResponseWriter: w, c: c, minSize: minSize}
+			defer func() {
+				if v := recover(); v != nil {
+					// What is buffered is dropped; Recover answers.
+					cw.release()
+					panic(v)
+				}
+			}()
+			next.ServeHTTP(cw, r)
+			cw.close()
+		})
+	}
+}
+
+type resetWriter interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+type compressor struct {
+	name string
+	pool sync.Pool
+}
+
+var compressors = []*compressor{
+	{name: "br", pool: sync.Pool{New: func() any {
+		return brotli.NewWriterLevel(nil, brotli.DefaultCompression)
+	}}},
+	{name: "gzip", pool: sync.Pool{New: func() any {
+		return gzip.NewWriter(nil)
+	}}},
+	{name: "deflate", pool: sync.Pool{New: func() any {
+		w, _ := flate.NewWriter(nil, flate.DefaultCompression)
+		return w
+	}}},
+}
+
+// negotiateEncoding returns the compressor for the encoding an
+// Accept-Encoding header prefers, the first of compressors on a tie, or nil
+// for none.
+func negotiateEncoding(header string) *compressor {
+	accepted := helper.ParseAccept(header)
+	quality := func(name string) float64 {
+		for _, a := range accepted {
+			if a.Value == name {
+				return a.Q
+			}
+		}
+		for _, a := range accepted {
+			if a.Value == "*" {
+				return a.Q
+			}
+		}
+		return 0
+	}
+
+	var best *compressor
+	var bestQ float64
+	for _, c := range compressors {
+		if q := quality(c.name); q > bestQ {
+			best, bestQ = c, q
+		}
+	}
+	return best
+}
+
+// compressible reports whether bodies of the media type are worth
+// compressing.
+func compressible(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "text/event-stream":
+		return false
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+json"), strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/x-ndjson", "application/xml", "application/javascript",
+		"application/msgpack", "application/x-msgpack":
+		return true
+	}
+	return false
+}
+
+// compressWriter holds back the start of the body until it knows whether
+// to compress it: when minSize bytes are in, the handler flushes or it is
+// done.
+type compressWriter struct {
+	http.ResponseWriter
+	c       *compressor
+	minSize int
+	status  int
+	buf     []byte
+	decided bool
+	w       resetWriter // nil unless compressing
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.decided || code < http.StatusOK {
+		cw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if cw.status == 0 {
+		cw.status = code
+	}
+	if code == http.StatusNoContent || code == http.StatusNotModified {
+		_ = cw.decide(false)
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.decided {
+		cw.buf = append(cw.buf, b...)
+		if len(cw.buf) < cw.minSize {
+			return len(b), nil
+		}
+		if err := cw.decide(true); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if cw.w != nil {
+		return cw.w.Write(b)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		_ = cw.decide(len(cw.buf) >= cw.minSize)
+	}
+	if cw.w != nil {
+		_ = cw.w.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// decide writes the header, compressed if large and the content allows,
+// and what is buffered.
+func (cw *compressWriter) decide(large bool) error {
+	cw.decided = true
+	h := cw.Header()
+	if h.Get("Content-Type") == "" && len(cw.buf) > 0 {
+		// As net/http would, before Content-Encoding hides the content.
+		h.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+	if large && h.Get("Content-Encoding") == "" && compressible(h.Get("Content-Type")) {
+		h.Set("Content-Encoding", cw.c.name)
+		h.Del("Content-Length")
+		cw.w = cw.c.pool.Get().(resetWriter)
+		cw.w.Reset(cw.ResponseWriter)
+	}
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if cw.w != nil {
+		_, err = cw.w.Write(buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// close sends what is left once the handler is done.
+func (cw *compressWriter) close() {
+	if !cw.decided {
+		_ = cw.decide(false)
+	}
+	if cw.w != nil {
+		_ = cw.w.Close()
+		cw.release()
+	}
+}
+
+func (cw *compressWriter) release() {
+	if cw.w != nil {
+		cw.w.Reset(nil)
+		cw.c.pool.Put(cw.w)
+		cw.w = nil
+	}
+}
@@ -0,0 +1,152 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var largeJSON = `[` + strings.Repeat(`{"id":1,"name":"Aren","email":"aren@example.com"},`, 100) + `{}]`
+
+func compressRequest(h http.Handler, acceptEncoding string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", "/users", nil)
+	if acceptEncoding != "" {
+		r.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func decompress(t *testing.T, encoding string, body []byte) string {
+	t.Helper()
+	var r io.Reader
+	switch encoding {
+	case "gzip":
+		gr, err := gzip.NewReader(bytes.NewReader(body))
+		require.NoError(t, err)
+		r = gr
+	case "deflate":
+		r = flate.NewReader(bytes.NewReader(body))
+	case "br":
+		r = brotli.NewReader(bytes.NewReader(body))
+	default:
+		return string(body)
+	}
+	b, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return string(b)
+}
+
+func TestCompress(t *testing.T) {
+	h := Compress(1024)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		// In pieces, the first smaller than the threshold.
+		w.Write([]byte(largeJSON[:10]))
+		w.Write([]byte(largeJSON[10:]))
+	}))
+
+	for acceptEncoding, want := range map[string]string{
+		"gzip":                     "gzip",
+		"gzip, deflate, br":        "br",
+		"deflate":                  "deflate",
+		"br;q=0.5, gzip":           "gzip",
+		"*":                        "br",
+		"*;q=0.5, br;q=0":          "gzip",
+		"identity":                 "",
+		"":                         "",
+		"compress, gzip;q=0":       "",
+		"GZIP;Q=0.8, identity;q=1": "gzip",
+	} {
+		w := compressRequest(h, acceptEncoding)
+		assert.Equal(t, http.StatusOK, w.Code, acceptEncoding)
+		assert.Equal(t, want, w.Header().Get("Content-Encoding"), acceptEncoding)
+		assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"), acceptEncoding)
+		assert.Equal(t, largeJSON, decompress(t, want, w.Body.Bytes()), acceptEncoding)
+		if want != "" {
+			assert.Less(t, w.Body.Len(), len(largeJSON)/4, acceptEncoding)
+		}
+	}
+}
+
+func TestCompressSkips(t *testing.T) {
+	for name, handler := range map[string]http.HandlerFunc{
+		"small": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"id":1}`))
+		},
+		"image": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(bytes.Repeat([]byte{0x89}, 4096))
+		},
+		"already encoded": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Content-Encoding", "zstd")
+			w.Write([]byte(largeJSON))
+		},
+		"no content": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		},
+	} {
+		w := compressRequest(Compress(1024)(handler), "gzip")
+		assert.NotEqual(t, "gzip", w.Header().Get("Content-Encoding"), name)
+	}
+}
+
+func TestCompressKeepsStatusAndSniffsType(t *testing.T) {
+	h := Compress(16)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("<html><body>" + strings.Repeat("hello ", 100) + "</body></html>"))
+	}))
+	w := compressRequest(h, "gzip")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+}
+
+func TestCompressStreamsEvents(t *testing.T) {
+	h := Compress(16)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("event: user.created\ndata: {}\n\n"))
+		w.(http.Flusher).Flush()
+	}))
+	w := compressRequest(h, "gzip")
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.True(t, w.Flushed)
+	assert.Equal(t, "event: user.created\ndata: {}\n\n", w.Body.String())
+}
+
+func TestCompressFlushesCompressedStream(t *testing.T) {
+	h := Compress(1024)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(largeJSON))
+		w.(http.Flusher).Flush()
+	}))
+	w := compressRequest(h, "gzip")
+	assert.True(t, w.Flushed)
+	assert.Equal(t, largeJSON, decompress(t, "gzip", w.Body.Bytes()))
+}
+
+func TestCompressLeavesPanicsToRecover(t *testing.T) {
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[{"id":1}`))
+		panic("boom")
+	}), Recover, Compress(1024))
+
+	w := compressRequest(h, "gzip")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	var p map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p), "only the problem is sent: %s", w.Body)
+}
@@ -0,0 +1,179 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// CORSOptions configures CORS, see there.
+type CORSOptions struct {
+	// AllowedOrigins are exact origins such as https://admin.example.com,
+	// origins with a wildcard subdomain such as https://*.example.com, or
+	// * for any.
+	AllowedOrigins []string
+	// AllowedMethods are offered to preflights where a route serves them.
+	AllowedMethods []string
+	// AllowedHeaders are the request headers allowed, * for any.
+	AllowedHeaders []string
+	// ExposedHeaders are the response headers scripts may read.
+	ExposedHeaders   []string
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight response.
+	MaxAge time.Duration
+}
+
+// CORS lets scripts on the allowed origins call the API. It answers
+// preflight requests itself, for every route of router, with the allowed
+// methods that route serves, so no OPTIONS routes are needed; requests from
+// other origins, same-origin ones included, pass through untouched. With
+// no allowed origins it does nothing. Add it with Chain, see there.
+func CORS(router *mux.Router, opts CORSOptions) func(http.Handler) http.Handler {
+	c := &cors{
+		router:         router,
+		methods:        opts.AllowedMethods,
+		headers:        strings.Join(opts.AllowedHeaders, ", "),
+		exposedHeaders: strings.Join(opts.ExposedHeaders, ", "),
+		credentials:    opts.AllowCredentials,
+	}
+	for _, o := range opts.AllowedOrigins {
+		switch {
+		case o == "*":
+			c.anyOrigin = true
+		case strings.Contains(o, "://*."):
+			scheme, host, _ := strings.Cut(o, "://*.")
+			c.wildcards = append(c.wildcards, [2]string{strings.ToLower(scheme) + "://", "." + strings.ToLower(host)})
+		default:
+			c.origins = append(c.origins, strings.ToLower(o))
+		}
+	}
+	for _, h := range opts.AllowedHeaders {
+		if h == "*" {
+			c.anyHeader = true
+		}
+	}
+	if opts.MaxAge > 0 {
+		c.maxAge = strconv.Itoa(int(opts.MaxAge / time.Second))
+	}
+
+	return func(next http.Handler) http.Handler {
+		if len(opts.AllowedOrigins) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c.serve(next, w, r)
+		})
+	}
+}
+
+type cors struct {
+	router         *mux.Router
+	anyOrigin      bool
+	origins        []string
+	wildcards      [][2]string // scheme://, .domain
+	methods        []string
+	anyHeader      bool
+	headers        string
+	exposedHeaders string
+	credentials    bool
+	maxAge         string
+}
+
+func (c *cors) serve(next http.Handler, w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+	h := w.Header()
+	h.Add("Vary", "Origin")
+	if preflight {
+		h.Add("Vary", "Access-Control-Request-Method")
+		h.Add("Vary", "Access-Control-Request-Headers")
+	}
+	if origin == "" || !c.allowed(origin) {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	c.setOrigin(h, origin)
+	if !preflight {
+		if c.exposedHeaders != "" {
+			h.Set("Access-Control-Expose-Headers", c.exposedHeaders)
+		}
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	methods := c.routeMethods(r)
+	if len(methods) == 0 {
+		// No route for the path: let the router say so.
+		next.ServeHTTP(w, r)
+		return
+	}
+	h.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+	if c.anyHeader {
+		if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+			h.Set("Access-Control-Allow-Headers", requested)
+		}
+	} else if c.headers != "" {
+		h.Set("Access-Control-Allow-Headers", c.headers)
+	}
+	if c.maxAge != "" {
+		h.Set("Access-Control-Max-Age", c.maxAge)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *cors) setOrigin(h http.Header, origin string) {
+	if c.anyOrigin && !c.credentials {
+		h.Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	// Browsers refuse * with credentials; name the origin instead.
+	h.Set("Access-Control-Allow-Origin", origin)
+	if c.credentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func (c *cors) allowed(origin string) bool {
+	if c.anyOrigin {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	for _, o := range c.origins {
+		if o == origin {
+			return true
+		}
+	}
+	for _, w := range c.wildcards {
+		scheme, domain := w[0], w[1]
+		host, ok := strings.CutPrefix(origin, scheme)
+		if !ok {
+			continue
+		}
+		// The subdomain, one or more labels, is all that may differ.
+		sub, ok := strings.CutSuffix(host, domain)
+		if ok && sub != "" && !strings.ContainsAny(sub, ":/?#@") {
+			return true
+		}
+	}
+	return false
+}
+
+// routeMethods returns the allowed methods a route of the router serves
+// at the path of r.
+func (c *cors) routeMethods(r *http.Request) []string {
+	var methods []string
+	for _, m := range c.methods {
+		probe := r.Clone(r.Context())
+		probe.Method = m
+		var match mux.RouteMatch
+		if c.router.Match(probe, &match) && match.MatchErr == nil {
+			methods = append(methods, m)
+		}
+	}
+	return methods
+}
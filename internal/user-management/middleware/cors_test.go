@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func corsRouter() *mux.Router {
+	ok := func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) }
+	router := mux.NewRouter()
+	router.HandleFunc("/users", ok).Methods("GET", "POST")
+	router.HandleFunc("/users/{id:[0-9]+}", ok).Methods("GET", "PUT", "DELETE")
+	router.PathPrefix("/swagger/").HandlerFunc(ok)
+	return router
+}
+
+func corsRequest(h http.Handler, method, path, origin string, headers ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, nil)
+	if origin != "" {
+		r.Header.Set("Origin", origin)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestCORSPreflight(t *testing.T) {
+	router := corsRouter()
+	h := Chain(router, CORS(router, CORSOptions{
+		AllowedOrigins: []string{"https://admin.example.com"},
+		AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+		AllowedHeaders: []string{"Content-Type", "X-Request-ID"},
+		ExposedHeaders: []string{"Location"},
+		MaxAge:         10 * time.Minute,
+	}))
+
+	w := corsRequest(h, "OPTIONS", "/users/7", "https://admin.example.com",
+		"Access-Control-Request-Method", "PUT",
+		"Access-Control-Request-Headers", "content-type")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "https://admin.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "GET, PUT, DELETE", w.Header().Get("Access-Control-Allow-Methods"), "only what the route serves")
+	assert.Equal(t, "Content-Type, X-Request-ID", w.Header().Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"}, w.Header().Values("Vary"))
+
+	w = corsRequest(h, "OPTIONS", "/users", "https://admin.example.com", "Access-Control-Request-Method", "POST")
+	assert.Equal(t, "GET, POST", w.Header().Get("Access-Control-Allow-Methods"))
+
+	// A path no route serves.
+	w = corsRequest(h, "OPTIONS", "/nope", "https://admin.example.com", "Access-Control-Request-Method", "GET")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Another origin gets no CORS headers; the router answers.
+	w = corsRequest(h, "OPTIONS", "/users", "https://evil.example.net", "Access-Control-Request-Method", "POST")
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestCORSActualRequest(t *testing.T) {
+	router := corsRouter()
+	h := Chain(router, CORS(router, CORSOptions{
+		AllowedOrigins:   []string{"https://*.example.com"},
+		AllowedMethods:   []string{"GET"},
+		ExposedHeaders:   []string{"Location", "X-Request-ID"},
+		AllowCredentials: true,
+	}))
+
+	w := corsRequest(h, "GET", "/users", "https://admin.example.com")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "https://admin.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, "Location, X-Request-ID", w.Header().Get("Access-Control-Expose-Headers"))
+	assert.Equal(t, "Origin", w.Header().Get("Vary"))
+
+	// Same-origin and non-browser requests, e.g. the Swagger UI and curl.
+	for _, origin := range []string{"", "http://localhost:8087"} {
+		w = corsRequest(h, "GET", "/swagger/index.html", origin)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "ok", w.Body.String())
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	}
+}
+
+func TestCORSOrigins(t *testing.T) {
+	c := CORS(corsRouter(), CORSOptions{AllowedOrigins: []string{
+		"https://admin.example.com", "https://*.apps.example.org", "http://*.localhost:3000",
+	}})
+	h := c(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	for origin, allowed := range map[string]bool{
+		"https://admin.example.com":        true,
+		"https://ADMIN.example.com":        true,
+		"http://admin.example.com":         false,
+		"https://admin.example.com:8443":   false,
+		"https://a.apps.example.org":       true,
+		"https://a.b.apps.example.org":     true,
+		"https://apps.example.org":         false,
+		"https://evilapps.example.org":     false,
+		"https://apps.example.org.evil.io": false,
+		"http://ui.localhost:3000":         true,
+		"http://ui.localhost:4000":         false,
+		"null":                             false,
+	} {
+		w := corsRequest(h, "GET", "/users", origin)
+		assert.Equal(t, allowed, w.Header().Get("Access-Control-Allow-Origin") != "", origin)
+	}
+
+	anyOrigin := CORS(corsRouter(), CORSOptions{AllowedOrigins: []string{"*"}})(http.NotFoundHandler())
+	w := corsRequest(anyOrigin, "GET", "/users", "https://anywhere.example")
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestCORSOff(t *testing.T) {
+	next := http.NotFoundHandler()
+	h := CORS(corsRouter(), CORSOptions{})(next)
+	w := corsRequest(h, "GET", "/users", "https://admin.example.com")
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, w.Header().Get("Vary"))
+}
@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics records the rate, errors and duration of requests, labelled by
+// the template of the router's route they match (e.g. /users/{id:[0-9]+}),
+// method and status code, and registers the metrics with reg. Add it with
+// Chain, see there.
+func Metrics(reg prometheus.Registerer, router *mux.Router) func(http.Handler) http.Handler {
+	m := &httpMetrics{
+		router: router,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "HTTP requests by route template, method and status code.",
+		}, []string{"route", "method", "code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Duration of HTTP requests by route template and method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "http_requests_in_flight",
+			Help: "HTTP requests being served.",
+		}),
+	}
+	reg.MustRegister(m.requests, m.duration, m.inFlight)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			m.serve(next, w, r)
+		})
+	}
+}
+
+type httpMetrics struct {
+	router   *mux.Router
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	inFlight prometheus.Gauge
+}
+
+func (m *httpMetrics) serve(next http.Handler, w http.ResponseWriter, r *http.Request) {
+	m.inFlight.Inc()
+	defer m.inFlight.Dec()
+
+	route := routeTemplate(m.router, r)
+	method := metricMethod(r.Method)
+
+	start := time.Now()
+	sw := &statusWriter{ResponseWriter: w}
+	next.ServeHTTP(sw, r)
+
+	m.duration.WithLabelValues(route, method).Observe(time.Since(start).Seconds())
+	m.requests.WithLabelValues(route, method, strconv.Itoa(sw.status())).Inc()
+}
+
+// metricMethod keeps made-up methods from creating series.
+func metricMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodOptions:
+		return method
+	default:
+		return "OTHER"
+	}
+}
+
+// statusWriter remembers the status code a handler wrote and counts the
+// bytes of the body. It passes Flush through for event streams and unwraps
+// for http.ResponseController.
+type statusWriter struct {
+	http.ResponseWriter
+	code  int
+	bytes int64
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *statusWriter) Flush() {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *statusWriter) status() int {
+	if w.code == 0 {
+		return http.StatusOK
+	}
+	return w.code
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	router := mux.NewRouter()
+	router.HandleFunc("/users/{id:[0-9]+}", func(w http.ResponseWriter, r *http.Request) {
+		if mux.Vars(r)["id"] == "0" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("{}"))
+	}).Methods("GET")
+	router.HandleFunc("/users/events", func(w http.ResponseWriter, r *http.Request) {
+		_, ok := w.(http.Flusher)
+		assert.True(t, ok, "event streams need to flush")
+	}).Methods("GET")
+
+	reg := prometheus.NewRegistry()
+	h := Chain(router, Metrics(reg, router))
+
+	for _, r := range []struct{ method, path string }{
+		{"GET", "/users/1"},
+		{"GET", "/users/2"},
+		{"GET", "/users/0"},
+		{"GET", "/users/events"},
+		{"GET", "/wp-admin.php"},
+		{"PROPFIND", "/users/1"},
+	} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(r.method, r.path, nil))
+	}
+
+	err := testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP http_requests_total HTTP requests by route template, method and status code.
+# TYPE http_requests_total counter
+http_requests_total{code="200",method="GET",route="/users/events"} 1
+http_requests_total{code="200",method="GET",route="/users/{id:[0-9]+}"} 2
+http_requests_total{code="404",method="GET",route="/users/{id:[0-9]+}"} 1
+http_requests_total{code="404",method="GET",route="unmatched"} 1
+http_requests_total{code="405",method="OTHER",route="unmatched"} 1
+`), "http_requests_total")
+	require.NoError(t, err)
+
+	assert.Equal(t, 4, testutil.CollectAndCount(reg, "http_request_duration_seconds"))
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP http_requests_in_flight HTTP requests being served.
+# TYPE http_requests_in_flight gauge
+http_requests_in_flight 0
+`), "http_requests_in_flight"))
+}
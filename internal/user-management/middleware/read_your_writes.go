@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+	"time"
+
+	"user-management/internal/user-management/helper"
+	"user-management/internal/user-management/infrastructure/repository"
+)
+
+// ClientIDHeader identifies a client across requests for read-your-writes.
+// Clients that do not send it are told apart by IP address.
+const ClientIDHeader = "X-Client-ID"
+
+// maxStickyClients caps how many clients ReadYourWrites remembers. Client
+// IDs are the client's to choose, so past it the client that wrote longest
+// ago is forgotten and reads from replicas again.
+const maxStickyClients = 10000
+
+// ReadYourWrites pins a client's reads to the primary database for window
+// after the same client sent a write request (POST, PUT, PATCH or DELETE),
+// so it does not read stale data from a lagging replica.
+func ReadYourWrites(window time.Duration) func(http.Handler) http.Handler {
+	return newStickiness(window, maxStickyClients).middleware
+}
+
+type stickiness struct {
+	window time.Duration
+	max    int
+	now    func() time.Time
+
+	mu sync.Mutex
+	// writes holds a *lastWrite per client, oldest first, and clients
+	// finds them by key.
+	writes  *list.List
+	clients map[string]*list.Element
+}
+
+type lastWrite struct {
+	key string
+	at  time.Time
+}
+
+func newStickiness(window time.Duration, max int) *stickiness {
+	return &stickiness{
+		window:  window,
+		max:     max,
+		now:     time.Now,
+		writes:  list.New(),
+		clients: make(map[string]*list.Element),
+	}
+}
+
+func (s *stickiness) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(ClientIDHeader)
+		if key == "" {
+			key = helper.ClientIP(r)
+		}
+
+		if s.sticky(key) {
+			r = r.WithContext(repository.WithPrimary(r.Context()))
+		}
+
+		next.ServeHTTP(w, r)
+
+		switch r.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+			s.wrote(key)
+		}
+	})
+}
+
+func (s *stickiness) sticky(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.clients[key]
+	if ok && s.now().Sub(e.Value.(*lastWrite).at) >= s.window {
+		s.remove(e)
+		return false
+	}
+	return ok
+}
+
+func (s *stickiness) wrote(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if e, ok := s.clients[key]; ok {
+		e.Value.(*lastWrite).at = now
+		s.writes.MoveToBack(e)
+	} else {
+		s.clients[key] = s.writes.PushBack(&lastWrite{key: key, at: now})
+	}
+
+	// Clients that never come back would otherwise stay forever.
+	for e := s.writes.Front(); e != nil; e = s.writes.Front() {
+		if s.writes.Len() <= s.max && now.Sub(e.Value.(*lastWrite).at) < s.window {
+			break
+		}
+		s.remove(e)
+	}
+}
+
+func (s *stickiness) remove(e *list.Element) {
+	s.writes.Remove(e)
+	delete(s.clients, e.Value.(*lastWrite).key)
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"user-management/internal/user-management/infrastructure/repository"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadYourWrites(t *testing.T) {
+	now := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	s := newStickiness(5*time.Second, 2)
+	s.now = func() time.Time { return now }
+
+	var primary bool
+	h := s.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		primary = repository.PrimaryOnly(r.Context())
+	}))
+
+	do := func(method, client string) bool {
+		r := httptest.NewRequest(method, "/users", nil)
+		r.Header.Set(ClientIDHeader, client)
+		h.ServeHTTP(httptest.NewRecorder(), r)
+		return primary
+	}
+
+	assert.False(t, do(http.MethodGet, "a"))
+	do(http.MethodPost, "a")
+	assert.True(t, do(http.MethodGet, "a"), "reads right after a write go to the primary")
+	assert.False(t, do(http.MethodGet, "b"), "other clients are not affected")
+
+	now = now.Add(5 * time.Second)
+	assert.False(t, do(http.MethodGet, "a"), "stickiness ends after the window")
+	assert.Zero(t, s.writes.Len())
+
+	// Past the cap, the client that wrote longest ago is forgotten.
+	for i, client := range []string{"a", "b", "a", "c"} {
+		now = now.Add(time.Duration(i) * time.Millisecond)
+		do(http.MethodPost, client)
+	}
+	assert.Equal(t, 2, s.writes.Len())
+	assert.True(t, do(http.MethodGet, "a"))
+	assert.False(t, do(http.MethodGet, "b"))
+	assert.True(t, do(http.MethodGet, "c"))
+
+	// Expired clients go at the next write, whoever sends it.
+	now = now.Add(5 * time.Second)
+	do(http.MethodPost, "d")
+	assert.Equal(t, 1, s.writes.Len())
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"user-management/internal/user-management/helper"
+
+	"github.com/rs/zerolog"
+)
+
+// Recover turns a panicking handler into a 500 problem response and logs
+// the panic with its stack, instead of the server dropping the connection.
+// A panic with http.ErrAbortHandler is passed on: it is how handlers abort
+// a response on purpose. Add it inside Metrics and AccessLog so they see
+// the 500.
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := &statusWriter{ResponseWriter: w}
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+
+			zerolog.Ctx(r.Context()).Error().
+				Str("panic", fmt.Sprint(v)).
+				Str("stack", string(debug.Stack())).
+				Str("method", r.Method).
+				Str("path", r.URL.Path).
+				Msg("handler panicked")
+
+			if sw.code != 0 {
+				// Part of the response is out; all that is left is to cut
+				// it short.
+				panic(http.ErrAbortHandler)
+			}
+			p := helper.NewProblem(http.StatusInternalServerError, "")
+			p.RequestID = RequestIDFromContext(r.Context())
+			helper.WriteProblem(sw, p)
+		}()
+		next.ServeHTTP(sw, r)
+	})
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"user-management/internal/user-management/helper"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecover(t *testing.T) {
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf)
+
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var m map[string]int
+		m["boom"]++
+	}), RequestID, Recover)
+
+	r := httptest.NewRequest("GET", "/users/1", nil)
+	r.Header.Set(RequestIDHeader, "req-1")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r.WithContext(logger.WithContext(r.Context())))
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, helper.ProblemContentType, w.Header().Get("Content-Type"))
+	var p helper.Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+	assert.Equal(t, helper.Problem{
+		Type:      "about:blank",
+		Title:     "Internal Server Error",
+		Status:    http.StatusInternalServerError,
+		RequestID: "req-1",
+	}, p)
+	assert.NotContains(t, w.Body.String(), "nil map", "the panic is not shown to clients")
+
+	var line map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.Equal(t, "error", line["level"])
+	assert.Equal(t, "req-1", line["request_id"])
+	assert.Contains(t, line["panic"], "assignment to entry in nil map")
+	assert.Contains(t, line["stack"], "recover_test.go")
+}
+
+func TestRecoverAfterResponseStarted(t *testing.T) {
+	h := Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"id":1},`))
+		panic("encoding failed")
+	}))
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users", nil))
+	}, "a half-written response is aborted rather than patched up")
+
+	h = Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users", nil))
+	})
+}
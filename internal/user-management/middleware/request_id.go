@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/rs/zerolog"
+)
+
+// RequestIDHeader carries the ID of a request, from the client or a proxy
+// in front of the service, and back in the response.
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the ID of the request ctx belongs to, or ""
+// outside of a request.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestID takes the request ID from the X-Request-ID header, or
+// generates one when it is missing or malformed, and echoes it in the
+// response. The ID is on the request context, and so is a logger adding it
+// to every line: log with zerolog.Ctx(ctx) or log.Ctx(ctx).
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+		logger := zerolog.Ctx(ctx).With().Str("request_id", id).Logger()
+		next.ServeHTTP(w, r.WithContext(logger.WithContext(ctx)))
+	})
+}
+
+// validRequestID accepts IDs of up to 128 letters, digits and -_.:, which
+// covers UUIDs and the IDs of common proxies but keeps log injection out.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-' || c == '_' || c == '.' || c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
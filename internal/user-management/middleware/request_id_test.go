@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestID(t *testing.T) {
+	var seen string
+	h := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestIDFromContext(r.Context())
+	}))
+
+	for header, kept := range map[string]bool{
+		"3f2b9c1e-7d4a-4f0e-9a51-0c8d2e6b1a77": true,
+		"":                                     false,
+		"evil\nlevel=error":                    false,
+	} {
+		r := httptest.NewRequest("GET", "/users", nil)
+		r.Header.Set(RequestIDHeader, header)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		assert.Equal(t, seen, w.Header().Get(RequestIDHeader), "echoed")
+		if kept {
+			assert.Equal(t, header, seen)
+		} else {
+			assert.Regexp(t, `^[0-9a-f]{32}$`, seen)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Timeout puts a deadline on the context of each request: the one routes
+// has for "METHOD template" or, failing that, for "template" (templates as
+// in Metrics, e.g. "GET /users/{id:[0-9]+}"), else def. A zero duration
+// means no deadline, for event streams and the like. The handlers' queries
+// and calls give up at the deadline; answering then is up to the handler.
+func Timeout(router *mux.Router, def time.Duration, routes map[string]time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := routeTemplate(router, r)
+			d, ok := routes[r.Method+" "+route]
+			if !ok {
+				d, ok = routes[route]
+			}
+			if !ok {
+				d = def
+			}
+			if d <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
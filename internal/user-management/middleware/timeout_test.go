@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeout(t *testing.T) {
+	router := mux.NewRouter()
+	var deadline time.Time
+	var hasDeadline bool
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		deadline, hasDeadline = r.Context().Deadline()
+	}
+	router.HandleFunc("/users", handler).Methods("GET", "POST")
+	router.HandleFunc("/users/events", handler).Methods("GET")
+	router.HandleFunc("/users/{id:[0-9]+}", handler).Methods("GET")
+
+	h := Chain(router, Timeout(router, 30*time.Second, map[string]time.Duration{
+		"POST /users":        5 * time.Second,
+		"/users/{id:[0-9]+}": 2 * time.Second,
+		"GET /users/events":  0,
+	}))
+
+	for _, tt := range []struct {
+		method, path string
+		want         time.Duration
+	}{
+		{"GET", "/users", 30 * time.Second},
+		{"POST", "/users", 5 * time.Second},
+		{"GET", "/users/7", 2 * time.Second},
+		{"GET", "/users/events", 0},
+	} {
+		start := time.Now()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, tt.path, nil))
+		if tt.want == 0 {
+			assert.False(t, hasDeadline, "%s %s", tt.method, tt.path)
+			continue
+		}
+		if assert.True(t, hasDeadline, "%s %s", tt.method, tt.path) {
+			assert.WithinDuration(t, start.Add(tt.want), deadline, time.Second, "%s %s", tt.method, tt.path)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http"
+
+	"user-management/internal/user-management/helper"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing starts a server span for every request, named after the method
+// and the template of the router's route it matches, e.g.
+// "POST /users". The span continues the trace of the W3C traceparent
+// header the caller sent, if any, and is on the request context for the
+// handlers, and so is a logger adding the trace ID to every line. Add it
+// with Chain, see there.
+func Tracing(router *mux.Router) func(http.Handler) http.Handler {
+	tracer := otel.Tracer("user-management/internal/user-management/middleware")
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+			route := routeTemplate(router, r)
+			ctx, span := tracer.Start(ctx, metricMethod(r.Method)+" "+route,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					semconv.HTTPRequestMethodKey.String(r.Method),
+					semconv.HTTPRoute(route),
+					semconv.URLPath(r.URL.Path),
+					semconv.ClientAddress(helper.ClientIP(r)),
+				),
+			)
+			defer span.End()
+			if sc := span.SpanContext(); sc.IsValid() {
+				logger := zerolog.Ctx(ctx).With().Str("trace_id", sc.TraceID().String()).Logger()
+				ctx = logger.WithContext(ctx)
+			}
+
+			sw := &statusWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r.WithContext(ctx))
+
+			status := sw.status()
+			span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+			if status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(status))
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+// Code generated by mockery v2.53.3. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	app "user-management/app"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// ConfigChangeFunc is an autogenerated mock type for the ConfigChangeFunc type
+type ConfigChangeFunc struct {
+	mock.Mock
+}
+
+// Execute provides a mock function with given fields: ctx, old, cfg
+func (_m *ConfigChangeFunc) Execute(ctx context.Context, old *app.Config, cfg *app.Config) error {
+	ret := _m.Called(ctx, old, cfg)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Execute")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *app.Config, *app.Config) error); ok {
+		r0 = rf(ctx, old, cfg)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewConfigChangeFunc creates a new instance of ConfigChangeFunc. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewConfigChangeFunc(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *ConfigChangeFunc {
+	mock := &ConfigChangeFunc{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
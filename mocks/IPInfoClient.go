@@ -2,16 +2,20 @@
 
 package mocks
 
-import mock "github.com/stretchr/testify/mock"
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+)
 
 // IPInfoClient is an autogenerated mock type for the IPInfoClient type
 type IPInfoClient struct {
 	mock.Mock
 }
 
-// GetInfo provides a mock function with given fields: ip
-func (_m *IPInfoClient) GetInfo(ip string) (map[string]interface{}, error) {
-	ret := _m.Called(ip)
+// GetInfo provides a mock function with given fields: ctx, ip
+func (_m *IPInfoClient) GetInfo(ctx context.Context, ip string) (map[string]interface{}, error) {
+	ret := _m.Called(ctx, ip)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetInfo")
@@ -19,19 +23,19 @@ func (_m *IPInfoClient) GetInfo(ip string) (map[string]interface{}, error) {
 
 	var r0 map[string]interface{}
 	var r1 error
-	if rf, ok := ret.Get(0).(func(string) (map[string]interface{}, error)); ok {
-		return rf(ip)
+	if rf, ok := ret.Get(0).(func(context.Context, string) (map[string]interface{}, error)); ok {
+		return rf(ctx, ip)
 	}
-	if rf, ok := ret.Get(0).(func(string) map[string]interface{}); ok {
-		r0 = rf(ip)
+	if rf, ok := ret.Get(0).(func(context.Context, string) map[string]interface{}); ok {
+		r0 = rf(ctx, ip)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(map[string]interface{})
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(ip)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, ip)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -0,0 +1,35 @@
+// Code generated by mockery v2.53.3. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	entity "user-management/internal/user-management/domain/entities"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// IUserEventPublisher is an autogenerated mock type for the IUserEventPublisher type
+type IUserEventPublisher struct {
+	mock.Mock
+}
+
+// Publish provides a mock function with given fields: ctx, event
+func (_m *IUserEventPublisher) Publish(ctx context.Context, event entity.UserEvent) {
+	_m.Called(ctx, event)
+}
+
+// NewIUserEventPublisher creates a new instance of IUserEventPublisher. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewIUserEventPublisher(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *IUserEventPublisher {
+	mock := &IUserEventPublisher{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
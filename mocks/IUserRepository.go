@@ -3,6 +3,8 @@
 package mocks
 
 import (
+	context "context"
+
 	entity "user-management/internal/user-management/domain/entities"
 
 	mock "github.com/stretchr/testify/mock"
@@ -13,9 +15,9 @@ type IUserRepository struct {
 	mock.Mock
 }
 
-// Create provides a mock function with given fields: user
-func (_m *IUserRepository) Create(user entity.User) (int64, error) {
-	ret := _m.Called(user)
+// Create provides a mock function with given fields: ctx, user
+func (_m *IUserRepository) Create(ctx context.Context, user entity.User) (int64, error) {
+	ret := _m.Called(ctx, user)
 
 	if len(ret) == 0 {
 		panic("no return value specified for Create")
@@ -23,17 +25,17 @@ func (_m *IUserRepository) Create(user entity.User) (int64, error) {
 
 	var r0 int64
 	var r1 error
-	if rf, ok := ret.Get(0).(func(entity.User) (int64, error)); ok {
-		return rf(user)
+	if rf, ok := ret.Get(0).(func(context.Context, entity.User) (int64, error)); ok {
+		return rf(ctx, user)
 	}
-	if rf, ok := ret.Get(0).(func(entity.User) int64); ok {
-		r0 = rf(user)
+	if rf, ok := ret.Get(0).(func(context.Context, entity.User) int64); ok {
+		r0 = rf(ctx, user)
 	} else {
 		r0 = ret.Get(0).(int64)
 	}
 
-	if rf, ok := ret.Get(1).(func(entity.User) error); ok {
-		r1 = rf(user)
+	if rf, ok := ret.Get(1).(func(context.Context, entity.User) error); ok {
+		r1 = rf(ctx, user)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -41,17 +43,47 @@ func (_m *IUserRepository) Create(user entity.User) (int64, error) {
 	return r0, r1
 }
 
-// Delete provides a mock function with given fields: id
-func (_m *IUserRepository) Delete(id int64) error {
-	ret := _m.Called(id)
+// CreateMany provides a mock function with given fields: ctx, users
+func (_m *IUserRepository) CreateMany(ctx context.Context, users []entity.User) ([]int64, error) {
+	ret := _m.Called(ctx, users)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateMany")
+	}
+
+	var r0 []int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []entity.User) ([]int64, error)); ok {
+		return rf(ctx, users)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []entity.User) []int64); ok {
+		r0 = rf(ctx, users)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]int64)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []entity.User) error); ok {
+		r1 = rf(ctx, users)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Delete provides a mock function with given fields: ctx, id
+func (_m *IUserRepository) Delete(ctx context.Context, id int64) error {
+	ret := _m.Called(ctx, id)
 
 	if len(ret) == 0 {
 		panic("no return value specified for Delete")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(int64) error); ok {
-		r0 = rf(id)
+	if rf, ok := ret.Get(0).(func(context.Context, int64) error); ok {
+		r0 = rf(ctx, id)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -59,9 +91,39 @@ func (_m *IUserRepository) Delete(id int64) error {
 	return r0
 }
 
-// GetAll provides a mock function with no fields
-func (_m *IUserRepository) GetAll() ([]entity.User, error) {
-	ret := _m.Called()
+// DeleteMany provides a mock function with given fields: ctx, ids
+func (_m *IUserRepository) DeleteMany(ctx context.Context, ids []int64) ([]int64, error) {
+	ret := _m.Called(ctx, ids)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteMany")
+	}
+
+	var r0 []int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []int64) ([]int64, error)); ok {
+		return rf(ctx, ids)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []int64) []int64); ok {
+		r0 = rf(ctx, ids)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]int64)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []int64) error); ok {
+		r1 = rf(ctx, ids)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetAll provides a mock function with given fields: ctx, filter
+func (_m *IUserRepository) GetAll(ctx context.Context, filter entity.UserFilter) ([]entity.User, error) {
+	ret := _m.Called(ctx, filter)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetAll")
@@ -69,19 +131,19 @@ func (_m *IUserRepository) GetAll() ([]entity.User, error) {
 
 	var r0 []entity.User
 	var r1 error
-	if rf, ok := ret.Get(0).(func() ([]entity.User, error)); ok {
-		return rf()
+	if rf, ok := ret.Get(0).(func(context.Context, entity.UserFilter) ([]entity.User, error)); ok {
+		return rf(ctx, filter)
 	}
-	if rf, ok := ret.Get(0).(func() []entity.User); ok {
-		r0 = rf()
+	if rf, ok := ret.Get(0).(func(context.Context, entity.UserFilter) []entity.User); ok {
+		r0 = rf(ctx, filter)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]entity.User)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func() error); ok {
-		r1 = rf()
+	if rf, ok := ret.Get(1).(func(context.Context, entity.UserFilter) error); ok {
+		r1 = rf(ctx, filter)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -89,9 +151,9 @@ func (_m *IUserRepository) GetAll() ([]entity.User, error) {
 	return r0, r1
 }
 
-// GetByID provides a mock function with given fields: id
-func (_m *IUserRepository) GetByID(id int64) (entity.User, error) {
-	ret := _m.Called(id)
+// GetByID provides a mock function with given fields: ctx, id
+func (_m *IUserRepository) GetByID(ctx context.Context, id int64) (entity.User, error) {
+	ret := _m.Called(ctx, id)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetByID")
@@ -99,17 +161,17 @@ func (_m *IUserRepository) GetByID(id int64) (entity.User, error) {
 
 	var r0 entity.User
 	var r1 error
-	if rf, ok := ret.Get(0).(func(int64) (entity.User, error)); ok {
-		return rf(id)
+	if rf, ok := ret.Get(0).(func(context.Context, int64) (entity.User, error)); ok {
+		return rf(ctx, id)
 	}
-	if rf, ok := ret.Get(0).(func(int64) entity.User); ok {
-		r0 = rf(id)
+	if rf, ok := ret.Get(0).(func(context.Context, int64) entity.User); ok {
+		r0 = rf(ctx, id)
 	} else {
 		r0 = ret.Get(0).(entity.User)
 	}
 
-	if rf, ok := ret.Get(1).(func(int64) error); ok {
-		r1 = rf(id)
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(ctx, id)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -117,17 +179,53 @@ func (_m *IUserRepository) GetByID(id int64) (entity.User, error) {
 	return r0, r1
 }
 
-// Update provides a mock function with given fields: user
-func (_m *IUserRepository) Update(user entity.User) error {
-	ret := _m.Called(user)
+// RunInTx provides a mock function with given fields: ctx, fn
+func (_m *IUserRepository) RunInTx(ctx context.Context, fn func(context.Context) error) error {
+	ret := _m.Called(ctx, fn)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RunInTx")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, func(context.Context) error) error); ok {
+		r0 = rf(ctx, fn)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Stream provides a mock function with given fields: ctx, filter, fn
+func (_m *IUserRepository) Stream(ctx context.Context, filter entity.UserFilter, fn func(entity.User) error) error {
+	ret := _m.Called(ctx, filter, fn)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Stream")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, entity.UserFilter, func(entity.User) error) error); ok {
+		r0 = rf(ctx, filter, fn)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Update provides a mock function with given fields: ctx, user
+func (_m *IUserRepository) Update(ctx context.Context, user entity.User) error {
+	ret := _m.Called(ctx, user)
 
 	if len(ret) == 0 {
 		panic("no return value specified for Update")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(entity.User) error); ok {
-		r0 = rf(user)
+	if rf, ok := ret.Get(0).(func(context.Context, entity.User) error); ok {
+		r0 = rf(ctx, user)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -0,0 +1,58 @@
+// Code generated by mockery v2.53.3. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	entity "user-management/internal/user-management/domain/entities"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// IUserSearcher is an autogenerated mock type for the IUserSearcher type
+type IUserSearcher struct {
+	mock.Mock
+}
+
+// Search provides a mock function with given fields: ctx, query
+func (_m *IUserSearcher) Search(ctx context.Context, query entity.UserSearchQuery) (entity.UserSearchResult, error) {
+	ret := _m.Called(ctx, query)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Search")
+	}
+
+	var r0 entity.UserSearchResult
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, entity.UserSearchQuery) (entity.UserSearchResult, error)); ok {
+		return rf(ctx, query)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, entity.UserSearchQuery) entity.UserSearchResult); ok {
+		r0 = rf(ctx, query)
+	} else {
+		r0 = ret.Get(0).(entity.UserSearchResult)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, entity.UserSearchQuery) error); ok {
+		r1 = rf(ctx, query)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewIUserSearcher creates a new instance of IUserSearcher. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewIUserSearcher(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *IUserSearcher {
+	mock := &IUserSearcher{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
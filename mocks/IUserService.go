@@ -3,6 +3,7 @@
 package mocks
 
 import (
+	context "context"
 	entity "user-management/internal/user-management/domain/entities"
 
 	mock "github.com/stretchr/testify/mock"
@@ -13,17 +14,37 @@ type IUserService struct {
 	mock.Mock
 }
 
-// DeleteUser provides a mock function with given fields: id
-func (_m *IUserService) DeleteUser(id int64) error {
-	ret := _m.Called(id)
+// CreateUsers provides a mock function with given fields: ctx, users, transactional
+func (_m *IUserService) CreateUsers(ctx context.Context, users []entity.User, transactional bool) []entity.UserBatchResult {
+	ret := _m.Called(ctx, users, transactional)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateUsers")
+	}
+
+	var r0 []entity.UserBatchResult
+	if rf, ok := ret.Get(0).(func(context.Context, []entity.User, bool) []entity.UserBatchResult); ok {
+		r0 = rf(ctx, users, transactional)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]entity.UserBatchResult)
+		}
+	}
+
+	return r0
+}
+
+// DeleteUser provides a mock function with given fields: ctx, id
+func (_m *IUserService) DeleteUser(ctx context.Context, id int64) error {
+	ret := _m.Called(ctx, id)
 
 	if len(ret) == 0 {
 		panic("no return value specified for DeleteUser")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(int64) error); ok {
-		r0 = rf(id)
+	if rf, ok := ret.Get(0).(func(context.Context, int64) error); ok {
+		r0 = rf(ctx, id)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -31,9 +52,29 @@ func (_m *IUserService) DeleteUser(id int64) error {
 	return r0
 }
 
-// GetUserByID provides a mock function with given fields: id
-func (_m *IUserService) GetUserByID(id int64) (entity.User, error) {
-	ret := _m.Called(id)
+// DeleteUsers provides a mock function with given fields: ctx, ids, transactional
+func (_m *IUserService) DeleteUsers(ctx context.Context, ids []int64, transactional bool) []entity.UserBatchResult {
+	ret := _m.Called(ctx, ids, transactional)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteUsers")
+	}
+
+	var r0 []entity.UserBatchResult
+	if rf, ok := ret.Get(0).(func(context.Context, []int64, bool) []entity.UserBatchResult); ok {
+		r0 = rf(ctx, ids, transactional)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]entity.UserBatchResult)
+		}
+	}
+
+	return r0
+}
+
+// GetUserByID provides a mock function with given fields: ctx, id
+func (_m *IUserService) GetUserByID(ctx context.Context, id int64) (entity.User, error) {
+	ret := _m.Called(ctx, id)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetUserByID")
@@ -41,17 +82,17 @@ func (_m *IUserService) GetUserByID(id int64) (entity.User, error) {
 
 	var r0 entity.User
 	var r1 error
-	if rf, ok := ret.Get(0).(func(int64) (entity.User, error)); ok {
-		return rf(id)
+	if rf, ok := ret.Get(0).(func(context.Context, int64) (entity.User, error)); ok {
+		return rf(ctx, id)
 	}
-	if rf, ok := ret.Get(0).(func(int64) entity.User); ok {
-		r0 = rf(id)
+	if rf, ok := ret.Get(0).(func(context.Context, int64) entity.User); ok {
+		r0 = rf(ctx, id)
 	} else {
 		r0 = ret.Get(0).(entity.User)
 	}
 
-	if rf, ok := ret.Get(1).(func(int64) error); ok {
-		r1 = rf(id)
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(ctx, id)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -59,9 +100,9 @@ func (_m *IUserService) GetUserByID(id int64) (entity.User, error) {
 	return r0, r1
 }
 
-// ListUsers provides a mock function with no fields
-func (_m *IUserService) ListUsers() ([]entity.User, error) {
-	ret := _m.Called()
+// ListUsers provides a mock function with given fields: ctx, filter
+func (_m *IUserService) ListUsers(ctx context.Context, filter entity.UserFilter) ([]entity.User, error) {
+	ret := _m.Called(ctx, filter)
 
 	if len(ret) == 0 {
 		panic("no return value specified for ListUsers")
@@ -69,19 +110,19 @@ func (_m *IUserService) ListUsers() ([]entity.User, error) {
 
 	var r0 []entity.User
 	var r1 error
-	if rf, ok := ret.Get(0).(func() ([]entity.User, error)); ok {
-		return rf()
+	if rf, ok := ret.Get(0).(func(context.Context, entity.UserFilter) ([]entity.User, error)); ok {
+		return rf(ctx, filter)
 	}
-	if rf, ok := ret.Get(0).(func() []entity.User); ok {
-		r0 = rf()
+	if rf, ok := ret.Get(0).(func(context.Context, entity.UserFilter) []entity.User); ok {
+		r0 = rf(ctx, filter)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]entity.User)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func() error); ok {
-		r1 = rf()
+	if rf, ok := ret.Get(1).(func(context.Context, entity.UserFilter) error); ok {
+		r1 = rf(ctx, filter)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -89,29 +130,64 @@ func (_m *IUserService) ListUsers() ([]entity.User, error) {
 	return r0, r1
 }
 
-// RegisterUser provides a mock function with given fields: user, ip
-func (_m *IUserService) RegisterUser(user entity.User, ip string) (map[string]interface{}, error) {
-	ret := _m.Called(user, ip)
+// RegisterUser provides a mock function with given fields: ctx, user, ip
+func (_m *IUserService) RegisterUser(ctx context.Context, user entity.User, ip string) (entity.User, map[string]interface{}, error) {
+	ret := _m.Called(ctx, user, ip)
 
 	if len(ret) == 0 {
 		panic("no return value specified for RegisterUser")
 	}
 
-	var r0 map[string]interface{}
-	var r1 error
-	if rf, ok := ret.Get(0).(func(entity.User, string) (map[string]interface{}, error)); ok {
-		return rf(user, ip)
+	var r0 entity.User
+	var r1 map[string]interface{}
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, entity.User, string) (entity.User, map[string]interface{}, error)); ok {
+		return rf(ctx, user, ip)
 	}
-	if rf, ok := ret.Get(0).(func(entity.User, string) map[string]interface{}); ok {
-		r0 = rf(user, ip)
+	if rf, ok := ret.Get(0).(func(context.Context, entity.User, string) entity.User); ok {
+		r0 = rf(ctx, user, ip)
 	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(map[string]interface{})
+		r0 = ret.Get(0).(entity.User)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, entity.User, string) map[string]interface{}); ok {
+		r1 = rf(ctx, user, ip)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(map[string]interface{})
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(entity.User, string) error); ok {
-		r1 = rf(user, ip)
+	if rf, ok := ret.Get(2).(func(context.Context, entity.User, string) error); ok {
+		r2 = rf(ctx, user, ip)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// SearchUsers provides a mock function with given fields: ctx, query
+func (_m *IUserService) SearchUsers(ctx context.Context, query entity.UserSearchQuery) (entity.UserSearchResult, error) {
+	ret := _m.Called(ctx, query)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SearchUsers")
+	}
+
+	var r0 entity.UserSearchResult
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, entity.UserSearchQuery) (entity.UserSearchResult, error)); ok {
+		return rf(ctx, query)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, entity.UserSearchQuery) entity.UserSearchResult); ok {
+		r0 = rf(ctx, query)
+	} else {
+		r0 = ret.Get(0).(entity.UserSearchResult)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, entity.UserSearchQuery) error); ok {
+		r1 = rf(ctx, query)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -119,17 +195,17 @@ func (_m *IUserService) RegisterUser(user entity.User, ip string) (map[string]in
 	return r0, r1
 }
 
-// UpdateUser provides a mock function with given fields: user
-func (_m *IUserService) UpdateUser(user entity.User) error {
-	ret := _m.Called(user)
+// StreamUsers provides a mock function with given fields: ctx, filter, fn
+func (_m *IUserService) StreamUsers(ctx context.Context, filter entity.UserFilter, fn func(entity.User) error) error {
+	ret := _m.Called(ctx, filter, fn)
 
 	if len(ret) == 0 {
-		panic("no return value specified for UpdateUser")
+		panic("no return value specified for StreamUsers")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(entity.User) error); ok {
-		r0 = rf(user)
+	if rf, ok := ret.Get(0).(func(context.Context, entity.UserFilter, func(entity.User) error) error); ok {
+		r0 = rf(ctx, filter, fn)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -137,6 +213,54 @@ func (_m *IUserService) UpdateUser(user entity.User) error {
 	return r0
 }
 
+// UpdateUser provides a mock function with given fields: ctx, user
+func (_m *IUserService) UpdateUser(ctx context.Context, user entity.User) (entity.User, error) {
+	ret := _m.Called(ctx, user)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateUser")
+	}
+
+	var r0 entity.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, entity.User) (entity.User, error)); ok {
+		return rf(ctx, user)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, entity.User) entity.User); ok {
+		r0 = rf(ctx, user)
+	} else {
+		r0 = ret.Get(0).(entity.User)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, entity.User) error); ok {
+		r1 = rf(ctx, user)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateUsers provides a mock function with given fields: ctx, users, transactional
+func (_m *IUserService) UpdateUsers(ctx context.Context, users []entity.User, transactional bool) []entity.UserBatchResult {
+	ret := _m.Called(ctx, users, transactional)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateUsers")
+	}
+
+	var r0 []entity.UserBatchResult
+	if rf, ok := ret.Get(0).(func(context.Context, []entity.User, bool) []entity.UserBatchResult); ok {
+		r0 = rf(ctx, users, transactional)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]entity.UserBatchResult)
+		}
+	}
+
+	return r0
+}
+
 // NewIUserService creates a new instance of IUserService. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewIUserService(t interface {
@@ -0,0 +1,317 @@
+// Code generated by mockery v2.53.3. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	time "time"
+	entity "user-management/internal/user-management/domain/entities"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// IWebhookRepository is an autogenerated mock type for the IWebhookRepository type
+type IWebhookRepository struct {
+	mock.Mock
+}
+
+// CreateDelivery provides a mock function with given fields: ctx, delivery
+func (_m *IWebhookRepository) CreateDelivery(ctx context.Context, delivery entity.WebhookDelivery) (int64, error) {
+	ret := _m.Called(ctx, delivery)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateDelivery")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, entity.WebhookDelivery) (int64, error)); ok {
+		return rf(ctx, delivery)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, entity.WebhookDelivery) int64); ok {
+		r0 = rf(ctx, delivery)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, entity.WebhookDelivery) error); ok {
+		r1 = rf(ctx, delivery)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreateSubscription provides a mock function with given fields: ctx, sub
+func (_m *IWebhookRepository) CreateSubscription(ctx context.Context, sub entity.WebhookSubscription) (int64, error) {
+	ret := _m.Called(ctx, sub)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateSubscription")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, entity.WebhookSubscription) (int64, error)); ok {
+		return rf(ctx, sub)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, entity.WebhookSubscription) int64); ok {
+		r0 = rf(ctx, sub)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, entity.WebhookSubscription) error); ok {
+		r1 = rf(ctx, sub)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteSubscription provides a mock function with given fields: ctx, id
+func (_m *IWebhookRepository) DeleteSubscription(ctx context.Context, id int64) error {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteSubscription")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetActiveSubscriptions provides a mock function with given fields: ctx
+func (_m *IWebhookRepository) GetActiveSubscriptions(ctx context.Context) ([]entity.WebhookSubscription, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetActiveSubscriptions")
+	}
+
+	var r0 []entity.WebhookSubscription
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]entity.WebhookSubscription, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []entity.WebhookSubscription); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]entity.WebhookSubscription)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDeliveries provides a mock function with given fields: ctx, status
+func (_m *IWebhookRepository) GetDeliveries(ctx context.Context, status entity.WebhookDeliveryStatus) ([]entity.WebhookDelivery, error) {
+	ret := _m.Called(ctx, status)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetDeliveries")
+	}
+
+	var r0 []entity.WebhookDelivery
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, entity.WebhookDeliveryStatus) ([]entity.WebhookDelivery, error)); ok {
+		return rf(ctx, status)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, entity.WebhookDeliveryStatus) []entity.WebhookDelivery); ok {
+		r0 = rf(ctx, status)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]entity.WebhookDelivery)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, entity.WebhookDeliveryStatus) error); ok {
+		r1 = rf(ctx, status)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDeliveryByID provides a mock function with given fields: ctx, id
+func (_m *IWebhookRepository) GetDeliveryByID(ctx context.Context, id int64) (entity.WebhookDelivery, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetDeliveryByID")
+	}
+
+	var r0 entity.WebhookDelivery
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) (entity.WebhookDelivery, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64) entity.WebhookDelivery); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(entity.WebhookDelivery)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDueDeliveries provides a mock function with given fields: ctx, now, limit
+func (_m *IWebhookRepository) GetDueDeliveries(ctx context.Context, now time.Time, limit int) ([]entity.WebhookDelivery, error) {
+	ret := _m.Called(ctx, now, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetDueDeliveries")
+	}
+
+	var r0 []entity.WebhookDelivery
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, int) ([]entity.WebhookDelivery, error)); ok {
+		return rf(ctx, now, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, int) []entity.WebhookDelivery); ok {
+		r0 = rf(ctx, now, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]entity.WebhookDelivery)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time, int) error); ok {
+		r1 = rf(ctx, now, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetSubscriptionByID provides a mock function with given fields: ctx, id
+func (_m *IWebhookRepository) GetSubscriptionByID(ctx context.Context, id int64) (entity.WebhookSubscription, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetSubscriptionByID")
+	}
+
+	var r0 entity.WebhookSubscription
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) (entity.WebhookSubscription, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64) entity.WebhookSubscription); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(entity.WebhookSubscription)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetSubscriptions provides a mock function with given fields: ctx
+func (_m *IWebhookRepository) GetSubscriptions(ctx context.Context) ([]entity.WebhookSubscription, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetSubscriptions")
+	}
+
+	var r0 []entity.WebhookSubscription
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]entity.WebhookSubscription, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []entity.WebhookSubscription); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]entity.WebhookSubscription)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateDelivery provides a mock function with given fields: ctx, delivery
+func (_m *IWebhookRepository) UpdateDelivery(ctx context.Context, delivery entity.WebhookDelivery) error {
+	ret := _m.Called(ctx, delivery)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateDelivery")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, entity.WebhookDelivery) error); ok {
+		r0 = rf(ctx, delivery)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateSubscription provides a mock function with given fields: ctx, sub
+func (_m *IWebhookRepository) UpdateSubscription(ctx context.Context, sub entity.WebhookSubscription) error {
+	ret := _m.Called(ctx, sub)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateSubscription")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, entity.WebhookSubscription) error); ok {
+		r0 = rf(ctx, sub)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewIWebhookRepository creates a new instance of IWebhookRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewIWebhookRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *IWebhookRepository {
+	mock := &IWebhookRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
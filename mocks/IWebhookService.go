@@ -0,0 +1,265 @@
+// Code generated by mockery v2.53.3. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	entity "user-management/internal/user-management/domain/entities"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// IWebhookService is an autogenerated mock type for the IWebhookService type
+type IWebhookService struct {
+	mock.Mock
+}
+
+// CreateSubscription provides a mock function with given fields: ctx, sub
+func (_m *IWebhookService) CreateSubscription(ctx context.Context, sub entity.WebhookSubscription) (entity.WebhookSubscription, error) {
+	ret := _m.Called(ctx, sub)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateSubscription")
+	}
+
+	var r0 entity.WebhookSubscription
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, entity.WebhookSubscription) (entity.WebhookSubscription, error)); ok {
+		return rf(ctx, sub)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, entity.WebhookSubscription) entity.WebhookSubscription); ok {
+		r0 = rf(ctx, sub)
+	} else {
+		r0 = ret.Get(0).(entity.WebhookSubscription)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, entity.WebhookSubscription) error); ok {
+		r1 = rf(ctx, sub)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteSubscription provides a mock function with given fields: ctx, id
+func (_m *IWebhookService) DeleteSubscription(ctx context.Context, id int64) error {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteSubscription")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetDeliveryByID provides a mock function with given fields: ctx, id
+func (_m *IWebhookService) GetDeliveryByID(ctx context.Context, id int64) (entity.WebhookDelivery, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetDeliveryByID")
+	}
+
+	var r0 entity.WebhookDelivery
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) (entity.WebhookDelivery, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64) entity.WebhookDelivery); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(entity.WebhookDelivery)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetSubscriptionByID provides a mock function with given fields: ctx, id
+func (_m *IWebhookService) GetSubscriptionByID(ctx context.Context, id int64) (entity.WebhookSubscription, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetSubscriptionByID")
+	}
+
+	var r0 entity.WebhookSubscription
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) (entity.WebhookSubscription, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64) entity.WebhookSubscription); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(entity.WebhookSubscription)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListDeliveries provides a mock function with given fields: ctx, status
+func (_m *IWebhookService) ListDeliveries(ctx context.Context, status entity.WebhookDeliveryStatus) ([]entity.WebhookDelivery, error) {
+	ret := _m.Called(ctx, status)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListDeliveries")
+	}
+
+	var r0 []entity.WebhookDelivery
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, entity.WebhookDeliveryStatus) ([]entity.WebhookDelivery, error)); ok {
+		return rf(ctx, status)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, entity.WebhookDeliveryStatus) []entity.WebhookDelivery); ok {
+		r0 = rf(ctx, status)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]entity.WebhookDelivery)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, entity.WebhookDeliveryStatus) error); ok {
+		r1 = rf(ctx, status)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListSubscriptions provides a mock function with given fields: ctx
+func (_m *IWebhookService) ListSubscriptions(ctx context.Context) ([]entity.WebhookSubscription, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListSubscriptions")
+	}
+
+	var r0 []entity.WebhookSubscription
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]entity.WebhookSubscription, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []entity.WebhookSubscription); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]entity.WebhookSubscription)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ProcessDue provides a mock function with given fields: ctx
+func (_m *IWebhookService) ProcessDue(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ProcessDue")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Publish provides a mock function with given fields: ctx, event
+func (_m *IWebhookService) Publish(ctx context.Context, event entity.UserEvent) {
+	_m.Called(ctx, event)
+}
+
+// ReplayDelivery provides a mock function with given fields: ctx, id
+func (_m *IWebhookService) ReplayDelivery(ctx context.Context, id int64) (entity.WebhookDelivery, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReplayDelivery")
+	}
+
+	var r0 entity.WebhookDelivery
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) (entity.WebhookDelivery, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64) entity.WebhookDelivery); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(entity.WebhookDelivery)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Run provides a mock function with given fields: ctx
+func (_m *IWebhookService) Run(ctx context.Context) {
+	_m.Called(ctx)
+}
+
+// UpdateSubscription provides a mock function with given fields: ctx, sub
+func (_m *IWebhookService) UpdateSubscription(ctx context.Context, sub entity.WebhookSubscription) error {
+	ret := _m.Called(ctx, sub)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateSubscription")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, entity.WebhookSubscription) error); ok {
+		r0 = rf(ctx, sub)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewIWebhookService creates a new instance of IWebhookService. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewIWebhookService(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *IWebhookService {
+	mock := &IWebhookService{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,57 @@
+// Code generated by mockery v2.53.3. DO NOT EDIT.
+
+package mocks
+
+import (
+	mock "github.com/stretchr/testify/mock"
+	bun "github.com/uptrace/bun"
+)
+
+// ReadDBFunc is an autogenerated mock type for the ReadDBFunc type
+type ReadDBFunc struct {
+	mock.Mock
+}
+
+// Execute provides a mock function with no fields
+func (_m *ReadDBFunc) Execute() (*bun.DB, error) {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for Execute")
+	}
+
+	var r0 *bun.DB
+	var r1 error
+	if rf, ok := ret.Get(0).(func() (*bun.DB, error)); ok {
+		return rf()
+	}
+	if rf, ok := ret.Get(0).(func() *bun.DB); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*bun.DB)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewReadDBFunc creates a new instance of ReadDBFunc. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewReadDBFunc(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *ReadDBFunc {
+	mock := &ReadDBFunc{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}